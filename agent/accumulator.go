@@ -2,6 +2,7 @@ package agent
 
 import (
 	"log"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -167,3 +168,30 @@ func (a *trackingAccumulator) onDelivery(info telegraf.DeliveryInfo) {
 		panic("channel is full")
 	}
 }
+
+// errorRecorder is an Accumulator that remembers whether any error was added
+// to it.
+type errorRecorder struct {
+	telegraf.Accumulator
+
+	sync.Mutex
+	failed bool
+}
+
+func (r *errorRecorder) AddError(err error) {
+	if err == nil {
+		return
+	}
+
+	r.Lock()
+	r.failed = true
+	r.Unlock()
+	r.Accumulator.AddError(err)
+}
+
+// Failed returns true if an error was added to the accumulator.
+func (r *errorRecorder) Failed() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.failed
+}
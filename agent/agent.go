@@ -241,6 +241,75 @@ func (a *Agent) Test(ctx context.Context, waitDuration time.Duration) error {
 	return nil
 }
 
+// Check initializes the plugins and runs each input once, discarding the
+// metrics.  It returns the names of the inputs that reported an error.
+// Service inputs are initialized but not started, so that a configuration
+// can be checked while another agent using the same resources is running.
+func (a *Agent) Check(ctx context.Context) ([]string, error) {
+	log.Printf("D! [agent] Initializing plugins")
+	err := a.initPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	metricC := make(chan telegraf.Metric, 100)
+	stop := make(chan struct{})
+	var gathers sync.WaitGroup
+	defer func() {
+		// Gather cannot be canceled: the metrics of an input that did not
+		// complete are discarded until it returns, so that it does not
+		// block forever.
+		go func() {
+			gathers.Wait()
+			close(stop)
+		}()
+	}()
+	go func() {
+		for {
+			select {
+			case metric := <-metricC:
+				metric.Drop()
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var failed []string
+	for _, input := range a.Config.Inputs {
+		if _, ok := input.Input.(telegraf.ServiceInput); ok {
+			continue
+		}
+
+		acc := &errorRecorder{Accumulator: NewAccumulator(input, metricC)}
+		acc.SetPrecision(a.Precision())
+
+		done := make(chan error, 1)
+		gathers.Add(1)
+		go func(input *models.RunningInput) {
+			defer gathers.Done()
+			done <- input.Input.Gather(acc)
+		}(input)
+
+		select {
+		case err := <-done:
+			acc.AddError(err)
+		case <-ctx.Done():
+			return append(failed, input.LogName()),
+				fmt.Errorf("%s did not complete: %v", input.LogName(), ctx.Err())
+		}
+
+		if acc.Failed() {
+			failed = append(failed, input.LogName())
+		}
+	}
+
+	if len(failed) > 0 {
+		return failed, fmt.Errorf("One or more input plugins had an error")
+	}
+	return nil, nil
+}
+
 // runInputs starts and triggers the periodic gather for Inputs.
 //
 // When the context is done the timers are stopped and this function returns
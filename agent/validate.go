@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/internal/validate"
+)
+
+func init() {
+	validate.SetCheck(func(ctx context.Context, c interface{}) ([]string, error) {
+		a, err := NewAgent(c.(*config.Config))
+		if err != nil {
+			return nil, err
+		}
+		return a.Check(ctx)
+	})
+}
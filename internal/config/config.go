@@ -1007,6 +1007,9 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 		t.SetParserFunc(func() (parsers.Parser, error) {
 			return parsers.NewParser(config)
 		})
+		if pc, ok := input.(parsers.ParserConfigInput); ok {
+			pc.SetParserConfig(config)
+		}
 	}
 
 	pluginConfig, err := buildInput(name, table)
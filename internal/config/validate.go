@@ -0,0 +1,18 @@
+package config
+
+import (
+	"github.com/influxdata/telegraf/internal/validate"
+)
+
+func init() {
+	validate.SetLoad(func(paths []string) (*validate.Loaded, error) {
+		c := NewConfig()
+		for _, p := range paths {
+			err := c.LoadConfig(p)
+			if err != nil {
+				return nil, &validate.Error{Path: p, Err: err}
+			}
+		}
+		return &validate.Loaded{Config: c, Inputs: len(c.Inputs), Outputs: len(c.Outputs)}, nil
+	})
+}
@@ -0,0 +1,115 @@
+package encoding
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// Detector detects the character encoding of text from its first bytes.
+type Detector struct {
+	names      []string
+	candidates []encoding.Encoding
+}
+
+// NewDetector returns a detector of UTF-8 and UTF-16 text, and of the text
+// in one of the candidate encodings, tried in order when the text is
+// neither UTF-8 nor UTF-16.
+func NewDetector(candidates []string) (*Detector, error) {
+	d := &Detector{}
+	for _, name := range candidates {
+		enc, err := Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if enc == nil {
+			// UTF-8 is detected before the candidates
+			continue
+		}
+		d.names = append(d.names, name)
+		d.candidates = append(d.candidates, enc)
+	}
+	return d, nil
+}
+
+// Detect returns the name and the encoding of text starting with head: the
+// encoding of its byte order mark, UTF-16 when about every other byte is
+// zero as in mostly ASCII text, UTF-8 when it is valid UTF-8, or else the
+// first candidate decoding it without invalid characters.  UTF-8 returns a
+// nil Encoding, as does text none of the candidates decodes.
+func (d *Detector) Detect(head []byte) (string, encoding.Encoding) {
+	if name, enc, size := BOM(head); size > 0 {
+		return name, enc
+	}
+
+	var even, odd int
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			even++
+		}
+		if head[i+1] == 0 {
+			odd++
+		}
+	}
+	pairs := len(head) / 2
+	switch {
+	case pairs > 0 && odd > pairs/2 && even < odd/10:
+		return "utf-16le", utf16["utf-16le"]
+	case pairs > 0 && even > pairs/2 && odd < even/10:
+		return "utf-16be", utf16["utf-16be"]
+	}
+
+	head = trimPartialRune(head)
+	if utf8.Valid(head) {
+		return "utf-8", nil
+	}
+
+	for i, enc := range d.candidates {
+		if decodesCleanly(enc, head) {
+			return d.names[i], enc
+		}
+	}
+	return "utf-8", nil
+}
+
+// BOM returns the name and the encoding of the byte order mark head starts
+// with, and its size in bytes, which is 0 when there is none.  UTF-8
+// returns a nil Encoding.
+func BOM(head []byte) (string, encoding.Encoding, int) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8", nil, 3
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return "utf-16le", utf16["utf-16le"], 2
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return "utf-16be", utf16["utf-16be"], 2
+	}
+	return "", nil, 0
+}
+
+// trimPartialRune trims the bytes of a UTF-8 character cut at the end of
+// head.
+func trimPartialRune(head []byte) []byte {
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				return head[:i]
+			}
+			break
+		}
+	}
+	return head
+}
+
+// decodesCleanly returns whether enc decodes head without replacing invalid
+// bytes.  A character cut at the end of head is ignored.
+func decodesCleanly(enc encoding.Encoding, head []byte) bool {
+	dst := make([]byte, 4*len(head)+utf8.UTFMax)
+	nDst, _, err := enc.NewDecoder().Transform(dst, head, false)
+	if err != nil && err != transform.ErrShortSrc {
+		return false
+	}
+	return !bytes.ContainsRune(dst[:nDst], utf8.RuneError)
+}
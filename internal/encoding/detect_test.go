@@ -0,0 +1,108 @@
+package encoding
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		head       string
+		expected   string
+	}{
+		{
+			name:     "ascii",
+			head:     "cpu usage_idle=100\n",
+			expected: "utf-8",
+		},
+		{
+			name:     "utf-8",
+			head:     "cpu,host=日本 usage_idle=100\n",
+			expected: "utf-8",
+		},
+		{
+			name:     "utf-8 cut in a character",
+			head:     "cpu,host=日本"[:len("cpu,host=日本")-1],
+			expected: "utf-8",
+		},
+		{
+			name:     "utf-8 bom",
+			head:     "\xef\xbb\xbfcpu\n",
+			expected: "utf-8",
+		},
+		{
+			name:     "utf-16le bom",
+			head:     "\xff\xfec\x00",
+			expected: "utf-16le",
+		},
+		{
+			name:     "utf-16be bom",
+			head:     "\xfe\xff\x00c",
+			expected: "utf-16be",
+		},
+		{
+			name:     "utf-16le",
+			head:     "c\x00p\x00u\x00 \x00u\x00s\x00a\x00g\x00e\x00\n\x00",
+			expected: "utf-16le",
+		},
+		{
+			name:     "utf-16be",
+			head:     "\x00c\x00p\x00u\x00 \x00u\x00s\x00a\x00g\x00e\x00\n",
+			expected: "utf-16be",
+		},
+		{
+			name:       "first candidate decoding cleanly",
+			candidates: []string{"euc-jp", "shift_jis", "windows-1252"},
+			head:       "cpu,host=\x93\xfa\x96\x7b usage_idle=100\n",
+			expected:   "shift_jis",
+		},
+		{
+			name:     "no candidate",
+			head:     "cpu,host=\x93\xfa\x96\x7b usage_idle=100\n",
+			expected: "utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDetector(tt.candidates)
+			require.NoError(t, err)
+			name, enc := d.Detect([]byte(tt.head))
+			require.Equal(t, tt.expected, name)
+			if name == "utf-8" {
+				require.Nil(t, enc)
+			} else {
+				require.NotNil(t, enc)
+			}
+		})
+	}
+}
+
+func TestNewDetectorUnknown(t *testing.T) {
+	_, err := NewDetector([]string{"shift_jis", "klingon"})
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "klingon"))
+}
+
+func TestBOM(t *testing.T) {
+	tests := []struct {
+		head     string
+		expected string
+		size     int
+	}{
+		{head: "\xef\xbb\xbfcpu", expected: "utf-8", size: 3},
+		{head: "\xff\xfec\x00", expected: "utf-16le", size: 2},
+		{head: "\xfe\xff\x00c", expected: "utf-16be", size: 2},
+		{head: "cpu", expected: "", size: 0},
+		{head: "", expected: "", size: 0},
+	}
+	for _, tt := range tests {
+		name, _, size := BOM([]byte(tt.head))
+		require.Equal(t, tt.expected, name)
+		require.Equal(t, tt.size, size)
+	}
+}
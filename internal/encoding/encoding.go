@@ -0,0 +1,71 @@
+// Package encoding looks up the character encodings of text read by plugins,
+// such as log files written in the locale of the host.
+package encoding
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// aliases are names commonly used for encodings that the WHATWG Encoding
+// Standard spells differently.
+var aliases = map[string]string{
+	"shift-jis": "shift_jis",
+	"sjis":      "shift_jis",
+	"cp932":     "windows-31j",
+	"eucjp":     "euc-jp",
+	"euckr":     "euc-kr",
+	"cp936":     "gbk",
+	"utf16le":   "utf-16le",
+	"utf16be":   "utf-16be",
+	"utf16":     "utf-16",
+}
+
+// utf16 are the UTF-16 encodings, which unlike those of the WHATWG Encoding
+// Standard strip the byte order mark that Windows applications write at the
+// start of their files.
+var utf16 = map[string]encoding.Encoding{
+	"utf-16":   unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16le": unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	"utf-16be": unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+}
+
+// Lookup returns the character encoding with the given name, which is any
+// label of the WHATWG Encoding Standard, such as "utf-16le", "shift_jis",
+// "euc-jp" or "gbk".  UTF-8, or an empty name, returns a nil Encoding: the
+// text needs no decoding.
+func Lookup(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	switch name {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	}
+	if enc, ok := utf16[name]; ok {
+		return enc, nil
+	}
+
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown character encoding %q", name)
+	}
+	return enc, nil
+}
+
+// NewDecoder returns a decoder of text in the encoding enc, or of UTF-8 text
+// when enc is nil.  A byte order mark at the start of the text is stripped,
+// and overrides enc with the UTF-8 or UTF-16 encoding it stands for.
+func NewDecoder(enc encoding.Encoding) transform.Transformer {
+	fallback := encoding.Nop.NewDecoder()
+	if enc != nil {
+		fallback = enc.NewDecoder()
+	}
+	return unicode.BOMOverride(fallback)
+}
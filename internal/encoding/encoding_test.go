@@ -0,0 +1,129 @@
+package encoding
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/transform"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		input    string
+		expected string
+	}{
+		{
+			name:     "shift-jis",
+			encoding: "Shift-JIS",
+			input:    "\x93\xfa\x96\x7b",
+			expected: "日本",
+		},
+		{
+			name:     "euc-jp",
+			encoding: "euc-jp",
+			input:    "\xc6\xfc\xcb\xdc",
+			expected: "日本",
+		},
+		{
+			name:     "gbk",
+			encoding: "gbk",
+			input:    "\xd6\xd0\xce\xc4",
+			expected: "中文",
+		},
+		{
+			name:     "utf-16le",
+			encoding: "utf-16le",
+			input:    "a\x00b\x00\n\x00",
+			expected: "ab\n",
+		},
+		{
+			name:     "utf-16le with bom",
+			encoding: "utf-16le",
+			input:    "\xff\xfea\x00b\x00\n\x00",
+			expected: "ab\n",
+		},
+		{
+			name:     "utf-16be with bom",
+			encoding: "UTF-16BE",
+			input:    "\xfe\xff\x00a\x00b\x00\n",
+			expected: "ab\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := Lookup(tt.encoding)
+			require.NoError(t, err)
+			require.NotNil(t, enc)
+
+			out, err := ioutil.ReadAll(enc.NewDecoder().Reader(strings.NewReader(tt.input)))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(out))
+		})
+	}
+}
+
+func TestLookupUTF8(t *testing.T) {
+	for _, name := range []string{"", "utf-8", "UTF8"} {
+		enc, err := Lookup(name)
+		require.NoError(t, err)
+		require.Nil(t, enc)
+	}
+}
+
+func TestLookupUnknown(t *testing.T) {
+	_, err := Lookup("klingon")
+	require.Error(t, err)
+}
+
+func TestNewDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		input    string
+		expected string
+	}{
+		{
+			name:     "utf-8",
+			input:    "caf\xc3\xa9\n",
+			expected: "café\n",
+		},
+		{
+			name:     "utf-8 with bom",
+			input:    "\xef\xbb\xbfab\n",
+			expected: "ab\n",
+		},
+		{
+			name:     "invalid utf-8 unchanged",
+			input:    "a\xffb\n",
+			expected: "a\xffb\n",
+		},
+		{
+			name:     "utf-16le bom without encoding",
+			input:    "\xff\xfea\x00b\x00\n\x00",
+			expected: "ab\n",
+		},
+		{
+			name:     "bom overrides encoding",
+			encoding: "shift_jis",
+			input:    "\xef\xbb\xbf\xe6\x97\xa5\n",
+			expected: "日\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := Lookup(tt.encoding)
+			require.NoError(t, err)
+
+			r := transform.NewReader(strings.NewReader(tt.input), NewDecoder(enc))
+			out, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(out))
+		})
+	}
+}
@@ -103,6 +103,23 @@ func (w *FileWriter) Close() (err error) {
 	return nil
 }
 
+// Name returns the name of the current file.
+func (w *FileWriter) Name() string {
+	return w.filename
+}
+
+// Rotate archives the current file and opens a new one, regardless of the
+// rotation interval and size.
+func (w *FileWriter) Rotate() (err error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if err = w.rotate(); err != nil {
+		return err
+	}
+	return w.openCurrent()
+}
+
 func (w *FileWriter) openCurrent() (err error) {
 	// In case ModTime() fails, we use time.Now()
 	w.expireTime = time.Now().Add(w.interval)
@@ -113,3 +113,20 @@ func TestFileWriter_CloseRotates(t *testing.T) {
 	assert.Equal(t, 1, len(files))
 	assert.Regexp(t, "^test\\.[^\\.]+\\.log$", files[0].Name())
 }
+
+func TestFileWriter_Rotate(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "RotationForced")
+	require.NoError(t, err)
+	writer, err := NewFileWriter(filepath.Join(tempDir, "test.log"), 0, 1024, -1)
+	require.NoError(t, err)
+	defer func() { writer.Close(); os.RemoveAll(tempDir) }()
+
+	_, err = writer.Write([]byte("Hello World"))
+	require.NoError(t, err)
+	require.NoError(t, writer.(*FileWriter).Rotate())
+	_, err = writer.Write([]byte("Hello World 2"))
+	require.NoError(t, err)
+
+	files, _ := ioutil.ReadDir(tempDir)
+	assert.Equal(t, 2, len(files))
+}
@@ -0,0 +1,108 @@
+// Package validate checks a configuration file before it is put in use.
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Loaded is a configuration loaded by a LoadFunc.
+type Loaded struct {
+	// Config is the loaded configuration, passed to the CheckFunc.
+	Config interface{}
+	// Inputs and Outputs are the numbers of plugins of the configuration.
+	Inputs  int
+	Outputs int
+}
+
+// LoadFunc loads the configuration made of all the files in paths.  A file
+// that fails to load is returned as an *Error with its Path.
+type LoadFunc func(paths []string) (*Loaded, error)
+
+// CheckFunc initializes the plugins of a loaded configuration and runs each
+// input once, returning the inputs that reported an error.
+type CheckFunc func(ctx context.Context, config interface{}) ([]string, error)
+
+var (
+	load  LoadFunc
+	check CheckFunc
+)
+
+// SetLoad sets how configurations are loaded.  It is set by the config
+// package, and SetCheck by the agent package, which cannot be imported
+// here: the plugins using this package are imported by their tests.
+func SetLoad(f LoadFunc) {
+	load = f
+}
+
+// SetCheck sets how the inputs of a configuration are run.  Until it is
+// set, the inputs are only loaded.
+func SetCheck(f CheckFunc) {
+	check = f
+}
+
+// Error describes why a configuration failed validation.
+type Error struct {
+	// Path of the configuration file.
+	Path string
+	// Inputs that reported an error while gathering, if any.
+	Inputs []string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if len(e.Inputs) > 0 {
+		return fmt.Sprintf("%s: %v: %s", e.Path, e.Err, strings.Join(e.Inputs, ", "))
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Config loads the configuration file at path and runs its inputs once.
+// Validation fails with an *Error when the configuration does not load, has
+// no inputs or outputs, or an input reports an error or does not finish
+// gathering within timeout.
+func Config(path string, timeout time.Duration) error {
+	return Configs([]string{path}, timeout)
+}
+
+// Configs validates the configuration made of all the files in paths, as
+// Config does for a single file.  The Path of a returned *Error is the file
+// that failed to load, or the first file of paths.
+func Configs(paths []string, timeout time.Duration) error {
+	if len(paths) == 0 {
+		return &Error{Err: errors.New("no configuration files")}
+	}
+	path := paths[0]
+
+	if load == nil {
+		return &Error{Path: path, Err: errors.New("configurations cannot be loaded")}
+	}
+	c, err := load(paths)
+	if err != nil {
+		return err
+	}
+
+	if c.Outputs == 0 {
+		return &Error{Path: path, Err: errors.New("no outputs found")}
+	}
+	if c.Inputs == 0 {
+		return &Error{Path: path, Err: errors.New("no inputs found")}
+	}
+
+	if check == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	failed, err := check(ctx, c.Config)
+	if err != nil {
+		return &Error{Path: path, Inputs: failed, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package validate_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	_ "github.com/influxdata/telegraf/agent"
+	"github.com/influxdata/telegraf/internal/validate"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/stretchr/testify/require"
+)
+
+// gatheredMetrics receives the number of metrics added by a mock input with
+// metrics set, once its Gather returns.
+var gatheredMetrics = make(chan int, 1)
+
+type mockInput struct {
+	Fail    bool
+	Block   bool
+	Metrics int
+}
+
+func (m *mockInput) SampleConfig() string { return "" }
+func (m *mockInput) Description() string  { return "" }
+func (m *mockInput) Gather(acc telegraf.Accumulator) error {
+	if m.Block {
+		time.Sleep(time.Second)
+	}
+	if m.Fail {
+		return errors.New("gather failed")
+	}
+	acc.AddFields("mock", map[string]interface{}{"value": 42}, nil)
+	for i := 0; i < m.Metrics; i++ {
+		acc.AddFields("mock", map[string]interface{}{"value": i}, nil)
+	}
+	if m.Metrics > 0 {
+		gatheredMetrics <- m.Metrics
+	}
+	return nil
+}
+
+type mockOutput struct{}
+
+func (m *mockOutput) SampleConfig() string                  { return "" }
+func (m *mockOutput) Description() string                   { return "" }
+func (m *mockOutput) Connect() error                        { return nil }
+func (m *mockOutput) Close() error                          { return nil }
+func (m *mockOutput) Write(metrics []telegraf.Metric) error { return nil }
+
+func init() {
+	inputs.Add("validate_mock", func() telegraf.Input { return &mockInput{} })
+	outputs.Add("validate_mock", func() telegraf.Output { return &mockOutput{} })
+}
+
+func writeConfig(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "telegraf.conf")
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	require.NoError(t, err)
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected *validate.Error
+	}{
+		{
+			name:   "valid",
+			config: "[[outputs.validate_mock]]\n[[inputs.validate_mock]]\n",
+		},
+		{
+			name:     "unparsable",
+			config:   "[[outputs.validate_mock]\n",
+			expected: &validate.Error{},
+		},
+		{
+			name:     "unknown plugin",
+			config:   "[[outputs.validate_mock]]\n[[inputs.doesnotexist]]\n",
+			expected: &validate.Error{},
+		},
+		{
+			name:     "no outputs",
+			config:   "[[inputs.validate_mock]]\n",
+			expected: &validate.Error{},
+		},
+		{
+			name:     "no inputs",
+			config:   "[[outputs.validate_mock]]\n",
+			expected: &validate.Error{},
+		},
+		{
+			name: "input error",
+			config: "[[outputs.validate_mock]]\n" +
+				"[[inputs.validate_mock]]\n" +
+				"[[inputs.validate_mock]]\n  alias = \"broken\"\n  fail = true\n",
+			expected: &validate.Error{Inputs: []string{"inputs.validate_mock::broken"}},
+		},
+		{
+			name: "input timeout",
+			config: "[[outputs.validate_mock]]\n" +
+				"[[inputs.validate_mock]]\n  block = true\n",
+			expected: &validate.Error{Inputs: []string{"inputs.validate_mock"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := writeConfig(t, tt.config)
+			defer cleanup()
+
+			err := validate.Config(path, 100*time.Millisecond)
+			if tt.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			verr, ok := err.(*validate.Error)
+			require.True(t, ok, "expected *validate.Error, got %v", err)
+			require.Equal(t, path, verr.Path)
+			require.Equal(t, tt.expected.Inputs, verr.Inputs)
+			require.Error(t, verr.Err)
+		})
+	}
+}
+
+func TestConfigs(t *testing.T) {
+	main, cleanup := writeConfig(t, "[[outputs.validate_mock]]\n")
+	defer cleanup()
+
+	input, cleanup := writeConfig(t, "[[inputs.validate_mock]]\n")
+	defer cleanup()
+
+	require.NoError(t, validate.Configs([]string{main, input}, 100*time.Millisecond))
+
+	broken, cleanup := writeConfig(t, "[[inputs.validate_mock]\n")
+	defer cleanup()
+
+	err := validate.Configs([]string{main, input, broken}, 100*time.Millisecond)
+	verr, ok := err.(*validate.Error)
+	require.True(t, ok, "expected *validate.Error, got %v", err)
+	require.Equal(t, broken, verr.Path)
+}
+
+func TestConfigTimeoutCompletesGather(t *testing.T) {
+	path, cleanup := writeConfig(t, "[[outputs.validate_mock]]\n"+
+		"[[inputs.validate_mock]]\n  block = true\n  metrics = 1000\n")
+	defer cleanup()
+
+	err := validate.Config(path, 100*time.Millisecond)
+	require.Error(t, err)
+
+	// the metrics of the input are discarded after the timeout
+	select {
+	case n := <-gatheredMetrics:
+		require.Equal(t, 1000, n)
+	case <-time.After(5 * time.Second):
+		t.Fatal("gather blocked after the timeout")
+	}
+}
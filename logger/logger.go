@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf/internal"
@@ -131,6 +132,7 @@ var actualLogger io.Writer
 
 func newLogWriter(config LogConfig) io.Writer {
 	log.SetFlags(0)
+	cancelDebug()
 	if config.Debug {
 		wlog.SetLevel(wlog.DEBUG)
 	}
@@ -157,6 +159,104 @@ func newLogWriter(config LogConfig) io.Writer {
 	return logWriter
 }
 
+var (
+	debugMu    sync.Mutex
+	debugTimer *time.Timer
+	// debugGen tells apart the timers of successive calls to EnableDebugFor
+	debugGen int
+	// debugRestore is the level in effect before debug logging was enabled
+	debugRestore wlog.Level
+)
+
+// EnableDebugFor raises the log level to debug for d, after which the level
+// set up by SetupLogging is restored.  Calling it again while debug logging
+// is enabled sets a new end to it.
+func EnableDebugFor(d time.Duration) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+
+	if debugTimer == nil {
+		debugRestore = wlog.LogLevel()
+	} else {
+		debugTimer.Stop()
+	}
+	wlog.SetLevel(wlog.DEBUG)
+
+	debugGen++
+	gen := debugGen
+	debugTimer = time.AfterFunc(d, func() {
+		debugMu.Lock()
+		defer debugMu.Unlock()
+		if gen != debugGen {
+			return
+		}
+		wlog.SetLevel(debugRestore)
+		debugTimer = nil
+		log.Printf("I! Debug logging disabled")
+	})
+}
+
+// cancelDebug ends debug logging enabled with EnableDebugFor without
+// restoring the log level.
+func cancelDebug() {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+
+	if debugTimer != nil {
+		debugTimer.Stop()
+		debugTimer = nil
+		debugGen++
+	}
+}
+
+// LogFile returns the name of the file the log is written to, or an empty
+// string when the log is not written to a file.
+func LogFile() string {
+	t, ok := actualLogger.(*telegrafLog)
+	if !ok {
+		return ""
+	}
+
+	switch w := t.internalWriter.(type) {
+	case *rotate.FileWriter:
+		return w.Name()
+	case *os.File:
+		if w != os.Stderr {
+			return w.Name()
+		}
+	}
+	return ""
+}
+
+// RotateLogFile archives the current log file and starts a new one.  When
+// no rotation is configured, the log file is opened again instead, so that
+// a file moved away by an external tool such as logrotate is recreated.  It
+// fails when the log is not written to a file.
+func RotateLogFile() error {
+	t, ok := actualLogger.(*telegrafLog)
+	if !ok {
+		return errors.New("the log is not written to a file")
+	}
+
+	switch w := t.internalWriter.(type) {
+	case *rotate.FileWriter:
+		return w.Rotate()
+	case *os.File:
+		if w == os.Stderr {
+			break
+		}
+		f, err := os.OpenFile(w.Name(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		logWriter := newTelegrafWriter(f)
+		log.SetOutput(logWriter)
+		actualLogger = logWriter
+		return w.Close()
+	}
+	return errors.New("the log is not written to a file")
+}
+
 func init() {
 	tlc := &telegrafLogCreator{}
 	registerLogger("", tlc)
@@ -8,8 +8,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/wlog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -114,6 +116,63 @@ func TestWriteToFileInRotation(t *testing.T) {
 	assert.Equal(t, 2, len(files))
 }
 
+func TestRotateLogFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "LogRotation")
+	require.NoError(t, err)
+	config := createBasicLogConfig(filepath.Join(tempDir, "test.log"))
+	config.LogTarget = LogTargetFile
+	config.RotationMaxSize = internal.Size{Size: int64(1024)}
+	writer := newLogWriter(config)
+	closer, isCloser := writer.(io.Closer)
+	assert.True(t, isCloser)
+	defer func() { closer.Close(); os.RemoveAll(tempDir) }()
+
+	log.Printf("I! TEST")
+	require.NoError(t, RotateLogFile())
+	files, _ := ioutil.ReadDir(tempDir)
+	assert.Equal(t, 2, len(files))
+
+	SetupLogging(LogConfig{LogTarget: "stderr", Quiet: true})
+	require.Error(t, RotateLogFile())
+	assert.Equal(t, "", LogFile())
+}
+
+func TestRotateLogFileReopens(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "LogReopen")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	logfile := filepath.Join(tempDir, "test.log")
+	config := createBasicLogConfig(logfile)
+	config.LogTarget = LogTargetFile
+	newLogWriter(config)
+	defer SetupLogging(LogConfig{LogTarget: "stderr", Quiet: true})
+	assert.Equal(t, logfile, LogFile())
+
+	// the log file is moved away, as done by logrotate
+	require.NoError(t, os.Rename(logfile, logfile+".1"))
+	require.NoError(t, RotateLogFile())
+	log.Printf("I! TEST")
+
+	f, err := ioutil.ReadFile(logfile)
+	require.NoError(t, err)
+	assert.Contains(t, string(f), "I! TEST")
+}
+
+func TestEnableDebugFor(t *testing.T) {
+	SetupLogging(LogConfig{LogTarget: "stderr", Quiet: true})
+	defer SetupLogging(LogConfig{LogTarget: "stderr", Quiet: true})
+
+	EnableDebugFor(time.Hour)
+	assert.Equal(t, wlog.DEBUG, wlog.LogLevel())
+
+	// the second call sets a new end to debug logging
+	EnableDebugFor(10 * time.Millisecond)
+	assert.Equal(t, wlog.DEBUG, wlog.LogLevel())
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, wlog.ERROR, wlog.LogLevel())
+}
+
 func TestLogTargetSettings(t *testing.T) {
 	config := LogConfig{
 		LogTarget: "",
@@ -0,0 +1,121 @@
+// +build !solaris
+
+package tail
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+var (
+	dedupWindows      = make(map[string]*dedupWindow)
+	dedupWindowsMutex = new(sync.Mutex)
+)
+
+// lineKey identifies a line read from a file: the offset of the line, or -1
+// when it is not known, and the hash of its text.
+type lineKey struct {
+	offset int64
+	sum    uint64
+}
+
+// dedupWindow remembers the last lines read from a file, to drop them when
+// they are read again, such as when the file is read from the beginning
+// after a reload.
+type dedupWindow struct {
+	sync.Mutex
+	// keys are the last lines read, a ring whose oldest line is at next
+	keys []lineKey
+	next int
+	seen map[lineKey]bool
+	// resume is the offset the file is resumed from once its reading is
+	// stopped, from which lines are no longer remembered, or -1
+	resume int64
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	return &dedupWindow{
+		keys:   make([]lineKey, 0, size),
+		seen:   make(map[lineKey]bool, size),
+		resume: -1,
+	}
+}
+
+// open starts reading the file again.  A file opened again by its tailer,
+// such as after rotation, is a new file whose lines are not duplicates.
+func (w *dedupWindow) open(reopen bool) {
+	w.Lock()
+	defer w.Unlock()
+	w.resume = -1
+	if reopen {
+		w.keys = w.keys[:0]
+		w.next = 0
+		w.seen = make(map[lineKey]bool, cap(w.keys))
+	}
+}
+
+// add adds a line read at offset, returning false when it is in the window
+// already.
+func (w *dedupWindow) add(offset int64, text string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	key := lineKey{offset: offset, sum: h.Sum64()}
+
+	w.Lock()
+	defer w.Unlock()
+	if w.seen[key] {
+		return false
+	}
+	if w.resume >= 0 && offset >= w.resume {
+		return true
+	}
+
+	if len(w.keys) < cap(w.keys) {
+		w.keys = append(w.keys, key)
+	} else {
+		delete(w.seen, w.keys[w.next])
+		w.keys[w.next] = key
+		w.next = (w.next + 1) % len(w.keys)
+	}
+	w.seen[key] = true
+	return true
+}
+
+// forgetFrom forgets the lines read at offset or after it, which are read
+// again on purpose when the file is resumed from offset, including the
+// lines read until its reading stops.
+func (w *dedupWindow) forgetFrom(offset int64) {
+	w.Lock()
+	defer w.Unlock()
+	w.resume = offset
+
+	// the lines are kept from the oldest to the newest
+	keys := make([]lineKey, 0, cap(w.keys))
+	for i := range w.keys {
+		key := w.keys[(w.next+i)%len(w.keys)]
+		if key.offset >= offset {
+			delete(w.seen, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	w.keys = keys
+	w.next = 0
+}
+
+// dedupWindow returns the window of the lines read from file, with
+// dedup_window, or nil.
+func (t *Tail) dedupWindow(file string) *dedupWindow {
+	if t.DedupWindow <= 0 {
+		return nil
+	}
+
+	t.dedupsMu.Lock()
+	defer t.dedupsMu.Unlock()
+	w, ok := t.dedups[file]
+	if !ok || cap(w.keys) != t.DedupWindow {
+		w = newDedupWindow(t.DedupWindow)
+		t.dedups[file] = w
+	}
+	return w
+}
@@ -0,0 +1,51 @@
+package tail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupWindow(t *testing.T) {
+	w := newDedupWindow(2)
+	require.True(t, w.add(0, "first"))
+	require.True(t, w.add(6, "second"))
+	require.False(t, w.add(0, "first"))
+	// the same text at another offset is another line
+	require.True(t, w.add(13, "first"))
+
+	// the oldest line left the window
+	require.True(t, w.add(0, "first"))
+	require.False(t, w.add(13, "first"))
+}
+
+func TestDedupWindowUnknownOffsets(t *testing.T) {
+	w := newDedupWindow(10)
+	require.True(t, w.add(-1, "first"))
+	require.False(t, w.add(-1, "first"))
+}
+
+func TestDedupWindowForgetFrom(t *testing.T) {
+	w := newDedupWindow(10)
+	require.True(t, w.add(0, "first"))
+	require.True(t, w.add(6, "second"))
+	require.True(t, w.add(13, "third"))
+
+	w.forgetFrom(6)
+	require.False(t, w.add(0, "first"))
+	// the lines read until the reading stops are not remembered either
+	require.True(t, w.add(13, "third"))
+	require.True(t, w.add(13, "third"))
+
+	w.open(false)
+	require.True(t, w.add(6, "second"))
+	require.False(t, w.add(6, "second"))
+	require.False(t, w.add(0, "first"))
+}
+
+func TestDedupWindowReopen(t *testing.T) {
+	w := newDedupWindow(10)
+	require.True(t, w.add(0, "header"))
+	w.open(true)
+	require.True(t, w.add(0, "header"))
+}
@@ -0,0 +1,163 @@
+// +build !solaris
+
+package tail
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/influxdata/tail"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/encoding"
+	textencoding "golang.org/x/text/encoding"
+)
+
+// offsetTracker tracks the offset in a tailed file before which the metrics
+// of every line were delivered, with at_least_once.  Reading the file again
+// from there reads the lines whose metrics may have been lost.
+type offsetTracker struct {
+	sync.Mutex
+	// read is the offset of the first line not parsed yet, or -1 when the
+	// offsets of the lines are not known
+	read int64
+	// pending are the records whose metrics are not delivered yet, in the
+	// order they were read
+	pending []pendingRecord
+}
+
+type pendingRecord struct {
+	id        telegraf.TrackingID
+	offset    int64
+	delivered bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{read: -1}
+}
+
+// reset starts over when the file is opened, with lines read from offset.
+// The records of a rotated file can no longer be read again.
+func (o *offsetTracker) reset(offset int64) {
+	if o == nil {
+		return
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.read = offset
+	o.pending = nil
+}
+
+// setRead sets the offset of the first line not parsed yet, such as the
+// first line of a multiline record that is not complete.
+func (o *offsetTracker) setRead(offset int64) {
+	if o == nil {
+		return
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.read = offset
+}
+
+// add adds a record starting at offset whose metrics are not delivered
+// yet.  A record with no tracking id is never delivered, as its metrics
+// were added without tracking.
+func (o *offsetTracker) add(id telegraf.TrackingID, offset int64) {
+	if o == nil || offset < 0 {
+		return
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.pending = append(o.pending, pendingRecord{id: id, offset: offset})
+}
+
+// deliver records that the metrics of a record were delivered.
+func (o *offsetTracker) deliver(id telegraf.TrackingID) {
+	o.Lock()
+	defer o.Unlock()
+	for i := range o.pending {
+		if o.pending[i].id == id {
+			o.pending[i].delivered = true
+			break
+		}
+	}
+	for len(o.pending) > 0 && o.pending[0].delivered {
+		o.pending = o.pending[1:]
+	}
+}
+
+// offset returns the offset before which the metrics of every line were
+// delivered, if known.
+func (o *offsetTracker) offset() (int64, bool) {
+	o.Lock()
+	defer o.Unlock()
+	if o.read < 0 {
+		return 0, false
+	}
+	if len(o.pending) > 0 && o.pending[0].offset < o.read {
+		return o.pending[0].offset, true
+	}
+	return o.read, true
+}
+
+// trackDelivery adds the metrics of a record starting at offset with
+// tracking, so that ot knows when they are delivered.
+func (t *Tail) trackDelivery(metrics []telegraf.Metric, ot *offsetTracker, offset int64) {
+	// the record is added to ot before its delivery can be received
+	t.deliveriesMu.Lock()
+	defer t.deliveriesMu.Unlock()
+	id := t.tracking.AddTrackingMetricGroup(metrics)
+	if ot != nil {
+		t.deliveries[id] = ot
+		ot.add(id, offset)
+	}
+}
+
+// delivered records that the metrics of a record were delivered.  The
+// metrics rejected by the outputs are not read again either, as they would
+// be rejected again.
+func (t *Tail) delivered(id telegraf.TrackingID) {
+	t.deliveriesMu.Lock()
+	ot, ok := t.deliveries[id]
+	delete(t.deliveries, id)
+	t.deliveriesMu.Unlock()
+	if ok {
+		ot.deliver(id)
+	}
+}
+
+// deliveredOffset returns the offset in the file of tailer before which the
+// metrics of every line were delivered, with at_least_once and when known.
+func (t *Tail) deliveredOffset(tailer *tail.Tail) (int64, bool) {
+	t.deliveriesMu.Lock()
+	ot, ok := t.offsetTrackers[tailer]
+	t.deliveriesMu.Unlock()
+	if !ok {
+		return 0, false
+	}
+	return ot.offset()
+}
+
+// linesOffset returns the offset in the file r of the lines read from it,
+// where its tailer opened and seeked it, or -1 when it is not known.  The
+// lines of a file in another encoding than UTF-8 are decoded to another
+// size, so their offsets are not known.
+func linesOffset(r io.Reader, enc textencoding.Encoding, head func() []byte) int64 {
+	f, ok := r.(*os.File)
+	if !ok || enc != nil {
+		return -1
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	if offset == 0 {
+		// the byte order mark is stripped from the first line
+		_, bomEnc, size := encoding.BOM(head())
+		if bomEnc != nil {
+			return -1
+		}
+		offset = int64(size)
+	}
+	return offset
+}
@@ -0,0 +1,53 @@
+package tail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	ot := newOffsetTracker()
+	_, ok := ot.offset()
+	require.False(t, ok)
+
+	ot.reset(100)
+	ot.add(1, 100)
+	ot.add(2, 110)
+	ot.add(3, 120)
+	ot.setRead(130)
+	offset, ok := ot.offset()
+	require.True(t, ok)
+	require.Equal(t, int64(100), offset)
+
+	// records delivered out of order
+	ot.deliver(2)
+	offset, _ = ot.offset()
+	require.Equal(t, int64(100), offset)
+	ot.deliver(1)
+	offset, _ = ot.offset()
+	require.Equal(t, int64(120), offset)
+	ot.deliver(3)
+	offset, _ = ot.offset()
+	require.Equal(t, int64(130), offset)
+
+	// a record added without tracking is never delivered
+	ot.add(0, 130)
+	ot.setRead(140)
+	offset, _ = ot.offset()
+	require.Equal(t, int64(130), offset)
+
+	// the records of a rotated file are forgotten
+	ot.reset(0)
+	offset, _ = ot.offset()
+	require.Equal(t, int64(0), offset)
+}
+
+func TestOffsetTrackerUnknownOffsets(t *testing.T) {
+	ot := newOffsetTracker()
+	ot.reset(-1)
+	ot.add(1, -1)
+	ot.setRead(-1)
+	_, ok := ot.offset()
+	require.False(t, ok)
+}
@@ -0,0 +1,44 @@
+package tail
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// dockerLogLine is a line of a log of Docker's json-file logging driver.
+type dockerLogLine struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+// unwrapDockerLog returns the record of a line of a Docker json-file log:
+// the log text, with the stream as a tag and the time as a field.  Docker
+// splits long lines into partial entries not ending with a newline, which
+// are kept in partial until the entry completing them; ok is false then.
+func unwrapDockerLog(text string, partial *strings.Builder) (rec record, ok bool, err error) {
+	var line dockerLogLine
+	if err := json.Unmarshal([]byte(text), &line); err != nil {
+		return record{}, false, err
+	}
+
+	if !strings.HasSuffix(line.Log, "\n") {
+		partial.WriteString(line.Log)
+		return record{}, false, nil
+	}
+
+	log := strings.TrimSuffix(line.Log, "\n")
+	if partial.Len() > 0 {
+		log = partial.String() + log
+		partial.Reset()
+	}
+
+	rec = record{text: strings.TrimRight(log, "\r")}
+	if line.Stream != "" {
+		rec.tags = map[string]string{"stream": line.Stream}
+	}
+	if line.Time != "" {
+		rec.fields = map[string]interface{}{"time": line.Time}
+	}
+	return rec, true, nil
+}
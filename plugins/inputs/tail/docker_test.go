@@ -0,0 +1,76 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnwrapDockerLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []record
+	}{
+		{
+			name: "lines",
+			lines: []string{
+				`{"log":"GET /\n","stream":"stdout","time":"2020-07-01T12:00:00.1Z"}`,
+				`{"log":"oops\r\n","stream":"stderr","time":"2020-07-01T12:00:00.2Z"}`,
+			},
+			expected: []record{
+				{
+					text:   "GET /",
+					tags:   map[string]string{"stream": "stdout"},
+					fields: map[string]interface{}{"time": "2020-07-01T12:00:00.1Z"},
+				},
+				{
+					text:   "oops",
+					tags:   map[string]string{"stream": "stderr"},
+					fields: map[string]interface{}{"time": "2020-07-01T12:00:00.2Z"},
+				},
+			},
+		},
+		{
+			name: "partial entries",
+			lines: []string{
+				`{"log":"a long ","stream":"stdout","time":"2020-07-01T12:00:00.1Z"}`,
+				`{"log":"line","stream":"stdout","time":"2020-07-01T12:00:00.2Z"}`,
+				`{"log":"\n","stream":"stdout","time":"2020-07-01T12:00:00.3Z"}`,
+			},
+			expected: []record{
+				{
+					text:   "a long line",
+					tags:   map[string]string{"stream": "stdout"},
+					fields: map[string]interface{}{"time": "2020-07-01T12:00:00.3Z"},
+				},
+			},
+		},
+		{
+			name:     "log only",
+			lines:    []string{`{"log":"hello\n"}`},
+			expected: []record{{text: "hello"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var partial strings.Builder
+			var records []record
+			for _, line := range tt.lines {
+				rec, ok, err := unwrapDockerLog(line, &partial)
+				require.NoError(t, err)
+				if ok {
+					records = append(records, rec)
+				}
+			}
+			require.Equal(t, tt.expected, records)
+		})
+	}
+}
+
+func TestUnwrapDockerLogInvalid(t *testing.T) {
+	var partial strings.Builder
+	_, _, err := unwrapDockerLog("GET /", &partial)
+	require.Error(t, err)
+}
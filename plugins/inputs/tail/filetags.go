@@ -0,0 +1,78 @@
+// +build !solaris
+
+package tail
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+)
+
+// Tags of file_tags.
+const (
+	fileTagOwner      = "owner"
+	fileTagGroup      = "group"
+	fileTagGeneration = "generation"
+)
+
+// generationRe matches the number of the rotation generation at the end of
+// the name of a rotated file, such as app.log.1 or app.log.2.gz.
+var generationRe = regexp.MustCompile(`\.(\d+)(\.gz)?$`)
+
+// checkFileTags returns an error for the tags of file_tags that are not
+// known.
+func checkFileTags(tags []string) error {
+	for _, tag := range tags {
+		switch tag {
+		case fileTagOwner, fileTagGroup, fileTagGeneration:
+		default:
+			return fmt.Errorf("invalid file_tags %q, expected %q, %q or %q",
+				tag, fileTagOwner, fileTagGroup, fileTagGeneration)
+		}
+	}
+	return nil
+}
+
+// fileTags returns the file_tags of a file.  The owner and the group are
+// names when they can be looked up, and ids otherwise; they are left out on
+// Windows and when the file cannot be read.
+func (t *Tail) fileTags(filename string) map[string]string {
+	if len(t.FileTags) == 0 || filename == stdinFile {
+		return nil
+	}
+
+	var uid, gid string
+	var owned bool
+	if info, err := os.Stat(filename); err == nil {
+		uid, gid, owned = statOwner(info.Sys())
+	}
+
+	tags := make(map[string]string, len(t.FileTags))
+	for _, tag := range t.FileTags {
+		switch tag {
+		case fileTagOwner:
+			if !owned {
+				continue
+			}
+			tags[tag] = uid
+			if u, err := user.LookupId(uid); err == nil {
+				tags[tag] = u.Username
+			}
+		case fileTagGroup:
+			if !owned {
+				continue
+			}
+			tags[tag] = gid
+			if g, err := user.LookupGroupId(gid); err == nil {
+				tags[tag] = g.Name
+			}
+		case fileTagGeneration:
+			tags[tag] = "0"
+			if match := generationRe.FindStringSubmatch(filename); match != nil {
+				tags[tag] = match[1]
+			}
+		}
+	}
+	return tags
+}
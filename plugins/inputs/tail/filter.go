@@ -0,0 +1,90 @@
+package tail
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Actions on the lines longer than max_line_bytes.
+const (
+	maxLineTruncate = "truncate"
+	maxLineDrop     = "drop"
+)
+
+// LineFilters is the configuration of the lines dropped before they are
+// joined into multiline records and parsed.
+type LineFilters struct {
+	// Include, when set, keeps only the lines matching one of its patterns.
+	Include []string `toml:"include"`
+	// Exclude drops the lines matching one of its patterns.
+	Exclude []string `toml:"exclude"`
+}
+
+// lineFilter drops lines according to LineFilters.
+type lineFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// newLineFilter returns the filter of a config, or nil when no pattern is
+// set and every line is kept.
+func (c *LineFilters) newLineFilter() (*lineFilter, error) {
+	if len(c.Include) == 0 && len(c.Exclude) == 0 {
+		return nil, nil
+	}
+
+	f := &lineFilter{}
+	var err error
+	f.include, err = compilePatterns(c.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid line_filters include: %s", err)
+	}
+	f.exclude, err = compilePatterns(c.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid line_filters exclude: %s", err)
+	}
+	return f, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// keep returns whether a line is kept.
+func (f *lineFilter) keep(text string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, text) {
+		return false
+	}
+	return !matchAny(f.exclude, text)
+}
+
+func matchAny(res []*regexp.Regexp, text string) bool {
+	for _, re := range res {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateLine returns the first max bytes of text, without splitting a
+// UTF-8 character.
+func truncateLine(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	i := max
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return text[:i]
+}
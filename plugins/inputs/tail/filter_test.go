@@ -0,0 +1,88 @@
+package tail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   LineFilters
+		expected []string
+	}{
+		{
+			name:     "include",
+			config:   LineFilters{Include: []string{"ERROR", "WARN"}},
+			expected: []string{"ERROR disk full", "WARN GET /health slow"},
+		},
+		{
+			name:     "exclude",
+			config:   LineFilters{Exclude: []string{"^DEBUG", "GET /health"}},
+			expected: []string{"INFO started", "ERROR disk full"},
+		},
+		{
+			name: "include and exclude",
+			config: LineFilters{
+				Include: []string{"ERROR", "WARN"},
+				Exclude: []string{"GET /health"},
+			},
+			expected: []string{"ERROR disk full"},
+		},
+	}
+	lines := []string{
+		"DEBUG connecting",
+		"INFO started",
+		"ERROR disk full",
+		"WARN GET /health slow",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := tt.config.newLineFilter()
+			require.NoError(t, err)
+
+			var kept []string
+			for _, line := range lines {
+				if f.keep(line) {
+					kept = append(kept, line)
+				}
+			}
+			require.Equal(t, tt.expected, kept)
+		})
+	}
+}
+
+func TestLineFilterNone(t *testing.T) {
+	f, err := (&LineFilters{}).newLineFilter()
+	require.NoError(t, err)
+	require.Nil(t, f)
+}
+
+func TestLineFilterInvalid(t *testing.T) {
+	for _, config := range []LineFilters{
+		{Include: []string{"("}},
+		{Exclude: []string{"("}},
+	} {
+		_, err := config.newLineFilter()
+		require.Error(t, err)
+	}
+}
+
+func TestTruncateLine(t *testing.T) {
+	tests := []struct {
+		text     string
+		max      int
+		expected string
+	}{
+		{text: "short", max: 10, expected: "short"},
+		{text: "exactly", max: 7, expected: "exactly"},
+		{text: "too long", max: 3, expected: "too"},
+		// the 3 bytes of 日 are not split
+		{text: "a日本", max: 3, expected: "a"},
+		{text: "a日本", max: 4, expected: "a日"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, truncateLine(tt.text, tt.max))
+	}
+}
@@ -0,0 +1,83 @@
+// +build !solaris
+
+package tail
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/influxdata/telegraf/internal/globpath"
+	"github.com/influxdata/telegraf/plugins/parsers"
+)
+
+// FileFormat is the data format of the files matched by globs, replacing
+// the data_format of the plugin.
+type FileFormat struct {
+	Files      []string `toml:"files"`
+	DataFormat string   `toml:"data_format"`
+}
+
+type fileFormat struct {
+	globs      []*globpath.GlobPath
+	dataFormat string
+}
+
+func (t *Tail) compileFileFormats() ([]fileFormat, error) {
+	if len(t.FileFormats) == 0 {
+		return nil, nil
+	}
+	if t.parserConfig == nil {
+		return nil, errors.New("file_format requires the parser config of the plugin")
+	}
+
+	formats := make([]fileFormat, 0, len(t.FileFormats))
+	for _, ff := range t.FileFormats {
+		if ff.DataFormat == "" {
+			return nil, fmt.Errorf("file_format for %q has no data_format", ff.Files)
+		}
+		format := fileFormat{dataFormat: ff.DataFormat}
+		for _, file := range ff.Files {
+			g, err := globpath.Compile(file)
+			if err != nil {
+				return nil, fmt.Errorf("glob %q failed to compile: %s", file, err)
+			}
+			format.globs = append(format.globs, g)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// dataFormat returns the data format of file, when known.
+func (t *Tail) dataFormat(file string) string {
+	if format, ok := t.fileFormat(file); ok {
+		return format.dataFormat
+	}
+	if t.parserConfig != nil {
+		return t.parserConfig.DataFormat
+	}
+	return ""
+}
+
+// fileFormat returns the first file_format matching file.
+func (t *Tail) fileFormat(file string) (fileFormat, bool) {
+	for _, format := range t.fileFormats {
+		for _, g := range format.globs {
+			if g.MatchString(file) {
+				return format, true
+			}
+		}
+	}
+	return fileFormat{}, false
+}
+
+// newParser returns a parser of the data format of the first file_format
+// matching file, or of data_format.
+func (t *Tail) newParser(file string) (parsers.Parser, error) {
+	if format, ok := t.fileFormat(file); ok {
+		config := *t.parserConfig
+		config.DataFormat = format.dataFormat
+		return parsers.NewParser(&config)
+	}
+	return t.parserFunc()
+}
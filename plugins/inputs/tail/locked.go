@@ -0,0 +1,62 @@
+// +build !solaris
+
+package tail
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Wait before opening again a file locked by another process, doubled at
+// each attempt.
+const (
+	lockedRetryInitial = time.Second
+	lockedRetryMax     = time.Minute
+)
+
+// lockedFile is a file that could not be opened as another process locked
+// it, such as a log opened without sharing on Windows.
+type lockedFile struct {
+	attempts int
+	// next is when the file is opened again
+	next time.Time
+	// fromBeginning is whether the file is read from the beginning once
+	// opened, as when it was first found
+	fromBeginning bool
+}
+
+// retryLocked records that file, to be read from the beginning or not,
+// could not be opened as another process locked it, and opens it again
+// after a backoff.  The attempts are counted in the files_locked field of
+// internal_tail.
+func (t *Tail) retryLocked(file string, fromBeginning bool) {
+	l, ok := t.locked[file]
+	if !ok {
+		l = &lockedFile{fromBeginning: fromBeginning}
+		t.locked[file] = l
+	}
+
+	wait := lockedRetryInitial << uint(l.attempts)
+	if wait > lockedRetryMax || wait <= 0 {
+		wait = lockedRetryMax
+	}
+	l.attempts++
+	l.next = time.Now().Add(wait)
+
+	t.Log.Warnf("%q is locked by another process, opening it again in %s", file, wait)
+	selfstat.Register("tail", "files_locked", map[string]string{"path": file}).Incr(1)
+
+	time.AfterFunc(wait, func() {
+		t.Lock()
+		defer t.Unlock()
+		select {
+		case <-t.done:
+			return
+		default:
+		}
+		if err := t.tailNewFiles(true); err != nil {
+			t.acc.AddError(err)
+		}
+	})
+}
@@ -0,0 +1,9 @@
+// +build !windows
+
+package tail
+
+// isLocked returns whether err is the error opening a file locked by
+// another process, which only Windows locks.
+func isLocked(_ error) bool {
+	return false
+}
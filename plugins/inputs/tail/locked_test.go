@@ -0,0 +1,35 @@
+package tail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryLocked(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	plugin.Lock()
+	defer plugin.Unlock()
+	start := time.Now()
+	plugin.retryLocked("app.log", true)
+	plugin.retryLocked("app.log", false)
+
+	l := plugin.locked["app.log"]
+	require.Equal(t, 2, l.attempts)
+	require.True(t, l.fromBeginning)
+	require.False(t, l.next.Before(start.Add(2*lockedRetryInitial)))
+
+	for i := 0; i < 10; i++ {
+		plugin.retryLocked("app.log", true)
+	}
+	require.False(t, l.next.After(time.Now().Add(lockedRetryMax)))
+}
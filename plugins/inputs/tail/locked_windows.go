@@ -0,0 +1,23 @@
+// +build windows
+
+package tail
+
+import (
+	"os"
+	"syscall"
+)
+
+// Errors opening a file another process opened without sharing it.
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+// isLocked returns whether err is the error opening a file locked by
+// another process.
+func isLocked(err error) bool {
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	return err == errorSharingViolation || err == errorLockViolation
+}
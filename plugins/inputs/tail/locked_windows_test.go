@@ -0,0 +1,18 @@
+// +build windows
+
+package tail
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLocked(t *testing.T) {
+	require.True(t, isLocked(&os.PathError{Op: "open", Path: "app.log", Err: errorSharingViolation}))
+	require.True(t, isLocked(errorLockViolation))
+	require.False(t, isLocked(&os.PathError{Op: "open", Path: "app.log", Err: os.ErrNotExist}))
+	require.False(t, isLocked(errors.New("locked")))
+}
@@ -0,0 +1,201 @@
+package tail
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+const (
+	matchPreviousLine = "previous"
+	matchNextLine     = "next"
+
+	defaultMultilineTimeout  = 5 * time.Second
+	defaultMultilineMaxLines = 1000
+	defaultMultilineMaxBytes = 1024 * 1024
+	defaultMultilineJoinWith = " "
+)
+
+// MultilineConfig configures how lines are joined into records spanning
+// several lines, such as stack traces.
+type MultilineConfig struct {
+	// Pattern matches the lines belonging to a neighbouring line.
+	Pattern string `toml:"pattern"`
+	// EndPattern matches the last line of a record.
+	EndPattern string `toml:"end_pattern"`
+	// MatchWhichLine is the line a matching line belongs to: "previous" or
+	// "next".
+	MatchWhichLine string `toml:"match_which_line"`
+	// InvertMatch makes the lines that do not match the pattern belong to
+	// the neighbouring line.
+	InvertMatch bool `toml:"invert_match"`
+	// Timeout is how long a record waits for more lines before it is
+	// parsed.
+	Timeout internal.Duration `toml:"timeout"`
+	// MaxLines and MaxBytes limit the size of a record; larger records are
+	// parsed early and tagged as truncated.  Zero is no limit.
+	MaxLines int           `toml:"max_lines"`
+	MaxBytes internal.Size `toml:"max_bytes"`
+	// JoinWith separates the lines of a record.
+	JoinWith string `toml:"join_with"`
+}
+
+// multiline joins lines into records.  The buffer holding the lines of the
+// current record belongs to the caller, one per file.
+type multiline struct {
+	config     *MultilineConfig
+	pattern    *regexp.Regexp
+	endPattern *regexp.Regexp
+}
+
+// recordBuffer holds the lines of the record being read from a file.
+type recordBuffer struct {
+	bytes.Buffer
+	lines int
+	// first is the first line of the record, giving its tags and fields
+	first record
+	// truncated is set when a line of the record was truncated
+	truncated bool
+}
+
+// record is a line, or the joined lines of a multiline record.
+type record struct {
+	text string
+	// truncated is set when the record reached max_lines or max_bytes
+	// before it was complete, or when a line reached max_line_bytes.
+	truncated bool
+	// tags and fields are added to the metrics of the record, such as the
+	// stream and time of Docker logs.
+	tags   map[string]string
+	fields map[string]interface{}
+	// offset is the offset in the file of the first line of the record,
+	// when tracked with at_least_once.
+	offset int64
+}
+
+// newMultiline returns the multiline handling of a config, or nil when no
+// pattern is set and every line is a record.
+func (c *MultilineConfig) newMultiline() (*multiline, error) {
+	if c.Pattern == "" && c.EndPattern == "" {
+		return nil, nil
+	}
+
+	m := &multiline{config: c}
+	var err error
+	if c.Pattern != "" {
+		m.pattern, err = regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiline pattern: %s", err)
+		}
+	}
+	if c.EndPattern != "" {
+		m.endPattern, err = regexp.Compile(c.EndPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiline end_pattern: %s", err)
+		}
+	}
+
+	switch c.MatchWhichLine {
+	case "":
+		c.MatchWhichLine = matchPreviousLine
+	case matchPreviousLine, matchNextLine:
+	default:
+		return nil, fmt.Errorf("invalid multiline match_which_line %q, expected %q or %q",
+			c.MatchWhichLine, matchPreviousLine, matchNextLine)
+	}
+
+	if c.Timeout.Duration <= 0 {
+		c.Timeout.Duration = defaultMultilineTimeout
+	}
+
+	return m, nil
+}
+
+// matches returns whether a line belongs to a neighbouring line according
+// to pattern.
+func (m *multiline) matches(text string) bool {
+	return m.pattern.MatchString(text) != m.config.InvertMatch
+}
+
+// startsRecord returns whether a line starts a new record.
+func (m *multiline) startsRecord(text string) bool {
+	return m.pattern != nil && m.config.MatchWhichLine == matchPreviousLine && !m.matches(text)
+}
+
+// endsRecord returns whether a line is the last of its record.
+func (m *multiline) endsRecord(text string) bool {
+	if m.endPattern != nil && m.endPattern.MatchString(text) {
+		return true
+	}
+	return m.pattern != nil && m.config.MatchWhichLine == matchNextLine && !m.matches(text)
+}
+
+// processLine adds a line to the record in buffer, returning the records
+// it completes.  A record reaching max_lines or max_bytes is returned as
+// truncated, and the lines that follow start a new record.
+func (m *multiline) processLine(line record, buffer *recordBuffer) []record {
+	var records []record
+	if m.startsRecord(line.text) {
+		if rec, ok := m.flush(buffer); ok {
+			records = append(records, rec)
+		}
+	}
+
+	m.append(line, buffer)
+	if m.endsRecord(line.text) {
+		if rec, ok := m.flush(buffer); ok {
+			records = append(records, rec)
+		}
+	} else if rec, ok := m.flushFull(buffer); ok {
+		records = append(records, rec)
+	}
+	return records
+}
+
+func (m *multiline) append(line record, buffer *recordBuffer) {
+	if buffer.lines == 0 {
+		buffer.first = line
+	}
+	if buffer.Len() > 0 {
+		buffer.WriteString(m.config.JoinWith)
+	}
+	buffer.WriteString(line.text)
+	buffer.lines++
+	buffer.truncated = buffer.truncated || line.truncated
+}
+
+// flushFull returns the record in buffer as truncated when it reached
+// max_lines or max_bytes.
+func (m *multiline) flushFull(buffer *recordBuffer) (record, bool) {
+	full := (m.config.MaxLines > 0 && buffer.lines >= m.config.MaxLines) ||
+		(m.config.MaxBytes.Size > 0 && int64(buffer.Len()) >= m.config.MaxBytes.Size)
+	if !full {
+		return record{}, false
+	}
+
+	rec, ok := m.flush(buffer)
+	rec.truncated = true
+	return rec, ok
+}
+
+// flush returns the record in buffer, if any, and empties the buffer.
+func (m *multiline) flush(buffer *recordBuffer) (record, bool) {
+	if buffer.Len() == 0 {
+		return record{}, false
+	}
+	rec := record{
+		text:      buffer.String(),
+		truncated: buffer.truncated,
+		tags:      buffer.first.tags,
+		fields:    buffer.first.fields,
+		offset:    buffer.first.offset,
+	}
+	buffer.Reset()
+	buffer.lines = 0
+	buffer.first = record{}
+	buffer.truncated = false
+	return rec, true
+}
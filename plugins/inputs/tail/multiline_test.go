@@ -0,0 +1,173 @@
+package tail
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultilineProcessLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   MultilineConfig
+		lines    []string
+		expected []string
+	}{
+		{
+			name:   "previous",
+			config: MultilineConfig{Pattern: `^\s`, MatchWhichLine: "previous", JoinWith: " "},
+			lines: []string{
+				"panic: oops",
+				"  at main.go:1",
+				"  at main.go:2",
+				"done",
+			},
+			expected: []string{"panic: oops   at main.go:1   at main.go:2", "done"},
+		},
+		{
+			name:   "next",
+			config: MultilineConfig{Pattern: `\\$`, MatchWhichLine: "next", JoinWith: " "},
+			lines: []string{
+				"one \\",
+				"two",
+				"three",
+			},
+			expected: []string{"one \\ two", "three"},
+		},
+		{
+			name:   "invert match",
+			config: MultilineConfig{Pattern: `^\d{4}-\d{2}-\d{2}`, MatchWhichLine: "previous", InvertMatch: true, JoinWith: " "},
+			lines: []string{
+				"2020-01-01 first",
+				"continued",
+				"2020-01-02 second",
+			},
+			expected: []string{"2020-01-01 first continued", "2020-01-02 second"},
+		},
+		{
+			name:   "join with newline",
+			config: MultilineConfig{Pattern: `^\s`, JoinWith: "\n"},
+			lines: []string{
+				"panic: oops",
+				"  at main.go:1",
+			},
+			expected: []string{"panic: oops\n  at main.go:1"},
+		},
+		{
+			name:   "end pattern",
+			config: MultilineConfig{EndPattern: `^END`, JoinWith: " "},
+			lines: []string{
+				"BEGIN",
+				"update",
+				"END",
+				"BEGIN",
+				"rollback",
+			},
+			expected: []string{"BEGIN update END", "BEGIN rollback"},
+		},
+		{
+			name:   "end pattern with pattern",
+			config: MultilineConfig{Pattern: `^\s`, EndPattern: `^\s+done`, JoinWith: " "},
+			lines: []string{
+				"job",
+				"  step",
+				"  done",
+				"  stray",
+				"next",
+			},
+			expected: []string{"job   step   done", "  stray", "next"},
+		},
+		{
+			name:   "continuation first",
+			config: MultilineConfig{Pattern: `^\s`, JoinWith: " "},
+			lines: []string{
+				"  orphan",
+				"start",
+			},
+			expected: []string{"  orphan", "start"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.config.newMultiline()
+			require.NoError(t, err)
+
+			var buffer recordBuffer
+			var records []string
+			for _, line := range tt.lines {
+				for _, rec := range m.processLine(record{text: line}, &buffer) {
+					require.False(t, rec.truncated)
+					records = append(records, rec.text)
+				}
+			}
+			if rec, ok := m.flush(&buffer); ok {
+				records = append(records, rec.text)
+			}
+			require.Equal(t, tt.expected, records)
+		})
+	}
+}
+
+func TestMultilineMaxSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   MultilineConfig
+		expected []record
+	}{
+		{
+			name:   "max lines",
+			config: MultilineConfig{Pattern: `^\s`, MaxLines: 2, JoinWith: " "},
+			expected: []record{
+				{text: "start   a", truncated: true},
+				{text: "  b   c", truncated: true},
+				{text: "end"},
+			},
+		},
+		{
+			name:   "max bytes",
+			config: MultilineConfig{Pattern: `^\s`, MaxBytes: internal.Size{Size: 10}, JoinWith: " "},
+			expected: []record{
+				{text: "start   a   b", truncated: true},
+				{text: "  c"},
+				{text: "end"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.config.newMultiline()
+			require.NoError(t, err)
+
+			var buffer recordBuffer
+			var records []record
+			for _, line := range []string{"start", "  a", "  b", "  c", "end"} {
+				records = append(records, m.processLine(record{text: line}, &buffer)...)
+			}
+			if rec, ok := m.flush(&buffer); ok {
+				records = append(records, rec)
+			}
+			require.Equal(t, tt.expected, records)
+		})
+	}
+}
+
+func TestMultilineDisabled(t *testing.T) {
+	config := MultilineConfig{}
+	m, err := config.newMultiline()
+	require.NoError(t, err)
+	require.Nil(t, m)
+}
+
+func TestMultilineInvalid(t *testing.T) {
+	for _, config := range []MultilineConfig{
+		{Pattern: "("},
+		{EndPattern: "("},
+		{Pattern: `^\s`, MatchWhichLine: "both"},
+	} {
+		_, err := config.newMultiline()
+		require.Error(t, err)
+	}
+}
@@ -0,0 +1,148 @@
+// +build !solaris
+
+package tail
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+
+	"github.com/influxdata/tail"
+)
+
+// fingerprintSize is the number of bytes at the beginning of a file that
+// are checked to recognize it.
+const fingerprintSize = 1024
+
+// fileOffset is the offset reached in a file, along with what identifies
+// the file, so that reading resumes in the same file after it was renamed,
+// but not in a new file that took its name.
+type fileOffset struct {
+	offset int64
+	info   os.FileInfo
+	// sum is the checksum of the first n bytes of the file, which catches
+	// files truncated in place and new files reusing the inode of a removed
+	// one.  Only bytes that were read are used, as they no longer change.
+	sum [sha256.Size]byte
+	n   int64
+}
+
+func newFileOffset(path string, offset int64) (fileOffset, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileOffset{}, err
+	}
+
+	n := offset
+	if n > fingerprintSize {
+		n = fingerprintSize
+	}
+	sum, err := checksum(path, n)
+	if err != nil {
+		return fileOffset{}, err
+	}
+
+	return fileOffset{offset: offset, info: info, sum: sum, n: n}, nil
+}
+
+// matches returns whether the file at path, with info, is the file the
+// offset was reached in.
+func (o fileOffset) matches(path string, info os.FileInfo) bool {
+	if !os.SameFile(o.info, info) || info.Size() < o.offset {
+		return false
+	}
+	sum, err := checksum(path, o.n)
+	return err == nil && sum == o.sum
+}
+
+// checksum returns the checksum of the first n bytes of the file at path.
+func checksum(path string, n int64) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, n); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// findOffset returns the offset recorded for file, even under another name,
+// and the name it was recorded for.
+func (t *Tail) findOffset(file string) (fileOffset, string, bool) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileOffset{}, "", false
+	}
+	for path, o := range t.offsets {
+		if o.matches(file, info) {
+			return o, path, true
+		}
+	}
+	return fileOffset{}, "", false
+}
+
+// pruneOffsets forgets the offsets recorded for files that are not among
+// files, such as deleted files, so that they do not pile up.
+func (t *Tail) pruneOffsets(files map[string]bool) {
+	if len(t.offsets) == 0 {
+		return
+	}
+
+	infos := make([]os.FileInfo, 0, len(files))
+	for file := range files {
+		if info, err := os.Stat(file); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	for path, o := range t.offsets {
+		found := false
+		for _, info := range infos {
+			if os.SameFile(o.info, info) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Log.Debugf("Forgetting offset of %q, it is no longer matched", path)
+			delete(t.offsets, path)
+		}
+	}
+}
+
+// seekInfo returns where to start reading file when not reading it from the
+// beginning: at the offset recorded for it, even under another name, or at
+// the beginning if another file had its name, as it was rotated.  Other
+// files are read from the end.
+func (t *Tail) seekInfo(file string) *tail.SeekInfo {
+	if o, path, ok := t.findOffset(file); ok {
+		if path != file {
+			t.Log.Debugf("Using offset %d of %q for %q", o.offset, path, file)
+		} else {
+			t.Log.Debugf("Using offset %d for %q", o.offset, file)
+		}
+		return &tail.SeekInfo{
+			Whence: 0,
+			Offset: o.offset,
+		}
+	}
+
+	if _, ok := t.offsets[file]; ok {
+		t.Log.Debugf("Reading %q from the beginning, it was rotated", file)
+		return &tail.SeekInfo{
+			Whence: 0,
+			Offset: 0,
+		}
+	}
+
+	return &tail.SeekInfo{
+		Whence: 2,
+		Offset: 0,
+	}
+}
@@ -0,0 +1,244 @@
+package tail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileOffsetMatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\nsecond\n"), 0644))
+	o, err := newFileOffset(path, 6)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		update  func() error
+		matches bool
+	}{
+		{
+			name: "appended",
+			update: func() error {
+				f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				_, err = f.WriteString("third\n")
+				return err
+			},
+			matches: true,
+		},
+		{
+			name: "rewritten in place",
+			update: func() error {
+				return ioutil.WriteFile(path, []byte("other\nfile\n"), 0644)
+			},
+			matches: false,
+		},
+		{
+			name: "truncated",
+			update: func() error {
+				return os.Truncate(path, 0)
+			},
+			matches: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.update())
+			info, err := os.Stat(path)
+			require.NoError(t, err)
+			require.Equal(t, tt.matches, o.matches(path, info))
+		})
+	}
+}
+
+// tailOffsets starts a tail of files, with offsets recorded for earlier
+// files, and returns the values read.
+func tailOffsets(t *testing.T, files string, offsets map[string]fileOffset) []interface{} {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{files}
+	plugin.offsets = offsets
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	for _, tailer := range plugin.tailers {
+		info, err := os.Stat(tailer.Filename)
+		require.NoError(t, err)
+		for n, err := tailer.Tell(); err == nil && n < info.Size(); n, err = tailer.Tell() {
+			runtime.Gosched()
+		}
+	}
+	plugin.Stop()
+
+	var values []interface{}
+	for _, m := range acc.GetTelegrafMetrics() {
+		values = append(values, m.Fields()["value"])
+	}
+	return values
+}
+
+func TestTailResumesAtOffset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\nsecond\n"), 0644))
+	o, err := newFileOffset(path, 6)
+	require.NoError(t, err)
+
+	values := tailOffsets(t, path, map[string]fileOffset{path: o})
+	require.Equal(t, []interface{}{"second"}, values)
+}
+
+func TestTailResumesRenamedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\nsecond\n"), 0644))
+	o, err := newFileOffset(path, 6)
+	require.NoError(t, err)
+
+	// the file is renamed by log rotation, and a new one takes its name
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, ioutil.WriteFile(path, []byte("third\n"), 0644))
+
+	values := tailOffsets(t, path+"*", map[string]fileOffset{path: o})
+	require.ElementsMatch(t, []interface{}{"second", "third"}, values)
+}
+
+func TestTailReadsRotatedFileFromBeginning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\nsecond\n"), 0644))
+	o, err := newFileOffset(path, 6)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, ioutil.WriteFile(path, []byte("third\nfourth\n"), 0644))
+
+	values := tailOffsets(t, path, map[string]fileOffset{path: o})
+	require.Equal(t, []interface{}{"third", "fourth"}, values)
+}
+
+func TestTailNewFileFromEnd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\nsecond\n"), 0644))
+
+	values := tailOffsets(t, path, map[string]fileOffset{})
+	require.Empty(t, values)
+}
+
+func TestTailResumesLargeFile(t *testing.T) {
+	utf16le := func(s string) string {
+		var b strings.Builder
+		for _, c := range []byte(s) {
+			b.WriteByte(c)
+			b.WriteByte(0)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		encode   func(string) string
+	}{
+		{
+			name:   "utf-8",
+			encode: func(s string) string { return s },
+		},
+		{
+			name:     "utf-16le",
+			encoding: "utf-16le",
+			encode:   utf16le,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "tail")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			// lines of 100 characters, far more than the readers buffer
+			const lines = 1000
+			var content strings.Builder
+			for i := 0; i < lines; i++ {
+				content.WriteString(tt.encode(fmt.Sprintf("%099d\n", i)))
+			}
+			lineSize := int64(content.Len() / lines)
+			path := filepath.Join(dir, "test.log")
+			require.NoError(t, ioutil.WriteFile(path, []byte(content.String()), 0644))
+			o, err := newFileOffset(path, 0)
+			require.NoError(t, err)
+
+			tailFile := func(offsets map[string]fileOffset, n int) (map[string]fileOffset, []interface{}) {
+				plugin := NewTail()
+				plugin.Log = testutil.Logger{}
+				plugin.Files = []string{path}
+				plugin.CharacterEncoding = tt.encoding
+				plugin.offsets = offsets
+				plugin.SetParserFunc(newStringParser)
+
+				acc := testutil.Accumulator{}
+				require.NoError(t, plugin.Start(&acc))
+				acc.Wait(n)
+				plugin.Stop()
+
+				var values []interface{}
+				for _, m := range acc.GetTelegrafMetrics() {
+					values = append(values, m.Fields()["value"])
+				}
+				return plugin.offsets, values
+			}
+
+			// stopped while the file is read, the offset is that of a line
+			offsets, values := tailFile(map[string]fileOffset{path: o}, 10)
+			offset := offsets[path].offset
+			require.True(t, offset >= 10*lineSize, "offset %d", offset)
+			require.Zero(t, offset%lineSize, "offset %d", offset)
+			resumed := int(offset / lineSize)
+			require.True(t, len(values) <= resumed)
+
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+			require.NoError(t, err)
+			_, err = f.WriteString(tt.encode("last\n"))
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			var expected []interface{}
+			for i := resumed; i < lines; i++ {
+				expected = append(expected, fmt.Sprintf("%099d", i))
+			}
+			expected = append(expected, "last")
+			_, values = tailFile(offsets, len(expected))
+			require.Equal(t, expected, values)
+		})
+	}
+}
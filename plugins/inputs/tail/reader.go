@@ -0,0 +1,407 @@
+// +build !solaris
+
+package tail
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/influxdata/tail"
+	"github.com/influxdata/telegraf/internal/encoding"
+	textencoding "golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+const (
+	gzipExt = ".gz"
+	// stdinFile in files reads standard input
+	stdinFile = "-"
+
+	// autoEncoding as character_encoding detects the encoding of each file
+	autoEncoding = "auto"
+	// headSize is the number of bytes at the start of a file its encoding
+	// is detected from
+	headSize = 4096
+)
+
+// readGzip decompresses and reads a file once, parsing its lines like the
+// lines of tailed files.
+func (t *Tail) readGzip(file string) {
+	parser, err := t.newParser(file)
+	if err != nil {
+		t.Log.Errorf("Creating parser: %s", err.Error())
+	}
+
+	t.Log.Debugf("Reading compressed file %q", file)
+
+	lines := make(chan *tail.Line)
+	t.wg.Add(2)
+	go func() {
+		defer t.wg.Done()
+		defer close(lines)
+		if err := t.readGzipLines(file, lines); err != nil {
+			t.Log.Errorf("Reading %q: %s", file, err.Error())
+		}
+	}()
+	// a compressed file is caught up with only once it is read
+	th := t.newThrottle(func() bool { return false })
+	go func() {
+		defer t.wg.Done()
+		t.receiver(parser, file, lines, nil, th, nil)
+	}()
+}
+
+func (t *Tail) readGzipLines(file string, lines chan<- *tail.Line) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	r := bufio.NewReaderSize(gz, headSize)
+	head := func() []byte {
+		b, _ := r.Peek(headSize)
+		return b
+	}
+	return t.readLines(t.openReader(r, file, head), lines)
+}
+
+// readStdin reads standard input once, parsing its lines like the lines of
+// tailed files.
+func (t *Tail) readStdin() {
+	parser, err := t.newParser(stdinFile)
+	if err != nil {
+		t.Log.Errorf("Creating parser: %s", err.Error())
+	}
+
+	t.Log.Debugf("Reading standard input")
+
+	// reading standard input cannot be interrupted, so the plugin does not
+	// wait for the reader when it stops, only for the lines to be parsed
+	read := make(chan *tail.Line)
+	go func() {
+		defer close(read)
+		// waiting for headSize bytes would hold the first lines back
+		r := bufio.NewReaderSize(t.stdin, headSize)
+		head := func() []byte {
+			r.Peek(1)
+			b, _ := r.Peek(r.Buffered())
+			return b
+		}
+		if err := t.readLines(t.openReader(r, stdinFile, head), read); err != nil {
+			t.Log.Errorf("Reading standard input: %s", err.Error())
+		}
+	}()
+
+	lines := make(chan *tail.Line)
+	t.wg.Add(2)
+	go func() {
+		defer t.wg.Done()
+		defer close(lines)
+		for {
+			select {
+			case line, ok := <-read:
+				if !ok {
+					return
+				}
+				lines <- line
+			case <-t.done:
+				return
+			}
+		}
+	}()
+	go func() {
+		defer t.wg.Done()
+		t.receiver(parser, stdinFile, lines, nil, nil, nil)
+	}()
+}
+
+// openReader returns the reader of the text of r, read from file, decoded
+// from its encoding.
+func (t *Tail) openReader(r io.Reader, file string, head func() []byte) io.Reader {
+	return newDecodingReader(r, encoding.NewDecoder(t.fileEncoding(file, head)))
+}
+
+// fileEncoding returns the encoding of file, character_encoding or with
+// "auto" the encoding detected from head, the first bytes of the file.
+// UTF-8 is nil.
+func (t *Tail) fileEncoding(file string, head func() []byte) textencoding.Encoding {
+	if t.detector == nil {
+		return t.charset
+	}
+	name, enc := t.detector.Detect(head())
+	t.Log.Debugf("Reading %q as %s", file, name)
+	return enc
+}
+
+// readHead returns the first bytes of a file.
+func readHead(file string) []byte {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	b := make([]byte, headSize)
+	n, _ := io.ReadFull(f, b)
+	return b[:n]
+}
+
+// readLines sends the lines read from r until the end of r, or until the
+// plugin stops.
+func (t *Tail) readLines(r io.Reader, lines chan<- *tail.Line) error {
+	reader := bufio.NewReader(r)
+	for {
+		text, err := reader.ReadString('\n')
+		if text != "" {
+			select {
+			case lines <- tail.NewLine(strings.TrimSuffix(text, "\n")):
+			case <-t.done:
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// eofReader sets eof once the end of r is reached.
+type eofReader struct {
+	r   io.Reader
+	eof *int32
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		atomic.StoreInt32(e.eof, 1)
+	}
+	return n, err
+}
+
+// decodingReader reads the text of r decoded by t.  Unlike transform.Reader
+// it goes on reading r after io.EOF, as tailed files grow.
+type decodingReader struct {
+	r       io.Reader
+	t       transform.Transformer
+	buf     []byte
+	src     []byte // bytes read but not decoded yet
+	dst     []byte // bytes decoded but not returned yet
+	started bool
+}
+
+func newDecodingReader(r io.Reader, t transform.Transformer) *decodingReader {
+	return &decodingReader{r: r, t: t, buf: make([]byte, 4096)}
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	for len(d.dst) == 0 {
+		n, err := d.r.Read(d.buf)
+		d.src = append(d.src, d.buf[:n]...)
+		if derr := d.decode(false); derr != nil {
+			return 0, derr
+		}
+		// the byte order mark is only looked for once a few bytes are read,
+		// which a file of a single short line may never have
+		if err == io.EOF && !d.started {
+			if derr := d.decode(true); derr != nil {
+				return 0, derr
+			}
+		}
+		if len(d.dst) == 0 && err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.dst)
+	d.dst = d.dst[n:]
+	return n, nil
+}
+
+// decode decodes as much of the bytes read as possible, keeping incomplete
+// characters until the rest of them is read.
+func (d *decodingReader) decode(atEOF bool) error {
+	for len(d.src) > 0 {
+		nDst, nSrc, err := d.t.Transform(d.buf, d.src, atEOF)
+		d.dst = append(d.dst, d.buf[:nDst]...)
+		d.src = d.src[nSrc:]
+		if nSrc > 0 {
+			d.started = true
+		}
+		switch err {
+		case nil, transform.ErrShortDst:
+		case transform.ErrShortSrc:
+			return nil
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// readPosition is the offset in a tailed file of the end of the lines read
+// from it, or -1 when the tailer tells it.
+type readPosition struct {
+	offset int64
+}
+
+func newReadPosition() *readPosition {
+	return &readPosition{offset: -1}
+}
+
+func (p *readPosition) set(offset int64) {
+	atomic.StoreInt64(&p.offset, offset)
+}
+
+func (p *readPosition) get() int64 {
+	return atomic.LoadInt64(&p.offset)
+}
+
+// newFileReader returns the reader of the text of a tailed file read from r,
+// at offset start in the file, decoded from the encoding enc.  A byte order
+// mark at the start of the file overrides enc, and is skipped.  Text in
+// UTF-8 is read from r as it is, so that the tailer tells the offset of its
+// lines.  Other text is decoded a line at a time, with the offset of the
+// lines read set in pos.  With start -1, when r is not a file, the offsets
+// are not used and the text is decoded as it is read.
+func newFileReader(r io.Reader, start int64, enc textencoding.Encoding, head func() []byte, pos *readPosition) io.Reader {
+	pos.set(-1)
+	if start < 0 {
+		return newDecodingReader(r, encoding.NewDecoder(enc))
+	}
+
+	_, bomEnc, size := encoding.BOM(head())
+	if size > 0 {
+		enc = bomEnc
+		if start == 0 {
+			io.CopyN(ioutil.Discard, r, int64(size))
+			start = int64(size)
+		}
+	}
+	if enc == nil {
+		return r
+	}
+
+	nl, err := encodedNewline(enc)
+	if err != nil {
+		return newDecodingReader(r, encoding.NewDecoder(enc))
+	}
+	pos.set(start)
+	return &lineDecodingReader{
+		r:   r,
+		t:   enc.NewDecoder(),
+		nl:  nl,
+		buf: make([]byte, 4096),
+		end: start,
+		pos: pos,
+	}
+}
+
+// encodedNewline returns a newline in the encoding enc.  It is told apart
+// from the byte order mark some encoders write first by encoding a second
+// newline.
+func encodedNewline(enc textencoding.Encoding) ([]byte, error) {
+	one, err := enc.NewEncoder().Bytes([]byte("\n"))
+	if err != nil {
+		return nil, err
+	}
+	two, err := enc.NewEncoder().Bytes([]byte("\n\n"))
+	if err != nil {
+		return nil, err
+	}
+	return two[len(one):], nil
+}
+
+// lineDecodingReader reads the text of a tailed file decoded by t, a line at
+// a time, and sets pos to the offset in the file of the end of the lines
+// read: the tailer cannot tell it, as the file is read ahead of the text
+// returned.  A line is only decoded once its newline is written, so the
+// tailer never reads an incomplete line.
+type lineDecodingReader struct {
+	r   io.Reader
+	t   transform.Transformer
+	nl  []byte // a newline in the encoding of the file
+	buf []byte
+	src []byte // bytes read but not decoded yet, from the start of a line
+	dst []byte // the line decoded but not returned yet
+	end int64  // offset in the file of the end of the line in dst
+	pos *readPosition
+}
+
+func (d *lineDecodingReader) Read(p []byte) (int, error) {
+	for len(d.dst) == 0 {
+		ok, err := d.decodeLine()
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			break
+		}
+
+		n, err := d.r.Read(d.buf)
+		d.src = append(d.src, d.buf[:n]...)
+		if n == 0 && err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.dst)
+	d.dst = d.dst[n:]
+	if len(d.dst) == 0 {
+		d.pos.set(d.end)
+	}
+	return n, nil
+}
+
+// decodeLine decodes the first line of the bytes read, if its newline is
+// read.
+func (d *lineDecodingReader) decodeLine() (bool, error) {
+	n := d.lineSize()
+	if n < 0 {
+		return false, nil
+	}
+	line, _, err := transform.Bytes(d.t, d.src[:n])
+	if err != nil {
+		return false, err
+	}
+	d.dst = line
+	d.src = d.src[n:]
+	d.end += int64(n)
+	return true, nil
+}
+
+// lineSize returns the size of the first line of the bytes read with its
+// newline, or -1 when its newline is not read yet.  In encodings with
+// several bytes per character, the newline is only looked for at the start
+// of a character.
+func (d *lineDecodingReader) lineSize() int {
+	if len(d.nl) == 1 {
+		i := bytes.IndexByte(d.src, d.nl[0])
+		if i < 0 {
+			return -1
+		}
+		return i + 1
+	}
+	for i := 0; i+len(d.nl) <= len(d.src); i += len(d.nl) {
+		if bytes.Equal(d.src[i:i+len(d.nl)], d.nl) {
+			return i + len(d.nl)
+		}
+	}
+	return -1
+}
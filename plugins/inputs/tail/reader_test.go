@@ -0,0 +1,101 @@
+package tail
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf/internal/encoding"
+	"github.com/stretchr/testify/require"
+)
+
+// growingReader reads chunks, returning io.EOF after each of them like a
+// file that is still being written.
+type growingReader struct {
+	chunks []string
+	eof    bool
+}
+
+func (g *growingReader) Read(p []byte) (int, error) {
+	if g.eof || len(g.chunks) == 0 {
+		g.eof = false
+		return 0, io.EOF
+	}
+	n := copy(p, g.chunks[0])
+	g.chunks = g.chunks[1:]
+	g.eof = true
+	return n, nil
+}
+
+func TestDecodingReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		chunks   []string
+		expected string
+	}{
+		{
+			name:     "utf-8",
+			chunks:   []string{"a\n", "b\n"},
+			expected: "a\nb\n",
+		},
+		{
+			name:     "utf-8 with bom",
+			chunks:   []string{"\xef\xbb\xbfa\n", "b\n"},
+			expected: "a\nb\n",
+		},
+		{
+			name:     "character split across writes",
+			encoding: "utf-16le",
+			chunks:   []string{"\xff\xfea\x00\n", "\x00b\x00\n\x00"},
+			expected: "a\nb\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := encoding.Lookup(tt.encoding)
+			require.NoError(t, err)
+			r := newDecodingReader(&growingReader{chunks: tt.chunks}, encoding.NewDecoder(enc))
+
+			var out strings.Builder
+			buf := make([]byte, 16)
+			for i := 0; i < 2*len(tt.chunks)+1; i++ {
+				n, err := r.Read(buf)
+				out.Write(buf[:n])
+				if err != nil {
+					require.Equal(t, io.EOF, err)
+				}
+			}
+			require.Equal(t, tt.expected, out.String())
+		})
+	}
+}
+
+func TestLineDecodingReader(t *testing.T) {
+	enc, err := encoding.Lookup("utf-16le")
+	require.NoError(t, err)
+
+	chunks := []string{"\xff\xfe", "a\x00\n\x00b\x00", "c\x00\n\x00"}
+	head := []byte(strings.Join(chunks, ""))
+	pos := newReadPosition()
+	r := newFileReader(&growingReader{chunks: chunks}, 0, enc, func() []byte { return head }, pos)
+	require.Equal(t, int64(2), pos.get())
+
+	var lines []string
+	var offsets []int64
+	buf := make([]byte, 16)
+	for i := 0; i < 2*len(chunks)+1; i++ {
+		n, err := r.Read(buf)
+		if n > 0 {
+			lines = append(lines, string(buf[:n]))
+			offsets = append(offsets, pos.get())
+		}
+		if err != nil {
+			require.Equal(t, io.EOF, err)
+		}
+	}
+	// the second line is only returned once its newline is read
+	require.Equal(t, []string{"a\n", "bc\n"}, lines)
+	require.Equal(t, []int64{6, 12}, offsets)
+}
@@ -0,0 +1,7 @@
+// +build windows
+
+package tail
+
+func statOwner(_ interface{}) (uid, gid string, ok bool) {
+	return "", "", false
+}
@@ -0,0 +1,18 @@
+// +build !windows
+
+package tail
+
+import (
+	"strconv"
+	"syscall"
+)
+
+// statOwner returns the ids of the user and the group owning a file, from
+// the Sys of its FileInfo.
+func statOwner(sys interface{}) (uid, gid string, ok bool) {
+	stat, ok := sys.(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10), true
+}
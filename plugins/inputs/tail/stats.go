@@ -0,0 +1,36 @@
+package tail
+
+import (
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// fileStats are the internal metrics of a file, in the internal_tail
+// measurement with the path of the file as a tag.
+type fileStats struct {
+	bytesRead      selfstat.Stat
+	linesParsed    selfstat.Stat
+	parseErrors    selfstat.Stat
+	duplicateLines selfstat.Stat
+}
+
+func newFileStats(filename string) *fileStats {
+	tags := map[string]string{"path": filename}
+	return &fileStats{
+		bytesRead:      selfstat.Register("tail", "bytes_read", tags),
+		linesParsed:    selfstat.Register("tail", "lines_parsed", tags),
+		parseErrors:    selfstat.Register("tail", "parse_errors", tags),
+		duplicateLines: selfstat.Register("tail", "duplicate_lines", tags),
+	}
+}
+
+// recordPosition records the offset reached in a file of size bytes, and
+// the lag of the offset behind the end of the file.
+func recordPosition(filename string, offset, size int64) {
+	tags := map[string]string{"path": filename}
+	selfstat.Register("tail", "offset", tags).Set(offset)
+	lag := size - offset
+	if lag < 0 {
+		lag = 0
+	}
+	selfstat.Register("tail", "lag", tags).Set(lag)
+}
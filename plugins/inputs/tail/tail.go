@@ -3,54 +3,170 @@
 package tail
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/influxdata/tail"
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/encoding"
 	"github.com/influxdata/telegraf/internal/globpath"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
+	"github.com/influxdata/telegraf/selfstat"
+	textencoding "golang.org/x/text/encoding"
 )
 
 const (
 	defaultWatchMethod = "inotify"
+
+	// defaultMaxUndeliveredLines is max_undelivered_lines with
+	// at_least_once, unless set.
+	defaultMaxUndeliveredLines = 1000
+
+	// parseErrorMaxLine is the size the lines of tail_parse_error are
+	// truncated to.
+	parseErrorMaxLine = 1024
 )
 
 var (
-	offsets      = make(map[string]int64)
+	offsets      = make(map[string]fileOffset)
 	offsetsMutex = new(sync.Mutex)
 )
 
 type Tail struct {
-	Files         []string
-	FromBeginning bool
-	Pipe          bool
-	WatchMethod   string
+	Files             []string
+	Exclude           []string `toml:"exclude"`
+	MaxFilesPerGlob   int      `toml:"max_files_per_glob"`
+	PathTagPattern    string   `toml:"path_tag_pattern"`
+	FileTags          []string `toml:"file_tags"`
+	FollowSymlinks    bool     `toml:"follow_symlinks"`
+	ResolveSymlinks   bool     `toml:"resolve_symlinks"`
+	FromBeginning     bool
+	Pipe              bool
+	WatchMethod       string
+	CharacterEncoding string `toml:"character_encoding"`
+	DockerJSONLog     bool   `toml:"docker_json_log"`
+	ParseErrorMetric  bool   `toml:"parse_error_metric"`
+	AtLeastOnce       bool   `toml:"at_least_once"`
+
+	AutoCharacterEncodings []string `toml:"auto_character_encodings"`
+
+	MaxLineBytes  internal.Size `toml:"max_line_bytes"`
+	MaxLineAction string        `toml:"max_line_action"`
+
+	FallbackTimestamp       string            `toml:"fallback_timestamp"`
+	FallbackTimestampOffset internal.Duration `toml:"fallback_timestamp_offset"`
+
+	PathRescanInterval internal.Duration `toml:"path_rescan_interval"`
+
+	MaxUndeliveredLines int `toml:"max_undelivered_lines"`
+	MaxLinesPerSecond   int `toml:"max_lines_per_second"`
+	DedupWindow         int `toml:"dedup_window"`
+
+	FileFormats     []FileFormat    `toml:"file_format"`
+	LineTransforms  []LineTransform `toml:"line_transform"`
+	LineFilters     LineFilters     `toml:"line_filters"`
+	MultilineConfig MultilineConfig `toml:"multiline"`
 
 	Log telegraf.Logger
 
-	tailers    map[string]*tail.Tail
-	offsets    map[string]int64
+	tailers map[string]*tail.Tail
+	// locked are the files that could not be opened as another process
+	// locked them, until they are
+	locked map[string]*lockedFile
+	// gzipFiles are the compressed files read, which are read only once
+	gzipFiles  map[string]bool
+	stdin      io.Reader
+	stdinRead  bool
+	excludes   []*globpath.GlobPath
+	pathTagRe  *regexp.Regexp
+	offsets    map[string]fileOffset
 	parserFunc parsers.ParserFunc
+	// parserConfig builds the parsers of the file_format data formats
+	parserConfig *parsers.Config
+	fileFormats  []fileFormat
+	// charset is the character_encoding of the files, unless detector
+	// detects the encoding of each file
+	charset    textencoding.Encoding
+	detector   *encoding.Detector
+	multiline  *multiline
+	transforms []*lineTransform
+	lineFilter *lineFilter
 	wg         sync.WaitGroup
 	acc        telegraf.Accumulator
 
+	// tracking is set when max_undelivered_lines is, with a slot in
+	// undelivered taken by each record until its metrics are delivered
+	tracking    telegraf.TrackingAccumulator
+	undelivered chan struct{}
+
+	// with at_least_once, offsetTrackers track the offsets delivered in the
+	// tailed files, and deliveries the trackers of the records whose
+	// metrics are not delivered yet
+	offsetTrackers map[*tail.Tail]*offsetTracker
+	deliveries     map[telegraf.TrackingID]*offsetTracker
+	deliveriesMu   sync.Mutex
+
+	// positions are the offsets of the lines read from the tailed files
+	// decoded from another encoding than UTF-8, which their tailers cannot
+	// tell
+	positions   map[*tail.Tail]*readPosition
+	positionsMu sync.Mutex
+
+	// dedups are the windows of the lines read from each file, with
+	// dedup_window
+	dedups   map[string]*dedupWindow
+	dedupsMu sync.Mutex
+
+	// done is closed when the plugin stops, ending the rescan, the delivery
+	// tracking and the reading of compressed files; doneWg waits for the
+	// rescan and the delivery tracking
+	done   chan struct{}
+	doneWg sync.WaitGroup
+
 	sync.Mutex
 }
 
 func NewTail() *Tail {
 	offsetsMutex.Lock()
-	offsetsCopy := make(map[string]int64, len(offsets))
+	offsetsCopy := make(map[string]fileOffset, len(offsets))
 	for k, v := range offsets {
 		offsetsCopy[k] = v
 	}
 	offsetsMutex.Unlock()
 
+	dedupWindowsMutex.Lock()
+	dedupsCopy := make(map[string]*dedupWindow, len(dedupWindows))
+	for k, v := range dedupWindows {
+		dedupsCopy[k] = v
+	}
+	dedupWindowsMutex.Unlock()
+
 	return &Tail{
-		FromBeginning: false,
-		offsets:       offsetsCopy,
+		FromBeginning:     false,
+		FollowSymlinks:    true,
+		MaxLineAction:     maxLineTruncate,
+		FallbackTimestamp: fallbackTimestampRead,
+		MultilineConfig: MultilineConfig{
+			MatchWhichLine: matchPreviousLine,
+			Timeout:        internal.Duration{Duration: defaultMultilineTimeout},
+			MaxLines:       defaultMultilineMaxLines,
+			MaxBytes:       internal.Size{Size: defaultMultilineMaxBytes},
+			JoinWith:       defaultMultilineJoinWith,
+		},
+		offsets: offsetsCopy,
+		dedups:  dedupsCopy,
+		stdin:   os.Stdin,
 	}
 }
 
@@ -64,8 +180,41 @@ const sampleConfig = `
   ##
   ## See https://github.com/gobwas/glob for more examples
   ##
+  ## "-" reads the standard input, such as when Telegraf is at the end of a
+  ## shell pipeline.
+  ##
   files = ["/var/mymetrics.out"]
-  ## Read file from beginning.
+
+  ## Files matched by files to leave out, such as the rotated or compressed
+  ## logs next to the tailed ones.  These accept the same glob rules.
+  # exclude = ["/var/log/**.gz", "/var/log/**.1"]
+
+  ## Tail only the most recently modified files matched by each glob, for
+  ## globs such as "/var/log/app-*.log" where only the newest file is
+  ## written.  Files that are no longer among them are closed, and resumed
+  ## where they were left if they are modified again.  0 is no limit.
+  # max_files_per_glob = 0
+
+  ## Regular expression matching the path of the files, whose named groups
+  ## are added as tags to the metrics, besides the path tag.
+  # path_tag_pattern = '/var/log/(?P<service>[^/]+)/(?P<instance>[^.]+)\.log'
+
+  ## Tags from the metadata of the files added to the metrics, such as on
+  ## hosts shared by tenants where the path does not tell them apart:
+  ## "owner" and "group" for the user and the group owning the file, and
+  ## "generation" for the number a rotated file ends with, such as 1 for
+  ## app.log.1 and 0 for app.log.
+  # file_tags = []
+
+  ## Tail the symlinks matched by files.  With resolve_symlinks, the target
+  ## of a symlink is tailed instead of the symlink, and the new target is
+  ## tailed when the symlink is changed, as with logs whose current file is
+  ## a symlink to the newest log.  The path tag is still the symlink.
+  # follow_symlinks = true
+  # resolve_symlinks = false
+
+  ## Read file from beginning.  Files ending in .gz are then decompressed
+  ## and read once, they are skipped otherwise.
   from_beginning = false
   ## Whether file is a named pipe
   pipe = false
@@ -73,11 +222,142 @@ const sampleConfig = `
   ## Method used to watch for file updates.  Can be either "inotify" or "poll".
   # watch_method = "inotify"
 
+  ## Look for new files matched by files at this interval, besides every
+  ## collection interval, so that new files are tailed sooner.  0 is off.
+  # path_rescan_interval = "0s"
+
+  ## Maximum number of lines, or multiline records, whose metrics were not
+  ## delivered to the outputs yet.  Reading pauses once it is reached, such
+  ## as when backfilling files faster than the outputs write.  0 is no
+  ## limit.
+  # max_undelivered_lines = 0
+
+  ## Record the offsets reached in the files only once the metrics of their
+  ## lines are delivered to the outputs, so that the lines whose metrics
+  ## were not delivered when Telegraf stops or reloads are read again.
+  ## max_undelivered_lines is then 1000 unless set.  Lines may be read
+  ## twice, and the offsets of files in another encoding than UTF-8 are
+  ## recorded as without it.
+  # at_least_once = false
+
+  ## Number of the last lines read from each file that are remembered to
+  ## drop them when they are read again, such as when the offsets are lost
+  ## or a file is read from the beginning on reload.  A line is the same
+  ## when its text and its offset in the file are.  The offset is unknown for
+  ## compressed files, standard input and files in another encoding than
+  ## UTF-8, where lines repeated within the window are dropped too.  0 is
+  ## off.
+  # dedup_window = 0
+
+  ## Maximum number of lines read per second from each file while catching
+  ## up with it, such as when reading large files from the beginning, so
+  ## that the outputs are not flooded.  Files are read at full speed once
+  ## their end is reached.  0 is no limit.
+  # max_lines_per_second = 0
+
+  ## Character encoding of the files, such as "utf-16le", "shift_jis",
+  ## "euc-jp" or "gbk".  Any label of the WHATWG Encoding Standard can be
+  ## used.  By default files are read as UTF-8.  A byte order mark at the
+  ## start of a file is stripped, and sets the UTF-8 or UTF-16 encoding.
+  ## "auto" detects the encoding of each file from its first bytes when it
+  ## is opened: UTF-8, UTF-16, or else the first of auto_character_encodings
+  ## that decodes them without invalid characters.
+  # character_encoding = ""
+  # auto_character_encodings = ["shift_jis", "windows-1252"]
+
+  ## Unwrap the lines of Docker json-file logs, such as
+  ## {"log":"GET /\n","stream":"stdout","time":"2020-07-01T12:00:00Z"},
+  ## parsing only the log text.  The stream is added as a tag and the time
+  ## as a field of the metrics.
+  # docker_json_log = false
+
+  ## Lines longer than this, such as minified JSON dumps, are truncated with
+  ## the tag truncated=true, or dropped when max_line_action is "drop".  The
+  ## lines are still read in full.  0 is no limit.
+  # max_line_bytes = "0B"
+  # max_line_action = "truncate"
+
+  ## Timestamp of the metrics of lines the parser finds no timestamp in:
+  ## "read" for the time the line is read, or "modification_time" for the
+  ## modification time of the file, such as when backfilling old logs so
+  ## that they are not all stamped with the current time.  The offset is
+  ## added to it.
+  # fallback_timestamp = "read"
+  # fallback_timestamp_offset = "0s"
+
+  ## Add a tail_parse_error metric for each line that fails to parse, with
+  ## the line and the error as fields, to graph and alert on malformed logs.
+  # parse_error_metric = false
+
   ## Data format to consume.
   ## Each data format has its own unique set of configuration options, read
   ## more about them here:
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
   data_format = "influx"
+
+  ## Data formats of the files matched by these globs, instead of
+  ## data_format.  The first file_format matching a file is used.  The
+  ## other options of the data formats are set along with data_format.
+  # [[inputs.tail.file_format]]
+    # files = ["/var/log/**.json"]
+    # data_format = "json"
+
+  ## Rewrite lines before they are filtered, joined and parsed, such as to
+  ## strip the prefix added by a container runtime.  Each match of the
+  ## regular expression pattern is replaced, with $1 or ${name} expanded to
+  ## the text of its groups.  With only, the line becomes the replacement
+  ## of its first match, and lines not matching are left as they are.  The
+  ## transforms are applied in order.
+  # [[inputs.tail.line_transform]]
+    # pattern = '^\S+ (stdout|stderr) [FP] '
+    # replacement = ""
+  # [[inputs.tail.line_transform]]
+    # pattern = '^<\d+>\w+ +\d+ [\d:]+ (?P<host>\S+) (?P<message>.*)$'
+    # replacement = "${message} host=${host}"
+    # only = true
+
+  ## Drop lines before they are joined and parsed, such as debug messages or
+  ## health checks.  The patterns are regular expressions.
+  # [inputs.tail.line_filters]
+    ## Keep only the lines matching one of these patterns.
+    # include = ['ERROR', 'WARN']
+    ## Drop the lines matching one of these patterns.
+    # exclude = ['GET /health']
+
+  ## Join lines into records spanning several lines, such as stack traces,
+  ## before they are parsed.  Works with any data_format.
+  # [inputs.tail.multiline]
+    ## Regular expression matching the lines that belong to a neighbouring
+    ## line.  Multiline handling is off when neither pattern nor end_pattern
+    ## is set.
+    # pattern = '^\s'
+
+    ## Regular expression matching the last line of a record, for logs with
+    ## explicit record terminators.  It can be used alone or along with
+    ## pattern.
+    # end_pattern = '^END TRANSACTION'
+
+    ## Line a matching line belongs to: "previous" for continuation lines
+    ## such as the indented lines of a stack trace, or "next" for lines
+    ## ending with a continuation marker.
+    # match_which_line = "previous"
+
+    ## Make the lines that do not match the pattern belong to the
+    ## neighbouring line instead.
+    # invert_match = false
+
+    ## Parse a record once no line was added to it for this long.
+    # timeout = "5s"
+
+    ## String joining the lines of a record.  Use "\n" to keep the lines of a
+    ## stack trace, or '\n' for a literal backslash and n as in JSON.
+    # join_with = " "
+
+    ## Parse a record early once it has this many lines or bytes, with the
+    ## tag truncated=true; the lines that follow start a new record.  0 is no
+    ## limit.
+    # max_lines = 1000
+    # max_bytes = "1MB"
 `
 
 func (t *Tail) SampleConfig() string {
@@ -92,24 +372,192 @@ func (t *Tail) Gather(acc telegraf.Accumulator) error {
 	t.Lock()
 	defer t.Unlock()
 
+	t.checkTailers()
 	return t.tailNewFiles(true)
 }
 
+// rescan gathers every path_rescan_interval, tailing the new files, until
+// the plugin stops.
+func (t *Tail) rescan() {
+	ticker := time.NewTicker(t.PathRescanInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+			if err := t.Gather(t.acc); err != nil {
+				t.acc.AddError(err)
+			}
+		}
+	}
+}
+
+// receiveDelivered frees the slot of each record whose metrics were
+// delivered, until the plugin stops.
+func (t *Tail) receiveDelivered() {
+	for {
+		select {
+		case <-t.done:
+			return
+		case info := <-t.tracking.Delivered():
+			if t.AtLeastOnce {
+				t.delivered(info.ID())
+			}
+			<-t.undelivered
+		}
+	}
+}
+
+// checkTailers records the offset reached in the tailed files and their lag,
+// and stops tailing the files truncated in place below the offset, so that
+// tailNewFiles reads them again from the beginning.  The files truncated are
+// recorded in the files_truncated field of internal_tail.
+func (t *Tail) checkTailers() {
+	if t.Pipe {
+		return
+	}
+
+	for file, tailer := range t.tailers {
+		offset, err := t.tell(tailer)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(tailer.Filename)
+		if err != nil {
+			continue
+		}
+		recordPosition(file, offset, info.Size())
+		if info.Size() >= offset {
+			continue
+		}
+
+		t.Log.Debugf("%q was truncated to %d bytes, reading it from the beginning", file, info.Size())
+		selfstat.Register("tail", "files_truncated", map[string]string{"path": file}).Incr(1)
+		if err := tailer.Stop(); err != nil {
+			t.Log.Errorf("Stopping tail on %q: %s", file, err.Error())
+		}
+		delete(t.tailers, file)
+	}
+}
+
 func (t *Tail) Start(acc telegraf.Accumulator) error {
 	t.Lock()
 	defer t.Unlock()
 
+	var err error
+	if t.CharacterEncoding == autoEncoding {
+		t.detector, err = encoding.NewDetector(t.AutoCharacterEncodings)
+	} else {
+		t.charset, err = encoding.Lookup(t.CharacterEncoding)
+	}
+	if err != nil {
+		return err
+	}
+
+	t.multiline, err = t.MultilineConfig.newMultiline()
+	if err != nil {
+		return err
+	}
+
+	t.transforms, err = compileLineTransforms(t.LineTransforms)
+	if err != nil {
+		return err
+	}
+
+	t.lineFilter, err = t.LineFilters.newLineFilter()
+	if err != nil {
+		return err
+	}
+
+	t.fileFormats, err = t.compileFileFormats()
+	if err != nil {
+		return err
+	}
+
+	switch t.MaxLineAction {
+	case "":
+		t.MaxLineAction = maxLineTruncate
+	case maxLineTruncate, maxLineDrop:
+	default:
+		return fmt.Errorf("invalid max_line_action %q, expected %q or %q",
+			t.MaxLineAction, maxLineTruncate, maxLineDrop)
+	}
+
+	switch t.FallbackTimestamp {
+	case "":
+		t.FallbackTimestamp = fallbackTimestampRead
+	case fallbackTimestampRead, fallbackTimestampModTime:
+	default:
+		return fmt.Errorf("invalid fallback_timestamp %q, expected %q or %q",
+			t.FallbackTimestamp, fallbackTimestampRead, fallbackTimestampModTime)
+	}
+
+	if err := checkFileTags(t.FileTags); err != nil {
+		return err
+	}
+
+	if t.PathTagPattern != "" {
+		t.pathTagRe, err = regexp.Compile(t.PathTagPattern)
+		if err != nil {
+			return fmt.Errorf("invalid path_tag_pattern: %s", err)
+		}
+	}
+
+	t.excludes = make([]*globpath.GlobPath, 0, len(t.Exclude))
+	for _, exclude := range t.Exclude {
+		g, err := globpath.Compile(exclude)
+		if err != nil {
+			return fmt.Errorf("glob %q failed to compile: %s", exclude, err)
+		}
+		t.excludes = append(t.excludes, g)
+	}
+
 	t.acc = acc
 	t.tailers = make(map[string]*tail.Tail)
+	t.positions = make(map[*tail.Tail]*readPosition)
+	t.locked = make(map[string]*lockedFile)
+	t.gzipFiles = make(map[string]bool)
+	t.done = make(chan struct{})
+
+	if t.AtLeastOnce {
+		if t.MaxUndeliveredLines <= 0 {
+			t.MaxUndeliveredLines = defaultMaxUndeliveredLines
+		}
+		t.offsetTrackers = make(map[*tail.Tail]*offsetTracker)
+		t.deliveries = make(map[telegraf.TrackingID]*offsetTracker)
+	}
+
+	if t.MaxUndeliveredLines > 0 {
+		t.tracking = acc.WithTracking(t.MaxUndeliveredLines)
+		t.undelivered = make(chan struct{}, t.MaxUndeliveredLines)
+		t.doneWg.Add(1)
+		go func() {
+			defer t.doneWg.Done()
+			t.receiveDelivered()
+		}()
+	}
 
-	err := t.tailNewFiles(t.FromBeginning)
+	err = t.tailNewFiles(t.FromBeginning)
+
+	if t.PathRescanInterval.Duration > 0 {
+		t.doneWg.Add(1)
+		go func() {
+			defer t.doneWg.Done()
+			t.rescan()
+		}()
+	}
 
 	// clear offsets
-	t.offsets = make(map[string]int64)
+	t.offsets = make(map[string]fileOffset)
 	// assumption that once Start is called, all parallel plugins have already been initialized
 	offsetsMutex.Lock()
-	offsets = make(map[string]int64)
+	offsets = make(map[string]fileOffset)
 	offsetsMutex.Unlock()
+	dedupWindowsMutex.Lock()
+	dedupWindows = make(map[string]*dedupWindow)
+	dedupWindowsMutex.Unlock()
 
 	return err
 }
@@ -120,68 +568,284 @@ func (t *Tail) tailNewFiles(fromBeginning bool) error {
 		poll = true
 	}
 
+	// files left out by max_files_per_glob, unless another glob keeps them
+	kept := make(map[string]bool)
+	older := make(map[string]bool)
+
 	// Create a "tailer" for each file
 	for _, filepath := range t.Files {
+		if filepath == stdinFile {
+			if !t.stdinRead {
+				t.stdinRead = true
+				t.readStdin()
+			}
+			continue
+		}
+
 		g, err := globpath.Compile(filepath)
 		if err != nil {
 			t.Log.Errorf("Glob %q failed to compile: %s", filepath, err.Error())
 		}
-		for _, file := range g.Match() {
-			if _, ok := t.tailers[file]; ok {
-				// we're already tailing this file
+		files, olderFiles := t.matchFiles(g)
+		for _, file := range olderFiles {
+			older[file] = true
+		}
+		for _, file := range files {
+			kept[file] = true
+			target, ok := t.resolveSymlink(file)
+			if !ok {
 				continue
 			}
+			if tailer, ok := t.tailers[file]; ok {
+				if tailer.Filename == target {
+					// we're already tailing this file
+					continue
+				}
+				t.Log.Debugf("%q now links to %q", file, target)
+				t.closeTailer(file, tailer)
+			}
+			if strings.HasSuffix(file, gzipExt) {
+				// compressed files are not followed, they are only read
+				// when reading files from the beginning
+				if t.FromBeginning && !t.gzipFiles[file] {
+					t.gzipFiles[file] = true
+					t.readGzip(file)
+				}
+				continue
+			}
+
+			readFromBeginning := fromBeginning
+			if l, ok := t.locked[file]; ok {
+				if time.Now().Before(l.next) {
+					continue
+				}
+				readFromBeginning = l.fromBeginning
+			}
 
 			var seek *tail.SeekInfo
-			if !t.Pipe && !fromBeginning {
-				if offset, ok := t.offsets[file]; ok {
-					t.Log.Debugf("Using offset %d for %q", offset, file)
-					seek = &tail.SeekInfo{
-						Whence: 0,
-						Offset: offset,
-					}
-				} else {
-					seek = &tail.SeekInfo{
-						Whence: 2,
-						Offset: 0,
-					}
+			if !t.Pipe && !readFromBeginning {
+				seek = t.seekInfo(target)
+			} else if o, _, ok := t.findOffset(target); ok && !t.Pipe {
+				// resume files closed by max_files_per_glob
+				t.Log.Debugf("Using offset %d for %q", o.offset, target)
+				seek = &tail.SeekInfo{
+					Whence: 0,
+					Offset: o.offset,
 				}
 			}
 
-			tailer, err := tail.TailFile(file,
+			opened := make(chan int64)
+			var eof int32
+			pos := newReadPosition()
+			tailer, err := tail.TailFile(target,
 				tail.Config{
-					ReOpen:    true,
-					Follow:    true,
-					Location:  seek,
-					MustExist: true,
-					Poll:      poll,
-					Pipe:      t.Pipe,
-					Logger:    tail.DiscardingLogger,
+					ReOpen:         true,
+					Follow:         true,
+					Location:       seek,
+					MustExist:      true,
+					Poll:           poll,
+					Pipe:           t.Pipe,
+					Logger:         tail.DiscardingLogger,
+					OpenReaderFunc: t.tailerReader(target, opened, &eof, pos),
 				})
 			if err != nil {
+				if isLocked(err) {
+					t.retryLocked(file, readFromBeginning)
+					continue
+				}
 				t.acc.AddError(err)
 				continue
 			}
+			delete(t.locked, file)
+			t.positionsMu.Lock()
+			t.positions[tailer] = pos
+			t.positionsMu.Unlock()
 
 			t.Log.Debugf("Tail added for %q", file)
 
-			parser, err := t.parserFunc()
+			parser, err := t.newParser(file)
 			if err != nil {
 				t.Log.Errorf("Creating parser: %s", err.Error())
 			}
 
+			var th *throttle
+			var ot *offsetTracker
+			if !t.Pipe {
+				th = t.newThrottle(func() bool { return atomic.LoadInt32(&eof) != 0 })
+				if t.AtLeastOnce {
+					ot = newOffsetTracker()
+					t.deliveriesMu.Lock()
+					t.offsetTrackers[tailer] = ot
+					t.deliveriesMu.Unlock()
+				}
+			}
+
 			// create a goroutine for each "tailer"
 			t.wg.Add(1)
-			go func() {
+			go func(file string) {
 				defer t.wg.Done()
-				t.receiver(parser, tailer)
-			}()
-			t.tailers[tailer.Filename] = tailer
+				t.receiver(parser, file, tailer.Lines, opened, th, ot)
+				if err := tailer.Err(); err != nil {
+					t.Log.Errorf("Tailing %q: %s", tailer.Filename, err.Error())
+				}
+				if ot != nil {
+					t.deliveriesMu.Lock()
+					delete(t.offsetTrackers, tailer)
+					t.deliveriesMu.Unlock()
+				}
+				t.positionsMu.Lock()
+				delete(t.positions, tailer)
+				t.positionsMu.Unlock()
+			}(file)
+			t.tailers[file] = tailer
+		}
+	}
+
+	for file := range older {
+		if tailer, ok := t.tailers[file]; ok && !kept[file] {
+			t.Log.Debugf("Closing %q, newer files match", file)
+			t.closeTailer(file, tailer)
+		}
+	}
+
+	// files deleted or renamed so that the globs no longer match them
+	for file, tailer := range t.tailers {
+		if !kept[file] && !older[file] {
+			t.Log.Debugf("Tail stopped for %q, it is no longer matched", file)
+			if err := tailer.Stop(); err != nil {
+				t.Log.Errorf("Stopping tail on %q: %s", tailer.Filename, err.Error())
+			}
+			delete(t.tailers, file)
+		}
+	}
+
+	for file := range t.locked {
+		if !kept[file] {
+			delete(t.locked, file)
 		}
 	}
+
+	for file := range older {
+		kept[file] = true
+	}
+	t.pruneOffsets(kept)
 	return nil
 }
 
+// matchFiles returns the files matched by g that are not excluded, and when
+// there are more than max_files_per_glob, the older files left out.
+func (t *Tail) matchFiles(g *globpath.GlobPath) (files, older []string) {
+	for _, file := range g.Match() {
+		if !t.excluded(file) {
+			files = append(files, file)
+		}
+	}
+	if t.MaxFilesPerGlob <= 0 || len(files) <= t.MaxFilesPerGlob {
+		return files, nil
+	}
+
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return modTimes[files[i]].After(modTimes[files[j]])
+	})
+	return files[:t.MaxFilesPerGlob], files[t.MaxFilesPerGlob:]
+}
+
+// closeTailer stops tailing a file, recording its offset to resume it.
+func (t *Tail) closeTailer(file string, tailer *tail.Tail) {
+	if !t.Pipe {
+		if err := t.recordOffset(file, tailer); err != nil {
+			t.Log.Errorf("Recording offset for %q: %s", tailer.Filename, err.Error())
+		}
+	}
+	if err := tailer.Stop(); err != nil {
+		t.Log.Errorf("Stopping tail on %q: %s", tailer.Filename, err.Error())
+	}
+	delete(t.tailers, file)
+}
+
+// tailerReader returns the OpenReaderFunc of a tailer.  It sends on opened
+// every time the tailer opens its file, such as when it is reopened after
+// rotation, and sets eof once the end of the file is reached.  The send is
+// synchronous, so the receiver gets it after the last line of the old file
+// and before the first line of the new one; as the tailer holds its lock
+// meanwhile, the receiver must not call its methods.  With at_least_once or
+// dedup_window, the offset of the lines read is sent, and -1 otherwise.  The
+// offset reached in a file whose text is decoded is set in pos.
+func (t *Tail) tailerReader(file string, opened chan<- int64, eof *int32, pos *readPosition) func(io.Reader) io.Reader {
+	return func(r io.Reader) io.Reader {
+		head := func() []byte { return readHead(file) }
+		enc := t.fileEncoding(file, head)
+		offset := int64(-1)
+		if t.AtLeastOnce || t.DedupWindow > 0 {
+			offset = linesOffset(r, enc, head)
+		}
+
+		select {
+		case opened <- offset:
+		case <-t.done:
+		}
+		start := int64(-1)
+		if f, ok := r.(*os.File); ok && !t.Pipe {
+			if offset, err := f.Seek(0, io.SeekCurrent); err == nil {
+				start = offset
+			}
+		}
+		return newFileReader(&eofReader{r: r, eof: eof}, start, enc, head, pos)
+	}
+}
+
+// tell returns the offset reached in the file of tailer.
+func (t *Tail) tell(tailer *tail.Tail) (int64, error) {
+	t.positionsMu.Lock()
+	pos, ok := t.positions[tailer]
+	t.positionsMu.Unlock()
+	if ok {
+		if offset := pos.get(); offset >= 0 {
+			return offset, nil
+		}
+	}
+	return tailer.Tell()
+}
+
+// resolveSymlink returns the file to tail for a file matched by the globs,
+// which is the target of a symlink with resolve_symlinks.  It returns false
+// for symlinks not to tail.
+func (t *Tail) resolveSymlink(file string) (string, bool) {
+	info, err := os.Lstat(file)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return file, true
+	}
+	if !t.FollowSymlinks {
+		return "", false
+	}
+	if !t.ResolveSymlinks {
+		return file, true
+	}
+
+	target, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		t.Log.Debugf("Resolving symlink %q: %s", file, err.Error())
+		return "", false
+	}
+	return target, true
+}
+
+// excluded returns whether file matches one of the exclude globs.
+func (t *Tail) excluded(file string) bool {
+	for _, g := range t.excludes {
+		if g.MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
 // ParseLine parses a line of text.
 func parseLine(parser parsers.Parser, line string, firstLine bool) ([]telegraf.Metric, error) {
 	switch parser.(type) {
@@ -208,49 +872,301 @@ func parseLine(parser parsers.Parser, line string, firstLine bool) ([]telegraf.M
 }
 
 // Receiver is launched as a goroutine to continuously watch a tailed logfile
-// for changes, parse any incoming msgs, and add to the accumulator.
-func (t *Tail) receiver(parser parsers.Parser, tailer *tail.Tail) {
+// for changes, parse any incoming msgs, and add to the accumulator.  The
+// lines are limited by th, if any, until the file is caught up with.  Every
+// time the file is opened again, as told by opened, the parser starts over
+// so that a new header is parsed.  With at_least_once, ot tracks the
+// offsets of the lines parsed and of the records not delivered yet.
+func (t *Tail) receiver(parser parsers.Parser, filename string, lines <-chan *tail.Line, opened <-chan int64, th *throttle, ot *offsetTracker) {
 	var firstLine = true
-	for line := range tailer.Lines {
+	var reopen bool
+	stats := newFileStats(filename)
+	fileTags := t.fileTags(filename)
+	// offset of the next line in the file, or -1 when it is not known
+	offset := int64(-1)
+	window := t.dedupWindow(filename)
+
+	// lines of the multiline record being read, parsed once the record is
+	// complete or no line was added to it for the multiline timeout
+	var buffer recordBuffer
+	// partial Docker log entry, completed by the entries that follow, and
+	// the offset of its first line
+	var partial strings.Builder
+	var partialOffset int64
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	if t.multiline != nil {
+		timer = time.NewTimer(t.multiline.config.Timeout.Duration)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		if ot != nil {
+			// the lines of the records not complete yet are not parsed
+			read := offset
+			if partial.Len() > 0 {
+				read = partialOffset
+			}
+			if buffer.lines > 0 {
+				read = buffer.first.offset
+			}
+			ot.setRead(read)
+		}
+
+		var line *tail.Line
+		var open bool
+		select {
+		case line, open = <-lines:
+		case <-timeout:
+			if rec, ok := t.multiline.flush(&buffer); ok {
+				firstLine = t.parseRecord(parser, filename, stats, fileTags, ot, rec, firstLine)
+			}
+			continue
+		case offset = <-opened:
+			if !reopen {
+				// the file is opened the first time
+				reopen = true
+				ot.reset(offset)
+				if window != nil {
+					window.open(false)
+				}
+				continue
+			}
+			if t.multiline != nil {
+				if rec, ok := t.multiline.flush(&buffer); ok {
+					t.parseRecord(parser, filename, stats, fileTags, ot, rec, firstLine)
+				}
+			}
+			partial.Reset()
+			ot.reset(offset)
+			if window != nil {
+				window.open(true)
+			}
+
+			t.Log.Debugf("%q was reopened, parsing it from its first line", filename)
+			if p, err := t.newParser(filename); err != nil {
+				t.Log.Errorf("Creating parser: %s", err.Error())
+			} else {
+				parser = p
+			}
+			fileTags = t.fileTags(filename)
+			firstLine = true
+			continue
+		}
+		if !open {
+			break
+		}
+
 		if line.Err != nil {
-			t.Log.Errorf("Tailing %q: %s", tailer.Filename, line.Err.Error())
+			t.Log.Errorf("Tailing %q: %s", filename, line.Err.Error())
+			continue
+		}
+		if th != nil && !th.wait(t.done) {
+			t.Log.Debugf("Caught up with %q, no longer limiting its lines", filename)
+			th = nil
+		}
+		stats.bytesRead.Incr(int64(len(line.Text)) + 1)
+		start := offset
+		if offset >= 0 {
+			offset += int64(len(line.Text)) + 1
+		}
+		if window != nil && !window.add(start, line.Text) {
+			stats.duplicateLines.Incr(1)
 			continue
 		}
 		// Fix up files with Windows line endings.
-		text := strings.TrimRight(line.Text, "\r")
+		rec := record{text: strings.TrimRight(line.Text, "\r"), offset: start}
 
-		metrics, err := parseLine(parser, text, firstLine)
-		if err != nil {
-			t.Log.Errorf("Malformed log line in %q: [%q]: %s",
-				tailer.Filename, line.Text, err.Error())
+		if t.DockerJSONLog {
+			if partial.Len() == 0 {
+				partialOffset = start
+			}
+			var ok bool
+			var err error
+			rec, ok, err = unwrapDockerLog(rec.text, &partial)
+			if err != nil {
+				t.Log.Errorf("Malformed Docker log line in %q: [%q]: %s",
+					filename, line.Text, err.Error())
+				continue
+			}
+			if !ok {
+				continue
+			}
+			rec.offset = partialOffset
+		}
+
+		if t.MaxLineBytes.Size > 0 && int64(len(rec.text)) > t.MaxLineBytes.Size {
+			if t.MaxLineAction == maxLineDrop {
+				t.Log.Debugf("Dropping line of %d bytes in %q", len(rec.text), filename)
+				continue
+			}
+			rec.text = truncateLine(rec.text, int(t.MaxLineBytes.Size))
+			rec.truncated = true
+		}
+
+		rec.text = transformLine(t.transforms, rec.text)
+
+		if t.lineFilter != nil && !t.lineFilter.keep(rec.text) {
+			continue
+		}
+
+		if t.multiline != nil {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(t.multiline.config.Timeout.Duration)
+
+			for _, rec := range t.multiline.processLine(rec, &buffer) {
+				firstLine = t.parseRecord(parser, filename, stats, fileTags, ot, rec, firstLine)
+			}
 			continue
 		}
-		firstLine = false
 
-		for _, metric := range metrics {
-			metric.AddTag("path", tailer.Filename)
-			t.acc.AddMetric(metric)
+		firstLine = t.parseRecord(parser, filename, stats, fileTags, ot, rec, firstLine)
+	}
+
+	if t.multiline != nil {
+		if rec, ok := t.multiline.flush(&buffer); ok {
+			t.parseRecord(parser, filename, stats, fileTags, ot, rec, firstLine)
 		}
 	}
 
-	t.Log.Debugf("Tail removed for %q", tailer.Filename)
+	t.Log.Debugf("Tail removed for %q", filename)
+}
 
-	if err := tailer.Err(); err != nil {
-		t.Log.Errorf("Tailing %q: %s", tailer.Filename, err.Error())
+// parseRecord parses a line, or the lines of a multiline record, and adds
+// the metrics.  It returns whether the first line of the file is still to
+// be parsed.
+func (t *Tail) parseRecord(parser parsers.Parser, filename string, stats *fileStats, fileTags map[string]string, ot *offsetTracker, rec record, firstLine bool) bool {
+	start := time.Now()
+	metrics, err := parseLine(parser, rec.text, firstLine)
+	if err != nil {
+		stats.parseErrors.Incr(1)
+		t.Log.Errorf("Malformed log line in %q: [%q]: %s",
+			filename, rec.text, err.Error())
+		if t.ParseErrorMetric {
+			t.addParseError(filename, fileTags, rec, err)
+		}
+		return firstLine
+	}
+
+	stats.linesParsed.Incr(1)
+	t.setFallbackTime(filename, metrics, start, time.Now())
+
+	pathTags := t.pathTags(filename)
+	for _, metric := range metrics {
+		metric.AddTag("path", filename)
+		for k, v := range pathTags {
+			metric.AddTag(k, v)
+		}
+		for k, v := range fileTags {
+			metric.AddTag(k, v)
+		}
+		if rec.truncated {
+			metric.AddTag("truncated", "true")
+		}
+		for k, v := range rec.tags {
+			metric.AddTag(k, v)
+		}
+		for k, v := range rec.fields {
+			metric.AddField(k, v)
+		}
+	}
+	t.addMetrics(metrics, ot, rec.offset)
+	return false
+}
+
+// addParseError adds the tail_parse_error metric of a record that failed
+// to parse.
+func (t *Tail) addParseError(filename string, fileTags map[string]string, rec record, err error) {
+	tags := map[string]string{"path": filename}
+	for k, v := range t.pathTags(filename) {
+		tags[k] = v
+	}
+	for k, v := range fileTags {
+		tags[k] = v
+	}
+	if dataFormat := t.dataFormat(filename); dataFormat != "" {
+		tags["data_format"] = dataFormat
+	}
+
+	line := truncateLine(rec.text, parseErrorMaxLine)
+	if len(line) < len(rec.text) || rec.truncated {
+		tags["truncated"] = "true"
+	}
+	fields := map[string]interface{}{
+		"line":  line,
+		"error": err.Error(),
+	}
+	t.acc.AddFields("tail_parse_error", fields, tags)
+}
+
+// pathTags returns the tags of the named groups of path_tag_pattern in the
+// path of a file.
+func (t *Tail) pathTags(filename string) map[string]string {
+	if t.pathTagRe == nil {
+		return nil
+	}
+	match := t.pathTagRe.FindStringSubmatch(filename)
+	if match == nil {
+		return nil
+	}
+
+	tags := make(map[string]string)
+	for i, name := range t.pathTagRe.SubexpNames() {
+		if name != "" && match[i] != "" {
+			tags[name] = match[i]
+		}
+	}
+	return tags
+}
+
+// addMetrics adds the metrics of a record starting at offset.  With
+// max_undelivered_lines, it waits for a slot, which pauses the reading of
+// the file.  With at_least_once, ot tracks the delivery of the metrics.
+func (t *Tail) addMetrics(metrics []telegraf.Metric, ot *offsetTracker, offset int64) {
+	if t.tracking != nil && len(metrics) > 0 {
+		select {
+		case t.undelivered <- struct{}{}:
+			t.trackDelivery(metrics, ot, offset)
+			return
+		case <-t.done:
+			// the plugin is stopping, the outputs may not deliver the
+			// metrics until it has stopped, so the record is left
+			// undelivered
+			ot.add(0, offset)
+		}
+	}
+
+	for _, metric := range metrics {
+		t.acc.AddMetric(metric)
 	}
 }
 
 func (t *Tail) Stop() {
+	// done is closed first, as the rescan takes the lock, and the receivers
+	// waiting for deliveries must go on for the tailers to stop
+	if t.done != nil {
+		select {
+		case <-t.done:
+			// already stopped
+		default:
+			close(t.done)
+		}
+		t.doneWg.Wait()
+	}
+
 	t.Lock()
 	defer t.Unlock()
 
-	for _, tailer := range t.tailers {
+	for file, tailer := range t.tailers {
 		if !t.Pipe && !t.FromBeginning {
 			// store offset for resume
-			offset, err := tailer.Tell()
-			if err == nil {
-				t.Log.Debugf("Recording offset %d for %q", offset, tailer.Filename)
-			} else {
+			if err := t.recordOffset(file, tailer); err != nil {
 				t.Log.Errorf("Recording offset for %q: %s", tailer.Filename, err.Error())
 			}
 		}
@@ -268,12 +1184,47 @@ func (t *Tail) Stop() {
 		offsets[k] = v
 	}
 	offsetsMutex.Unlock()
+
+	dedupWindowsMutex.Lock()
+	for k, v := range t.dedups {
+		dedupWindows[k] = v
+	}
+	dedupWindowsMutex.Unlock()
+}
+
+// recordOffset records the offset reached in the file of tailer, to resume
+// reading it from there.  With at_least_once, it is the offset before which
+// the metrics of every line were delivered, when known.  The lines from the
+// offset are forgotten by the dedup_window of file, as they are read again.
+func (t *Tail) recordOffset(file string, tailer *tail.Tail) error {
+	offset, ok := t.deliveredOffset(tailer)
+	if !ok {
+		var err error
+		offset, err = t.tell(tailer)
+		if err != nil {
+			return err
+		}
+	}
+	o, err := newFileOffset(tailer.Filename, offset)
+	if err != nil {
+		return err
+	}
+	t.Log.Debugf("Recording offset %d for %q", offset, tailer.Filename)
+	t.offsets[tailer.Filename] = o
+	if window := t.dedupWindow(file); window != nil {
+		window.forgetFrom(offset)
+	}
+	return nil
 }
 
 func (t *Tail) SetParserFunc(fn parsers.ParserFunc) {
 	t.parserFunc = fn
 }
 
+func (t *Tail) SetParserConfig(config *parsers.Config) {
+	t.parserConfig = config
+}
+
 func init() {
 	inputs.Add("tail", func() telegraf.Input {
 		return NewTail()
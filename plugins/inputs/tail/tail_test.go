@@ -2,10 +2,16 @@ package tail
 
 import (
 	"bytes"
+	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/user"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +19,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/plugins/parsers/csv"
 	"github.com/influxdata/telegraf/plugins/parsers/json"
+	"github.com/influxdata/telegraf/selfstat"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -213,6 +220,60 @@ cpu,42
 	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
 }
 
+func TestCSVHeadersParsedAfterRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cpu.csv")
+	require.NoError(t, ioutil.WriteFile(path, []byte("measurement,time_idle\ncpu,42\n"), 0644))
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{path}
+	plugin.SetParserFunc(func() (parsers.Parser, error) {
+		return &csv.Parser{
+			MeasurementColumn: "measurement",
+			HeaderRowCount:    1,
+			TimeFunc:          func() time.Time { return time.Unix(0, 0) },
+		}, nil
+	})
+	defer plugin.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	acc.Wait(1)
+
+	// the new file has other columns
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, ioutil.WriteFile(path, []byte("measurement,time_user\ncpu,7\n"), 0644))
+	acc.Wait(2)
+	plugin.Stop()
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("cpu",
+			map[string]string{
+				"path": path,
+			},
+			map[string]interface{}{
+				"time_idle":   42,
+				"measurement": "cpu",
+			},
+			time.Unix(0, 0)),
+		testutil.MustMetric("cpu",
+			map[string]string{
+				"path": path,
+			},
+			map[string]interface{}{
+				"time_user":   7,
+				"measurement": "cpu",
+			},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
 // Ensure that the first line can produce multiple metrics (#6138)
 func TestMultipleMetricsOnFirstLine(t *testing.T) {
 	tmpfile, err := ioutil.TempFile("", "")
@@ -268,3 +329,1049 @@ func TestMultipleMetricsOnFirstLine(t *testing.T) {
 	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(),
 		testutil.IgnoreTime())
 }
+
+func TestCharacterEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		content  string
+	}{
+		{
+			name:     "shift_jis",
+			encoding: "shift_jis",
+			content:  "cpu,host=\x93\xfa\x96\x7b usage_idle=100\n",
+		},
+		{
+			name:     "utf-16le",
+			encoding: "utf-16le",
+			content: "c\x00p\x00u\x00,\x00h\x00o\x00s\x00t\x00=\x00\xe5\x65\x2c\x67 \x00" +
+				"u\x00s\x00a\x00g\x00e\x00_\x00i\x00d\x00l\x00e\x00=\x001\x000\x000\x00\n\x00",
+		},
+		{
+			name: "utf-16le with bom",
+			content: "\xff\xfec\x00p\x00u\x00,\x00h\x00o\x00s\x00t\x00=\x00\xe5\x65\x2c\x67 \x00" +
+				"u\x00s\x00a\x00g\x00e\x00_\x00i\x00d\x00l\x00e\x00=\x001\x000\x000\x00\n\x00",
+		},
+		{
+			name:    "utf-8 with bom",
+			content: "\xef\xbb\xbfcpu,host=日本 usage_idle=100\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpfile, err := ioutil.TempFile("", "")
+			require.NoError(t, err)
+			defer os.Remove(tmpfile.Name())
+			_, err = tmpfile.WriteString(tt.content)
+			require.NoError(t, err)
+			require.NoError(t, tmpfile.Close())
+
+			plugin := NewTail()
+			plugin.Log = testutil.Logger{}
+			plugin.FromBeginning = true
+			plugin.Files = []string{tmpfile.Name()}
+			plugin.CharacterEncoding = tt.encoding
+			plugin.SetParserFunc(parsers.NewInfluxParser)
+
+			acc := testutil.Accumulator{}
+			require.NoError(t, plugin.Start(&acc))
+			acc.Wait(1)
+			plugin.Stop()
+
+			acc.AssertContainsTaggedFields(t, "cpu",
+				map[string]interface{}{
+					"usage_idle": float64(100),
+				},
+				map[string]string{
+					"host": "日本",
+					"path": tmpfile.Name(),
+				})
+		})
+	}
+}
+
+func TestCharacterEncodingAuto(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"shift_jis.log": "cpu,host=\x93\xfa\x96\x7b usage_idle=100\n",
+		"utf-16le.log": "c\x00p\x00u\x00,\x00h\x00o\x00s\x00t\x00=\x00\xe5\x65\x2c\x67 \x00" +
+			"u\x00s\x00a\x00g\x00e\x00_\x00i\x00d\x00l\x00e\x00=\x001\x000\x000\x00\n\x00",
+		"utf-8.log": "cpu,host=日本 usage_idle=100\n",
+	}
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{filepath.Join(dir, "*.log")}
+	plugin.CharacterEncoding = "auto"
+	plugin.AutoCharacterEncodings = []string{"shift_jis"}
+	plugin.SetParserFunc(parsers.NewInfluxParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	acc.Wait(len(files))
+	plugin.Stop()
+
+	for name := range files {
+		acc.AssertContainsTaggedFields(t, "cpu",
+			map[string]interface{}{
+				"usage_idle": float64(100),
+			},
+			map[string]string{
+				"host": "日本",
+				"path": filepath.Join(dir, name),
+			})
+	}
+}
+
+func TestCharacterEncodingUnknown(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.CharacterEncoding = "klingon"
+	plugin.SetParserFunc(parsers.NewInfluxParser)
+
+	acc := testutil.Accumulator{}
+	require.Error(t, plugin.Start(&acc))
+}
+
+func newStringParser() (parsers.Parser, error) {
+	return parsers.NewValueParser("log", "string", nil)
+}
+
+func TestTailMultiline(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString("panic: oops\n  at main.go:1\n  at main.go:2\ndone\n")
+	require.NoError(t, err)
+	require.NoError(t, tmpfile.Close())
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{tmpfile.Name()}
+	plugin.MultilineConfig.Pattern = `^\s`
+	plugin.MultilineConfig.Timeout.Duration = 100 * time.Millisecond
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// the last record is parsed once the timeout passes
+	acc.Wait(2)
+
+	expected := []telegraf.Metric{
+		testutil.MustMetric("log",
+			map[string]string{"path": tmpfile.Name()},
+			map[string]interface{}{"value": "panic: oops   at main.go:1   at main.go:2"},
+			time.Unix(0, 0)),
+		testutil.MustMetric("log",
+			map[string]string{"path": tmpfile.Name()},
+			map[string]interface{}{"value": "done"},
+			time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(),
+		testutil.IgnoreTime())
+}
+
+func TestTailMultilineFlushedOnStop(t *testing.T) {
+	content := "panic: oops\n  at main.go:1\n"
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, tmpfile.Close())
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{tmpfile.Name()}
+	plugin.MultilineConfig.Pattern = `^\s`
+	plugin.MultilineConfig.Timeout.Duration = time.Hour
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+
+	// wait for the lines to be read
+	for _, tailer := range plugin.tailers {
+		for n, err := tailer.Tell(); err == nil && n < int64(len(content)); n, err = tailer.Tell() {
+			runtime.Gosched()
+		}
+	}
+
+	// the record waiting for the timeout is parsed when the plugin stops
+	plugin.Stop()
+	require.Equal(t, uint64(1), acc.NMetrics())
+	acc.AssertContainsFields(t, "log",
+		map[string]interface{}{"value": "panic: oops   at main.go:1"})
+}
+
+func TestTailTruncated(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString("first\nsecond\n")
+	require.NoError(t, err)
+	require.NoError(t, tmpfile.Close())
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.WatchMethod = "poll"
+	plugin.Files = []string{tmpfile.Name()}
+	plugin.SetParserFunc(newStringParser)
+	defer plugin.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	for _, tailer := range plugin.tailers {
+		for n, err := tailer.Tell(); err == nil && n < 13; n, err = tailer.Tell() {
+			runtime.Gosched()
+		}
+	}
+
+	require.NoError(t, ioutil.WriteFile(tmpfile.Name(), []byte("third\n"), 0644))
+	require.NoError(t, acc.GatherError(plugin.Gather))
+
+	acc.Wait(3)
+	var values []interface{}
+	for _, m := range acc.GetTelegrafMetrics() {
+		values = append(values, m.Fields()["value"])
+	}
+	require.Equal(t, []interface{}{"first", "second", "third"}, values)
+	truncated := selfstat.Register("tail", "files_truncated", map[string]string{"path": tmpfile.Name()})
+	require.Equal(t, int64(1), truncated.Get())
+}
+
+func TestTailExclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"app.log", "app.log.1", "app.log.2.gz"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("line\n"), 0644))
+	}
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{filepath.Join(dir, "**")}
+	plugin.Exclude = []string{filepath.Join(dir, "**.gz"), filepath.Join(dir, "*.1")}
+	plugin.SetParserFunc(newStringParser)
+	defer plugin.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+
+	var files []string
+	for file := range plugin.tailers {
+		files = append(files, file)
+	}
+	require.Equal(t, []string{filepath.Join(dir, "app.log")}, files)
+}
+
+func TestTailExcludeInvalid(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Exclude = []string{"/var/log/**[.log"}
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.Error(t, plugin.Start(&acc))
+}
+
+func TestTailMaxFilesPerGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	older := filepath.Join(dir, "app-1.log")
+	newer := filepath.Join(dir, "app-2.log")
+	require.NoError(t, ioutil.WriteFile(older, []byte("older\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(newer, []byte("newer\n"), 0644))
+	now := time.Now()
+	require.NoError(t, os.Chtimes(older, now, now.Add(-time.Minute)))
+	require.NoError(t, os.Chtimes(newer, now, now))
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{filepath.Join(dir, "app-*.log")}
+	plugin.MaxFilesPerGlob = 1
+	// ignore the offsets of files of earlier tests reusing their inodes
+	plugin.offsets = map[string]fileOffset{}
+	plugin.SetParserFunc(newStringParser)
+	defer plugin.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	require.Contains(t, plugin.tailers, newer)
+	require.NotContains(t, plugin.tailers, older)
+	acc.Wait(1)
+
+	// the older file becomes the newest
+	appendLine := func(path, line string, modTime time.Time) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString(line + "\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	appendLine(older, "older again", now.Add(time.Minute))
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	require.Contains(t, plugin.tailers, older)
+	require.NotContains(t, plugin.tailers, newer)
+	acc.Wait(3)
+
+	// the file that was closed resumes where it was left
+	appendLine(newer, "newer again", now.Add(2*time.Minute))
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	require.Contains(t, plugin.tailers, newer)
+	acc.Wait(4)
+
+	var values []interface{}
+	for _, m := range acc.GetTelegrafMetrics() {
+		values = append(values, m.Fields()["value"])
+	}
+	require.ElementsMatch(t, []interface{}{"newer", "older", "older again", "newer again"}, values)
+}
+
+func TestTailStopsUnmatchedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	older := filepath.Join(dir, "app-1.log")
+	newer := filepath.Join(dir, "app-2.log")
+	deleted := filepath.Join(dir, "app-3.log")
+	require.NoError(t, ioutil.WriteFile(older, []byte("older\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(newer, []byte("newer\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(deleted, []byte("deleted\n"), 0644))
+	now := time.Now()
+	require.NoError(t, os.Chtimes(older, now, now.Add(-time.Minute)))
+	require.NoError(t, os.Chtimes(newer, now, now))
+	require.NoError(t, os.Chtimes(deleted, now, now))
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{filepath.Join(dir, "app-*.log")}
+	plugin.MaxFilesPerGlob = 2
+	// ignore the offsets of files of earlier tests reusing their inodes
+	plugin.offsets = map[string]fileOffset{}
+	plugin.SetParserFunc(newStringParser)
+	defer plugin.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	require.Contains(t, plugin.tailers, newer)
+	require.Contains(t, plugin.tailers, deleted)
+
+	// the tailer of a deleted file is stopped
+	require.NoError(t, os.Remove(deleted))
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	require.NotContains(t, plugin.tailers, deleted)
+	require.Contains(t, plugin.tailers, older)
+
+	// the older file is closed again, keeping its offset to resume it
+	require.NoError(t, ioutil.WriteFile(deleted, []byte("deleted\n"), 0644))
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	require.NotContains(t, plugin.tailers, older)
+	require.Contains(t, plugin.offsets, older)
+
+	// the offset is forgotten once the file is deleted
+	require.NoError(t, os.Remove(older))
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	require.NotContains(t, plugin.offsets, older)
+}
+
+func TestTailResolveSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// the temporary directory may itself be behind a symlink
+	dir, err = filepath.EvalSymlinks(dir)
+	require.NoError(t, err)
+
+	first := filepath.Join(dir, "first.log")
+	second := filepath.Join(dir, "second.log")
+	link := filepath.Join(dir, "current")
+	require.NoError(t, ioutil.WriteFile(first, []byte("first\n"), 0644))
+	require.NoError(t, os.Symlink(first, link))
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{link}
+	plugin.ResolveSymlinks = true
+	plugin.SetParserFunc(newStringParser)
+	defer plugin.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	require.Equal(t, first, plugin.tailers[link].Filename)
+	acc.Wait(1)
+
+	// the symlink is changed to a new log
+	require.NoError(t, ioutil.WriteFile(second, []byte("second\n"), 0644))
+	require.NoError(t, os.Remove(link))
+	require.NoError(t, os.Symlink(second, link))
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	require.Equal(t, second, plugin.tailers[link].Filename)
+	acc.Wait(2)
+
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "first"},
+		map[string]string{"path": link})
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "second"},
+		map[string]string{"path": link})
+}
+
+func TestTailSkipSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	link := filepath.Join(dir, "current.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("line\n"), 0644))
+	require.NoError(t, os.Symlink(path, link))
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{filepath.Join(dir, "*.log")}
+	plugin.FollowSymlinks = false
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.Contains(t, plugin.tailers, path)
+	require.NotContains(t, plugin.tailers, link)
+}
+
+func TestTailPathRescanInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{filepath.Join(dir, "*.log")}
+	plugin.PathRescanInterval.Duration = 10 * time.Millisecond
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// the file is tailed without calling Gather
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("line\n"), 0644))
+	acc.Wait(1)
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "line"},
+		map[string]string{"path": path})
+}
+
+func TestTailGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err = w.Write([]byte("first\nsecond\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	path := filepath.Join(dir, "app.log.1.gz")
+	require.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	tests := []struct {
+		name          string
+		fromBeginning bool
+		expected      []interface{}
+	}{
+		{
+			name:          "from beginning",
+			fromBeginning: true,
+			expected:      []interface{}{"first", "second"},
+		},
+		{
+			name:          "from end",
+			fromBeginning: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewTail()
+			plugin.Log = testutil.Logger{}
+			plugin.FromBeginning = tt.fromBeginning
+			plugin.Files = []string{filepath.Join(dir, "*")}
+			plugin.SetParserFunc(newStringParser)
+
+			acc := testutil.Accumulator{}
+			require.NoError(t, plugin.Start(&acc))
+			require.Empty(t, plugin.tailers)
+			acc.Wait(len(tt.expected))
+
+			// compressed files are read only once
+			require.NoError(t, acc.GatherError(plugin.Gather))
+			plugin.Stop()
+
+			var values []interface{}
+			for _, m := range acc.GetTelegrafMetrics() {
+				values = append(values, m.Fields()["value"])
+			}
+			require.Equal(t, tt.expected, values)
+		})
+	}
+}
+
+func TestTailStdin(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{"-"}
+	plugin.stdin = strings.NewReader("first\nsecond")
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	acc.Wait(2)
+	// standard input is read only once
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	plugin.Stop()
+
+	require.Len(t, acc.Metrics, 2)
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "first"},
+		map[string]string{"path": "-"})
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "second"},
+		map[string]string{"path": "-"})
+}
+
+func TestTailStdinStop(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{"-"}
+	plugin.stdin = r
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+
+	// stopping does not wait for the standard input to be closed
+	plugin.Stop()
+}
+
+func TestTailDockerJSONLog(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString(
+		`{"log":"panic: oops\n","stream":"stderr","time":"2020-07-01T12:00:00.1Z"}` + "\n" +
+			`{"log":"  at main.go:1\n","stream":"stderr","time":"2020-07-01T12:00:00.2Z"}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, tmpfile.Close())
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{tmpfile.Name()}
+	plugin.DockerJSONLog = true
+	plugin.MultilineConfig.Pattern = `^\s`
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	info, err := os.Stat(tmpfile.Name())
+	require.NoError(t, err)
+	for _, tailer := range plugin.tailers {
+		for n, err := tailer.Tell(); err == nil && n < info.Size(); n, err = tailer.Tell() {
+			runtime.Gosched()
+		}
+	}
+	plugin.Stop()
+
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{
+			"value": "panic: oops   at main.go:1",
+			"time":  "2020-07-01T12:00:00.1Z",
+		},
+		map[string]string{
+			"path":   tmpfile.Name(),
+			"stream": "stderr",
+		})
+}
+
+func TestTailMaxLineBytes(t *testing.T) {
+	tests := []struct {
+		action   string
+		expected []telegraf.Metric
+	}{
+		{
+			action: "truncate",
+			expected: []telegraf.Metric{
+				testutil.MustMetric("log",
+					map[string]string{"path": "-"},
+					map[string]interface{}{"value": "short"},
+					time.Unix(0, 0)),
+				testutil.MustMetric("log",
+					map[string]string{"path": "-", "truncated": "true"},
+					map[string]interface{}{"value": "a very lo"},
+					time.Unix(0, 0)),
+			},
+		},
+		{
+			action: "drop",
+			expected: []telegraf.Metric{
+				testutil.MustMetric("log",
+					map[string]string{"path": "-"},
+					map[string]interface{}{"value": "short"},
+					time.Unix(0, 0)),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			plugin := NewTail()
+			plugin.Log = testutil.Logger{}
+			plugin.Files = []string{"-"}
+			plugin.stdin = strings.NewReader("short\na very long line\n")
+			plugin.MaxLineBytes.Size = 9
+			plugin.MaxLineAction = tt.action
+			plugin.SetParserFunc(newStringParser)
+
+			acc := testutil.Accumulator{}
+			require.NoError(t, plugin.Start(&acc))
+			acc.Wait(len(tt.expected))
+			plugin.Stop()
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+		})
+	}
+}
+
+// deliveringAccumulator lets tests deliver tracked metrics.
+type deliveringAccumulator struct {
+	*testutil.Accumulator
+	delivered chan telegraf.DeliveryInfo
+}
+
+func (a *deliveringAccumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator {
+	return a
+}
+
+func (a *deliveringAccumulator) Delivered() <-chan telegraf.DeliveryInfo {
+	return a.delivered
+}
+
+type deliveryInfo struct {
+	id telegraf.TrackingID
+}
+
+func (d deliveryInfo) ID() telegraf.TrackingID {
+	return d.id
+}
+
+func (deliveryInfo) Delivered() bool {
+	return true
+}
+
+func TestTailMaxUndeliveredLines(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{"-"}
+	plugin.stdin = strings.NewReader("first\nsecond\nthird\n")
+	plugin.MaxUndeliveredLines = 1
+	plugin.SetParserFunc(newStringParser)
+
+	acc := &deliveringAccumulator{
+		Accumulator: &testutil.Accumulator{},
+		delivered:   make(chan telegraf.DeliveryInfo),
+	}
+	require.NoError(t, plugin.Start(acc))
+	defer plugin.Stop()
+
+	// reading pauses until the metrics are delivered
+	acc.Wait(1)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, uint64(1), acc.NMetrics())
+
+	acc.delivered <- deliveryInfo{}
+	acc.Wait(2)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, uint64(2), acc.NMetrics())
+
+	acc.delivered <- deliveryInfo{}
+	acc.Wait(3)
+}
+
+func TestTailAtLeastOnce(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+	_, err = tmpfile.WriteString("zero\n")
+	require.NoError(t, err)
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{tmpfile.Name()}
+	plugin.AtLeastOnce = true
+	plugin.offsets = map[string]fileOffset{}
+	plugin.SetParserFunc(newStringParser)
+
+	acc := &deliveringAccumulator{
+		Accumulator: &testutil.Accumulator{},
+		delivered:   make(chan telegraf.DeliveryInfo),
+	}
+	require.NoError(t, plugin.Start(acc))
+	require.Equal(t, defaultMaxUndeliveredLines, plugin.MaxUndeliveredLines)
+	for _, tailer := range plugin.tailers {
+		for n, err := tailer.Tell(); err == nil && n == 0; n, err = tailer.Tell() {
+			// wait for tailer to jump to end
+			runtime.Gosched()
+		}
+	}
+
+	_, err = tmpfile.WriteString("first\nsecond\nthird\n")
+	require.NoError(t, err)
+	acc.Wait(3)
+
+	plugin.deliveriesMu.Lock()
+	ids := make([]telegraf.TrackingID, 0, len(plugin.deliveries))
+	for id := range plugin.deliveries {
+		ids = append(ids, id)
+	}
+	plugin.deliveriesMu.Unlock()
+	require.Len(t, ids, 3)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// the third line is delivered before the second
+	acc.delivered <- deliveryInfo{id: ids[0]}
+	acc.delivered <- deliveryInfo{id: ids[2]}
+	plugin.Stop()
+
+	// reading resumes at the second line
+	require.Equal(t, int64(len("zero\nfirst\n")), plugin.offsets[tmpfile.Name()].offset)
+}
+
+func TestTailDedupWindow(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+	_, err = tmpfile.WriteString("first\nsecond\n")
+	require.NoError(t, err)
+
+	newPlugin := func() *Tail {
+		plugin := NewTail()
+		plugin.Log = testutil.Logger{}
+		plugin.Files = []string{tmpfile.Name()}
+		plugin.FromBeginning = true
+		plugin.DedupWindow = 10
+		plugin.SetParserFunc(newStringParser)
+		return plugin
+	}
+
+	plugin := newPlugin()
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	acc.Wait(2)
+	plugin.Stop()
+
+	// the lines read again after a reload are dropped
+	plugin = newPlugin()
+	acc = testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	_, err = tmpfile.WriteString("third\n")
+	require.NoError(t, err)
+	// the lines before it were read and dropped by the time it is parsed
+	acc.Wait(1)
+	require.Equal(t, uint64(1), acc.NMetrics())
+	acc.AssertContainsFields(t, "log", map[string]interface{}{"value": "third"})
+}
+
+func TestTailFileFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.json"),
+		[]byte(`{"latency": 42}`+"\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app.log"),
+		[]byte("cpu usage_idle=100\n"), 0644))
+
+	config := &parsers.Config{
+		DataFormat: "influx",
+		MetricName: "tail",
+	}
+	parser, err := parsers.NewParser(config)
+	require.NoError(t, err)
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{filepath.Join(dir, "*")}
+	plugin.FileFormats = []FileFormat{
+		{Files: []string{filepath.Join(dir, "*.json")}, DataFormat: "json"},
+	}
+	plugin.SetParserFunc(func() (parsers.Parser, error) { return parser, nil })
+	plugin.SetParserConfig(config)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	acc.Wait(2)
+
+	acc.AssertContainsTaggedFields(t, "tail",
+		map[string]interface{}{"latency": float64(42)},
+		map[string]string{"path": filepath.Join(dir, "app.json")})
+	acc.AssertContainsTaggedFields(t, "cpu",
+		map[string]interface{}{"usage_idle": float64(100)},
+		map[string]string{"path": filepath.Join(dir, "app.log")})
+}
+
+func TestTailFileFormatWithoutParserConfig(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FileFormats = []FileFormat{
+		{Files: []string{"/var/log/*.json"}, DataFormat: "json"},
+	}
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.Error(t, plugin.Start(&acc))
+}
+
+func TestTailPathTagPattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "api"), 0755))
+	path := filepath.Join(dir, "api", "api-1.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("line\n"), 0644))
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{filepath.Join(dir, "*", "*.log")}
+	plugin.PathTagPattern = `/(?P<service>[^/]+)/(?P<instance>[^/.]+)\.log$`
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	acc.Wait(1)
+
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "line"},
+		map[string]string{
+			"path":     path,
+			"service":  "api",
+			"instance": "api-1",
+		})
+}
+
+func TestTailFileTags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("files have no owner on windows")
+	}
+	current, err := user.Current()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "tail")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "app.log")
+	require.NoError(t, ioutil.WriteFile(path, []byte("current\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(path+".2", []byte("rotated\n"), 0644))
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{path + "*"}
+	plugin.FileTags = []string{"owner", "generation"}
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	acc.Wait(2)
+	plugin.Stop()
+
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "current"},
+		map[string]string{"path": path, "owner": current.Username, "generation": "0"})
+	acc.AssertContainsTaggedFields(t, "log",
+		map[string]interface{}{"value": "rotated"},
+		map[string]string{"path": path + ".2", "owner": current.Username, "generation": "2"})
+}
+
+func TestTailFileTagsInvalid(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FileTags = []string{"tenant"}
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.Error(t, plugin.Start(&acc))
+}
+
+func TestTailPathTagPatternInvalid(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.PathTagPattern = "("
+	plugin.SetParserFunc(newStringParser)
+
+	acc := testutil.Accumulator{}
+	require.Error(t, plugin.Start(&acc))
+}
+
+func TestTailFallbackTimestamp(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString("cpu usage_idle=100\ncpu usage_idle=42 1500000000000000000\n")
+	require.NoError(t, err)
+	require.NoError(t, tmpfile.Close())
+	modTime := time.Unix(1600000000, 0)
+	require.NoError(t, os.Chtimes(tmpfile.Name(), modTime, modTime))
+
+	tests := []struct {
+		name      string
+		timestamp string
+		offset    time.Duration
+		expected  func(time.Time) bool
+	}{
+		{
+			name:      "modification time",
+			timestamp: "modification_time",
+			expected:  func(tm time.Time) bool { return tm.Equal(modTime) },
+		},
+		{
+			name:      "modification time with offset",
+			timestamp: "modification_time",
+			offset:    -time.Hour,
+			expected:  func(tm time.Time) bool { return tm.Equal(modTime.Add(-time.Hour)) },
+		},
+		{
+			name:      "read time with offset",
+			timestamp: "read",
+			offset:    -time.Hour,
+			expected: func(tm time.Time) bool {
+				return tm.Before(time.Now().Add(-time.Hour)) &&
+					tm.After(time.Now().Add(-2*time.Hour))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := NewTail()
+			plugin.Log = testutil.Logger{}
+			plugin.FromBeginning = true
+			plugin.Files = []string{tmpfile.Name()}
+			plugin.FallbackTimestamp = tt.timestamp
+			plugin.FallbackTimestampOffset.Duration = tt.offset
+			plugin.SetParserFunc(parsers.NewInfluxParser)
+
+			acc := testutil.Accumulator{}
+			require.NoError(t, plugin.Start(&acc))
+			acc.Wait(2)
+			plugin.Stop()
+
+			metrics := acc.GetTelegrafMetrics()
+			require.Len(t, metrics, 2)
+			require.True(t, tt.expected(metrics[0].Time()), metrics[0].Time())
+			// the timestamp of a line is kept
+			require.Equal(t, time.Unix(1500000000, 0), metrics[1].Time())
+		})
+	}
+}
+
+func TestTailFallbackTimestampInvalid(t *testing.T) {
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FallbackTimestamp = "yesterday"
+	plugin.SetParserFunc(parsers.NewInfluxParser)
+
+	acc := testutil.Accumulator{}
+	require.Error(t, plugin.Start(&acc))
+}
+
+func TestTailParseErrorMetric(t *testing.T) {
+	config := &parsers.Config{
+		DataFormat: "influx",
+		MetricName: "tail",
+	}
+	parser, err := parsers.NewParser(config)
+	require.NoError(t, err)
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.Files = []string{"-"}
+	plugin.stdin = strings.NewReader("cpu usage_idle=100\nnot a metric\n")
+	plugin.ParseErrorMetric = true
+	plugin.SetParserFunc(func() (parsers.Parser, error) { return parser, nil })
+	plugin.SetParserConfig(config)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	acc.Wait(2)
+	plugin.Stop()
+
+	m, ok := acc.Get("tail_parse_error")
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"path": "-", "data_format": "influx"}, m.Tags)
+	require.Equal(t, "not a metric", m.Fields["line"])
+	require.NotEmpty(t, m.Fields["error"])
+}
+
+func TestTailStats(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString("cpu usage_idle=100\nnot a metric\n")
+	require.NoError(t, err)
+	require.NoError(t, tmpfile.Close())
+
+	plugin := NewTail()
+	plugin.Log = testutil.Logger{}
+	plugin.FromBeginning = true
+	plugin.Files = []string{tmpfile.Name()}
+	plugin.SetParserFunc(parsers.NewInfluxParser)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, plugin.Start(&acc))
+	for _, tailer := range plugin.tailers {
+		for n, err := tailer.Tell(); err == nil && n < 32; n, err = tailer.Tell() {
+			runtime.Gosched()
+		}
+	}
+	require.NoError(t, acc.GatherError(plugin.Gather))
+	plugin.Stop()
+
+	tags := map[string]string{"path": tmpfile.Name()}
+	require.Equal(t, int64(32), selfstat.Register("tail", "bytes_read", tags).Get())
+	require.Equal(t, int64(1), selfstat.Register("tail", "lines_parsed", tags).Get())
+	require.Equal(t, int64(1), selfstat.Register("tail", "parse_errors", tags).Get())
+	require.Equal(t, int64(32), selfstat.Register("tail", "offset", tags).Get())
+	require.Equal(t, int64(0), selfstat.Register("tail", "lag", tags).Get())
+}
@@ -0,0 +1,63 @@
+// +build !solaris
+
+package tail
+
+import (
+	"time"
+)
+
+// throttle limits the lines read from a file to max_lines_per_second while
+// catching up with it, until the end of the file is reached.  A nil
+// throttle does not limit the lines.
+type throttle struct {
+	limit    int
+	interval time.Duration
+	// caughtUp returns whether the end of the file was reached
+	caughtUp func() bool
+
+	start time.Time
+	count int
+}
+
+func (t *Tail) newThrottle(caughtUp func() bool) *throttle {
+	if t.MaxLinesPerSecond <= 0 {
+		return nil
+	}
+	return &throttle{
+		limit:    t.MaxLinesPerSecond,
+		interval: time.Second,
+		caughtUp: caughtUp,
+	}
+}
+
+// wait waits, when limit lines were already read in the current interval,
+// for the next interval or until done is closed.  It returns false once the
+// file is caught up with, and the throttle can be dropped.
+func (th *throttle) wait(done <-chan struct{}) bool {
+	if th == nil {
+		return false
+	}
+	if th.caughtUp() {
+		return false
+	}
+
+	now := time.Now()
+	if now.Sub(th.start) >= th.interval {
+		th.start = now
+		th.count = 0
+	}
+	th.count++
+	if th.count <= th.limit {
+		return true
+	}
+
+	timer := time.NewTimer(th.interval - now.Sub(th.start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-done:
+	}
+	th.start = time.Now()
+	th.count = 1
+	return true
+}
@@ -0,0 +1,48 @@
+package tail
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	caughtUp := false
+	plugin := NewTail()
+	plugin.MaxLinesPerSecond = 2
+	th := plugin.newThrottle(func() bool { return caughtUp })
+	th.interval = 100 * time.Millisecond
+
+	done := make(chan struct{})
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		require.True(t, th.wait(done))
+	}
+	// the third and fifth lines wait for the next interval
+	require.True(t, time.Since(start) >= 2*th.interval)
+
+	caughtUp = true
+	start = time.Now()
+	require.False(t, th.wait(done))
+	require.True(t, time.Since(start) < th.interval)
+}
+
+func TestThrottleDone(t *testing.T) {
+	plugin := NewTail()
+	plugin.MaxLinesPerSecond = 1
+	th := plugin.newThrottle(func() bool { return false })
+	th.interval = time.Hour
+
+	done := make(chan struct{})
+	close(done)
+	require.True(t, th.wait(done))
+	require.True(t, th.wait(done))
+}
+
+func TestThrottleOff(t *testing.T) {
+	plugin := NewTail()
+	th := plugin.newThrottle(func() bool { return false })
+	require.Nil(t, th)
+	require.False(t, th.wait(nil))
+}
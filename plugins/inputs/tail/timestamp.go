@@ -0,0 +1,48 @@
+// +build !solaris
+
+package tail
+
+import (
+	"os"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Timestamps of the metrics of lines without one.
+const (
+	fallbackTimestampRead    = "read"
+	fallbackTimestampModTime = "modification_time"
+)
+
+// fallbackTime returns the timestamp of the metrics of a file whose lines
+// have none, for lines read at now.
+func (t *Tail) fallbackTime(filename string, now time.Time) time.Time {
+	tm := now
+	if t.FallbackTimestamp == fallbackTimestampModTime {
+		if info, err := os.Stat(filename); err == nil {
+			tm = info.ModTime()
+		}
+	}
+	return tm.Add(t.FallbackTimestampOffset.Duration)
+}
+
+// setFallbackTime sets the fallback timestamp of the metrics parsed between
+// start and end whose timestamp is in between, as the parser stamped them
+// with the time they were parsed for want of one in their line.
+func (t *Tail) setFallbackTime(filename string, metrics []telegraf.Metric, start, end time.Time) {
+	if t.FallbackTimestamp == fallbackTimestampRead && t.FallbackTimestampOffset.Duration == 0 {
+		return
+	}
+
+	var tm time.Time
+	for _, m := range metrics {
+		if m.Time().Before(start) || m.Time().After(end) {
+			continue
+		}
+		if tm.IsZero() {
+			tm = t.fallbackTime(filename, m.Time())
+		}
+		m.SetTime(tm)
+	}
+}
@@ -0,0 +1,71 @@
+package tail
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// LineTransform is a rewrite of the lines before they are filtered, joined
+// into multiline records and parsed, such as to strip the prefix added by
+// a container runtime or a syslog relay.
+type LineTransform struct {
+	// Pattern is the regular expression replaced in the lines.
+	Pattern string `toml:"pattern"`
+	// Replacement replaces each match of Pattern, with $1 or ${name}
+	// expanded to the text of the groups of the match.
+	Replacement string `toml:"replacement"`
+	// Only, when set, drops the text outside of the first match, so that the
+	// line is only the Replacement of its match.  Lines not matching are
+	// left as they are.
+	Only bool `toml:"only"`
+}
+
+// lineTransform rewrites lines according to a LineTransform.
+type lineTransform struct {
+	re          *regexp.Regexp
+	replacement string
+	only        bool
+}
+
+// compileLineTransforms returns the transforms of the configs, applied in
+// order.
+func compileLineTransforms(configs []LineTransform) ([]*lineTransform, error) {
+	transforms := make([]*lineTransform, 0, len(configs))
+	for _, c := range configs {
+		if c.Pattern == "" {
+			return nil, errors.New("line_transform has no pattern")
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line_transform pattern: %s", err)
+		}
+		transforms = append(transforms, &lineTransform{
+			re:          re,
+			replacement: c.Replacement,
+			only:        c.Only,
+		})
+	}
+	return transforms, nil
+}
+
+// apply returns text rewritten by the transform.
+func (lt *lineTransform) apply(text string) string {
+	if !lt.only {
+		return lt.re.ReplaceAllString(text, lt.replacement)
+	}
+
+	match := lt.re.FindStringSubmatchIndex(text)
+	if match == nil {
+		return text
+	}
+	return string(lt.re.ExpandString(nil, lt.replacement, text, match))
+}
+
+// transformLine returns text rewritten by each of the transforms in turn.
+func transformLine(transforms []*lineTransform, text string) string {
+	for _, lt := range transforms {
+		text = lt.apply(text)
+	}
+	return text
+}
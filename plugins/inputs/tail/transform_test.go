@@ -0,0 +1,87 @@
+package tail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineTransform(t *testing.T) {
+	tests := []struct {
+		name     string
+		configs  []LineTransform
+		text     string
+		expected string
+	}{
+		{
+			name: "strip prefix",
+			configs: []LineTransform{
+				{Pattern: `^\S+ (stdout|stderr) [FP] `},
+			},
+			text:     "2020-07-01T12:00:00.000000000Z stdout F cpu value=42",
+			expected: "cpu value=42",
+		},
+		{
+			name: "replace every match",
+			configs: []LineTransform{
+				{Pattern: `\s+`, Replacement: " "},
+			},
+			text:     "cpu  value=42\t1",
+			expected: "cpu value=42 1",
+		},
+		{
+			name: "reformat groups",
+			configs: []LineTransform{
+				{
+					Pattern:     `^<\d+>(?P<host>\S+) (?P<message>.*)$`,
+					Replacement: "${message},host=${host}",
+					Only:        true,
+				},
+			},
+			text:     "<13>server01 cpu",
+			expected: "cpu,host=server01",
+		},
+		{
+			name: "only drops the text outside of the match",
+			configs: []LineTransform{
+				{Pattern: `value=(\d+)`, Replacement: "cpu value=$1", Only: true},
+			},
+			text:     "noise value=42 noise",
+			expected: "cpu value=42",
+		},
+		{
+			name: "only keeps lines not matching",
+			configs: []LineTransform{
+				{Pattern: `^<\d+>(.*)$`, Replacement: "$1", Only: true},
+			},
+			text:     "cpu value=42",
+			expected: "cpu value=42",
+		},
+		{
+			name: "applied in order",
+			configs: []LineTransform{
+				{Pattern: `^\[\w+\] `},
+				{Pattern: `^cpu`, Replacement: "system"},
+			},
+			text:     "[app] cpu value=42",
+			expected: "system value=42",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transforms, err := compileLineTransforms(tt.configs)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, transformLine(transforms, tt.text))
+		})
+	}
+}
+
+func TestLineTransformInvalid(t *testing.T) {
+	for _, config := range []LineTransform{
+		{Pattern: ""},
+		{Pattern: "("},
+	} {
+		_, err := compileLineTransforms([]LineTransform{config})
+		require.Error(t, err)
+	}
+}
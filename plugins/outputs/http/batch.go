@@ -0,0 +1,123 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+)
+
+// batch is the serialized body of count metrics.
+type batch struct {
+	body []byte
+	// encoded is body with content_encoding applied, when already known
+	encoded []byte
+	count   int
+	metrics []telegraf.Metric
+	// url the batch is sent to, when resolved from the url template
+	url string
+	// id sent in the Idempotency-Key header of every attempt
+	id string
+}
+
+// batchID returns the idempotency key of a batch, derived from its body and
+// URL so that the same metrics written again get the same key.
+func batchID(b batch) string {
+	h := sha256.New()
+	h.Write([]byte(b.url))
+	h.Write([]byte{0})
+	h.Write(b.body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// serializeBatches serializes metrics into request bodies no larger than
+// max_body_size, halving the metrics of a body until it fits.  A single
+// metric that does not fit is sent on its own.
+func (h *HTTP) serializeBatches(metrics []telegraf.Metric) ([]batch, error) {
+	body, err := h.serializer.SerializeBatch(metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.MaxBodySize.Size <= 0 {
+		return []batch{{body: body, count: len(metrics), metrics: metrics}}, nil
+	}
+
+	// the body is measured once content_encoding is applied, and sent as is
+	encoded, err := h.encodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(encoded))
+	if size <= h.MaxBodySize.Size {
+		return []batch{{body: body, encoded: encoded, count: len(metrics), metrics: metrics}}, nil
+	}
+	if len(metrics) == 1 {
+		log.Printf("W! [outputs.http] Metric of %d bytes exceeds max_body_size", size)
+		return []batch{{body: body, encoded: encoded, count: 1, metrics: metrics}}, nil
+	}
+
+	half := len(metrics) / 2
+	first, err := h.serializeBatches(metrics[:half])
+	if err != nil {
+		return nil, err
+	}
+	second, err := h.serializeBatches(metrics[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+// acceptedMetrics remembers the metrics of the batches of a write that were
+// sent before another batch of the write failed.  The agent writes all the
+// metrics again, and only the ones not accepted yet are sent.
+type acceptedMetrics struct {
+	sync.Mutex
+	metrics map[telegraf.Metric]bool
+}
+
+// filter returns the metrics not accepted yet.  The accepted metrics that
+// are not written again, such as when they overflowed the buffer of the
+// agent, are forgotten.
+func (a *acceptedMetrics) filter(metrics []telegraf.Metric) []telegraf.Metric {
+	a.Lock()
+	defer a.Unlock()
+	if len(a.metrics) == 0 {
+		return metrics
+	}
+
+	pending := make([]telegraf.Metric, 0, len(metrics))
+	accepted := make(map[telegraf.Metric]bool)
+	for _, m := range metrics {
+		if a.metrics[m] {
+			accepted[m] = true
+			continue
+		}
+		pending = append(pending, m)
+	}
+	a.metrics = accepted
+	return pending
+}
+
+// add records that the metrics of a batch were accepted.
+func (a *acceptedMetrics) add(b batch) {
+	a.Lock()
+	defer a.Unlock()
+	if a.metrics == nil {
+		a.metrics = make(map[telegraf.Metric]bool)
+	}
+	for _, m := range b.metrics {
+		a.metrics[m] = true
+	}
+}
+
+// clear forgets the accepted metrics once a write succeeds, as the agent
+// does not write them again.
+func (a *acceptedMetrics) clear() {
+	a.Lock()
+	defer a.Unlock()
+	a.metrics = nil
+}
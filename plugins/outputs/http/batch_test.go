@@ -0,0 +1,138 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func getMetrics(t *testing.T, n int) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, n)
+	for i := 0; i < n; i++ {
+		m, err := metric.New(
+			"cpu",
+			map[string]string{},
+			map[string]interface{}{
+				"value": float64(i),
+			},
+			time.Unix(int64(i), 0),
+		)
+		require.NoError(t, err)
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func TestMaxBodySize(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:         ts.URL,
+		MaxBodySize: internal.Size{Size: 64},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write(getMetrics(t, 5)))
+
+	lines := 0
+	for _, body := range bodies {
+		require.True(t, len(body) <= 64, body)
+		lines += strings.Count(body, "\n")
+	}
+	require.True(t, len(bodies) > 1)
+	require.Equal(t, 5, lines)
+}
+
+func TestMaxBodySizeSingleMetric(t *testing.T) {
+	plugin := &HTTP{MaxBodySize: internal.Size{Size: 1}}
+	plugin.SetSerializer(influx.NewSerializer())
+
+	batches, err := plugin.serializeBatches(getMetrics(t, 2))
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+	require.Equal(t, 1, batches[0].count)
+}
+
+func TestMaxBodySizeEncoded(t *testing.T) {
+	var bodies [][]byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:             ts.URL,
+		ContentEncoding: "snappy",
+		MaxBodySize:     internal.Size{Size: 1024},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	// the body is encoded once, to measure it, and sent as encoded then
+	batches, err := plugin.serializeBatches(getMetrics(t, 5))
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	require.Equal(t, snappy.Encode(nil, batches[0].body), batches[0].encoded)
+
+	require.NoError(t, plugin.writeTo(ts.URL, batches[0]))
+	require.Equal(t, [][]byte{batches[0].encoded}, bodies)
+}
+
+func TestWriteResendsFailedBatches(t *testing.T) {
+	var bodies []string
+	failed := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		if strings.Contains(string(body), "value=1 ") && !failed {
+			failed = true
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:         ts.URL,
+		MaxBodySize: internal.Size{Size: 1},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	metrics := getMetrics(t, 3)
+	require.Error(t, plugin.Write(metrics))
+	require.Equal(t, []string{"cpu value=0 0\n"}, bodies)
+
+	// the agent writes every metric again
+	bodies = nil
+	require.NoError(t, plugin.Write(metrics))
+	require.Equal(t, []string{"cpu value=1 1000000000\n", "cpu value=2 2000000000\n"}, bodies)
+
+	// once written, the same metrics are new to the next write
+	bodies = nil
+	require.NoError(t, plugin.Write(metrics[:1]))
+	require.Equal(t, []string{"cpu value=0 0\n"}, bodies)
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// States of the circuit breaker, as reported by its internal metric.
+const (
+	breakerClosed int64 = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker stops writes to a bridge that keeps failing.  After
+// threshold consecutive failures it opens and writes fail at once for the
+// cooldown.  Then a single write is let through: the breaker closes when it
+// succeeds and opens again when it fails.  A nil circuitBreaker lets every
+// write through.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	stat      selfstat.Stat
+
+	mu       sync.Mutex
+	state    int64
+	failures int
+	openedAt time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, tags map[string]string) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		stat:      selfstat.Register("http_output", "circuit_breaker_state", tags),
+	}
+	b.stat.Set(breakerClosed)
+	return b
+}
+
+// allow returns an error when a write must not be sent.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if wait := b.cooldown - time.Since(b.openedAt); wait > 0 {
+			return fmt.Errorf("circuit breaker open after %d failed writes, %s left", b.failures, wait)
+		}
+		log.Printf("I! [outputs.http] Circuit breaker half-open, probing bridge")
+		b.setState(breakerHalfOpen)
+		return nil
+	case breakerHalfOpen:
+		return fmt.Errorf("circuit breaker half-open, waiting for probe")
+	}
+	return nil
+}
+
+// record counts the outcome of a write that was let through.
+func (b *circuitBreaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		if b.state != breakerClosed {
+			log.Printf("I! [outputs.http] Circuit breaker closed")
+		}
+		b.failures = 0
+		b.setState(breakerClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.state == breakerClosed && b.failures >= b.threshold) {
+		log.Printf("W! [outputs.http] Circuit breaker open for %s after %d failed writes", b.cooldown, b.failures)
+		b.openedAt = time.Now()
+		b.setState(breakerOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(state int64) {
+	b.state = state
+	b.stat.Set(state)
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, 50*time.Millisecond, map[string]string{"url": "test"})
+
+	require.NoError(t, b.allow())
+	b.record(true)
+	require.NoError(t, b.allow())
+	b.record(true)
+	require.Equal(t, breakerOpen, b.stat.Get())
+	require.Error(t, b.allow())
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, b.allow())
+	require.Equal(t, breakerHalfOpen, b.stat.Get())
+	require.Error(t, b.allow())
+
+	// a failed probe opens the breaker again
+	b.record(true)
+	require.Equal(t, breakerOpen, b.stat.Get())
+	require.Error(t, b.allow())
+
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, b.allow())
+	b.record(false)
+	require.Equal(t, breakerClosed, b.stat.Get())
+	require.NoError(t, b.allow())
+}
+
+func TestCircuitBreakerWrite(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:                     ts.URL,
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerCooldown:  internal.Duration{Duration: time.Minute},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	for i := 0; i < 4; i++ {
+		err := plugin.Write([]telegraf.Metric{getMetric()})
+		require.Error(t, err)
+	}
+	require.Equal(t, 2, requests)
+}
+
+func TestCircuitBreakerRetryAfter(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:                     ts.URL,
+		CircuitBreakerThreshold: 1,
+		CircuitBreakerCooldown:  internal.Duration{Duration: time.Millisecond},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.Error(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, breakerOpen, plugin.breaker.stat.Get())
+
+	// a write held back by Retry-After is not the probe of the breaker
+	plugin.retryAfter = time.Now().Add(time.Hour)
+	time.Sleep(time.Millisecond)
+	err := plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "retry after")
+	require.Equal(t, breakerOpen, plugin.breaker.stat.Get())
+	require.Equal(t, 1, requests)
+
+	status = http.StatusNoContent
+	plugin.retryAfter = time.Time{}
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, breakerClosed, plugin.breaker.stat.Get())
+	require.Equal(t, 2, requests)
+}
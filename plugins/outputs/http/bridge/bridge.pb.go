@@ -0,0 +1,420 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: bridge.proto
+
+package bridge
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type WriteRequest struct {
+	Source string `protobuf:"bytes,1,opt,name=source" json:"source,omitempty"`
+	// Metrics serialized in the data_format of the output.
+	Metrics              []byte   `protobuf:"bytes,2,opt,name=metrics,proto3" json:"metrics,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WriteRequest) Reset()         { *m = WriteRequest{} }
+func (m *WriteRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteRequest) ProtoMessage()    {}
+func (*WriteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bridge_683d5526bdefc3b5, []int{0}
+}
+func (m *WriteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteRequest.Unmarshal(m, b)
+}
+func (m *WriteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteRequest.Marshal(b, m, deterministic)
+}
+func (dst *WriteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteRequest.Merge(dst, src)
+}
+func (m *WriteRequest) XXX_Size() int {
+	return xxx_messageInfo_WriteRequest.Size(m)
+}
+func (m *WriteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteRequest proto.InternalMessageInfo
+
+func (m *WriteRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *WriteRequest) GetMetrics() []byte {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+type WriteResponse struct {
+	// Set when the batch was not accepted.
+	Error                string   `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WriteResponse) Reset()         { *m = WriteResponse{} }
+func (m *WriteResponse) String() string { return proto.CompactTextString(m) }
+func (*WriteResponse) ProtoMessage()    {}
+func (*WriteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bridge_683d5526bdefc3b5, []int{1}
+}
+func (m *WriteResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WriteResponse.Unmarshal(m, b)
+}
+func (m *WriteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WriteResponse.Marshal(b, m, deterministic)
+}
+func (dst *WriteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WriteResponse.Merge(dst, src)
+}
+func (m *WriteResponse) XXX_Size() int {
+	return xxx_messageInfo_WriteResponse.Size(m)
+}
+func (m *WriteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_WriteResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WriteResponse proto.InternalMessageInfo
+
+func (m *WriteResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ConfigRequest struct {
+	Source string `protobuf:"bytes,1,opt,name=source" json:"source,omitempty"`
+	// Revisions of the managed config sections, keyed by section name.
+	Revisions map[string]string `protobuf:"bytes,2,rep,name=revisions" json:"revisions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Why the last config received was rejected, if it was.
+	ConfigError string `protobuf:"bytes,3,opt,name=config_error,json=configError" json:"config_error,omitempty"`
+	// ETag of the last config received, valid for the revisions sent.
+	Etag                 string   `protobuf:"bytes,4,opt,name=etag" json:"etag,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ConfigRequest) Reset()         { *m = ConfigRequest{} }
+func (m *ConfigRequest) String() string { return proto.CompactTextString(m) }
+func (*ConfigRequest) ProtoMessage()    {}
+func (*ConfigRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bridge_683d5526bdefc3b5, []int{2}
+}
+func (m *ConfigRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ConfigRequest.Unmarshal(m, b)
+}
+func (m *ConfigRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ConfigRequest.Marshal(b, m, deterministic)
+}
+func (dst *ConfigRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ConfigRequest.Merge(dst, src)
+}
+func (m *ConfigRequest) XXX_Size() int {
+	return xxx_messageInfo_ConfigRequest.Size(m)
+}
+func (m *ConfigRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ConfigRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ConfigRequest proto.InternalMessageInfo
+
+func (m *ConfigRequest) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *ConfigRequest) GetRevisions() map[string]string {
+	if m != nil {
+		return m.Revisions
+	}
+	return nil
+}
+
+func (m *ConfigRequest) GetConfigError() string {
+	if m != nil {
+		return m.ConfigError
+	}
+	return ""
+}
+
+func (m *ConfigRequest) GetEtag() string {
+	if m != nil {
+		return m.Etag
+	}
+	return ""
+}
+
+type ConfigResponse struct {
+	// Set when the config matching etag is still current.
+	NotModified bool `protobuf:"varint,1,opt,name=not_modified,json=notModified" json:"not_modified,omitempty"`
+	// Plugin config as TOML.  Empty when there is nothing to change.
+	Config string `protobuf:"bytes,2,opt,name=config" json:"config,omitempty"`
+	// Signature of config, as in the X-Config-Signature header.
+	Signature            string   `protobuf:"bytes,3,opt,name=signature" json:"signature,omitempty"`
+	Etag                 string   `protobuf:"bytes,4,opt,name=etag" json:"etag,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ConfigResponse) Reset()         { *m = ConfigResponse{} }
+func (m *ConfigResponse) String() string { return proto.CompactTextString(m) }
+func (*ConfigResponse) ProtoMessage()    {}
+func (*ConfigResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_bridge_683d5526bdefc3b5, []int{3}
+}
+func (m *ConfigResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ConfigResponse.Unmarshal(m, b)
+}
+func (m *ConfigResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ConfigResponse.Marshal(b, m, deterministic)
+}
+func (dst *ConfigResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ConfigResponse.Merge(dst, src)
+}
+func (m *ConfigResponse) XXX_Size() int {
+	return xxx_messageInfo_ConfigResponse.Size(m)
+}
+func (m *ConfigResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ConfigResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ConfigResponse proto.InternalMessageInfo
+
+func (m *ConfigResponse) GetNotModified() bool {
+	if m != nil {
+		return m.NotModified
+	}
+	return false
+}
+
+func (m *ConfigResponse) GetConfig() string {
+	if m != nil {
+		return m.Config
+	}
+	return ""
+}
+
+func (m *ConfigResponse) GetSignature() string {
+	if m != nil {
+		return m.Signature
+	}
+	return ""
+}
+
+func (m *ConfigResponse) GetEtag() string {
+	if m != nil {
+		return m.Etag
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*WriteRequest)(nil), "bridge.WriteRequest")
+	proto.RegisterType((*WriteResponse)(nil), "bridge.WriteResponse")
+	proto.RegisterType((*ConfigRequest)(nil), "bridge.ConfigRequest")
+	proto.RegisterMapType((map[string]string)(nil), "bridge.ConfigRequest.RevisionsEntry")
+	proto.RegisterType((*ConfigResponse)(nil), "bridge.ConfigResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Bridge service
+
+type BridgeClient interface {
+	// WriteMetrics sends batches of metrics to the bridge.  Every batch is
+	// answered before the next one is sent.
+	WriteMetrics(ctx context.Context, opts ...grpc.CallOption) (Bridge_WriteMetricsClient, error)
+	// GetConfig returns the plugin config of an agent.
+	GetConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error)
+}
+
+type bridgeClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewBridgeClient(cc *grpc.ClientConn) BridgeClient {
+	return &bridgeClient{cc}
+}
+
+func (c *bridgeClient) WriteMetrics(ctx context.Context, opts ...grpc.CallOption) (Bridge_WriteMetricsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Bridge_serviceDesc.Streams[0], c.cc, "/bridge.Bridge/WriteMetrics", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &bridgeWriteMetricsClient{stream}
+	return x, nil
+}
+
+type Bridge_WriteMetricsClient interface {
+	Send(*WriteRequest) error
+	Recv() (*WriteResponse, error)
+	grpc.ClientStream
+}
+
+type bridgeWriteMetricsClient struct {
+	grpc.ClientStream
+}
+
+func (x *bridgeWriteMetricsClient) Send(m *WriteRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *bridgeWriteMetricsClient) Recv() (*WriteResponse, error) {
+	m := new(WriteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *bridgeClient) GetConfig(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error) {
+	out := new(ConfigResponse)
+	err := grpc.Invoke(ctx, "/bridge.Bridge/GetConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Bridge service
+
+type BridgeServer interface {
+	// WriteMetrics sends batches of metrics to the bridge.  Every batch is
+	// answered before the next one is sent.
+	WriteMetrics(Bridge_WriteMetricsServer) error
+	// GetConfig returns the plugin config of an agent.
+	GetConfig(context.Context, *ConfigRequest) (*ConfigResponse, error)
+}
+
+func RegisterBridgeServer(s *grpc.Server, srv BridgeServer) {
+	s.RegisterService(&_Bridge_serviceDesc, srv)
+}
+
+func _Bridge_WriteMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BridgeServer).WriteMetrics(&bridgeWriteMetricsServer{stream})
+}
+
+type Bridge_WriteMetricsServer interface {
+	Send(*WriteResponse) error
+	Recv() (*WriteRequest, error)
+	grpc.ServerStream
+}
+
+type bridgeWriteMetricsServer struct {
+	grpc.ServerStream
+}
+
+func (x *bridgeWriteMetricsServer) Send(m *WriteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *bridgeWriteMetricsServer) Recv() (*WriteRequest, error) {
+	m := new(WriteRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Bridge_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BridgeServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/bridge.Bridge/GetConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BridgeServer).GetConfig(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Bridge_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "bridge.Bridge",
+	HandlerType: (*BridgeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConfig",
+			Handler:    _Bridge_GetConfig_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WriteMetrics",
+			Handler:       _Bridge_WriteMetrics_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "bridge.proto",
+}
+
+func init() { proto.RegisterFile("bridge.proto", fileDescriptor_bridge_683d5526bdefc3b5) }
+
+var fileDescriptor_bridge_683d5526bdefc3b5 = []byte{
+	// 342 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0x4d, 0x6b, 0x2a, 0x31,
+	0x14, 0x7d, 0x51, 0xdf, 0xbc, 0x37, 0xd7, 0x51, 0x4a, 0x50, 0x19, 0xa4, 0x0b, 0x1d, 0x5a, 0x98,
+	0x95, 0x14, 0xbb, 0x29, 0xc5, 0x45, 0x6b, 0x91, 0xae, 0xdc, 0x64, 0xd3, 0xa5, 0xf8, 0x71, 0x1d,
+	0x42, 0x6b, 0x62, 0x93, 0x8c, 0xe0, 0xa2, 0x3f, 0xa0, 0xff, 0xb2, 0x3f, 0xa5, 0x4c, 0x92, 0xc1,
+	0x2a, 0x42, 0x77, 0xf7, 0x9c, 0xb9, 0x73, 0xee, 0xb9, 0xe7, 0x06, 0xa2, 0x85, 0xe2, 0xab, 0x0c,
+	0x07, 0x5b, 0x25, 0x8d, 0xa4, 0x81, 0x43, 0xc9, 0x03, 0x44, 0x2f, 0x8a, 0x1b, 0x64, 0xf8, 0x9e,
+	0xa3, 0x36, 0xb4, 0x03, 0x81, 0x96, 0xb9, 0x5a, 0x62, 0x4c, 0x7a, 0x24, 0x0d, 0x99, 0x47, 0x34,
+	0x86, 0x7f, 0x1b, 0x34, 0x8a, 0x2f, 0x75, 0x5c, 0xe9, 0x91, 0x34, 0x62, 0x25, 0x4c, 0xae, 0xa1,
+	0xe1, 0x15, 0xf4, 0x56, 0x0a, 0x8d, 0xb4, 0x05, 0x7f, 0x51, 0x29, 0xa9, 0xbc, 0x82, 0x03, 0xc9,
+	0x17, 0x81, 0xc6, 0x93, 0x14, 0x6b, 0x9e, 0xfd, 0x36, 0x6a, 0x0c, 0xa1, 0xc2, 0x1d, 0xd7, 0x5c,
+	0x8a, 0x62, 0x58, 0x35, 0xad, 0x0f, 0xaf, 0x06, 0xde, 0xfc, 0x91, 0xc2, 0x80, 0x95, 0x6d, 0x13,
+	0x61, 0xd4, 0x9e, 0x1d, 0x7e, 0xa3, 0x7d, 0x88, 0x96, 0xb6, 0x75, 0xe6, 0xac, 0x54, 0xed, 0x84,
+	0xba, 0xe3, 0x26, 0x05, 0x45, 0x29, 0xd4, 0xd0, 0xcc, 0xb3, 0xb8, 0x66, 0x3f, 0xd9, 0xba, 0x3b,
+	0x82, 0xe6, 0xb1, 0x26, 0xbd, 0x80, 0xea, 0x2b, 0xee, 0xbd, 0xc3, 0xa2, 0x2c, 0xd6, 0xdb, 0xcd,
+	0xdf, 0x72, 0xb4, 0x39, 0x84, 0xcc, 0x81, 0xfb, 0xca, 0x1d, 0x49, 0x3e, 0xa0, 0x59, 0xfa, 0xf3,
+	0x51, 0xf4, 0x21, 0x12, 0xd2, 0xcc, 0x36, 0x72, 0xc5, 0xd7, 0x1c, 0x57, 0x56, 0xe6, 0x3f, 0xab,
+	0x0b, 0x69, 0xa6, 0x9e, 0x2a, 0x52, 0x70, 0xae, 0xbc, 0x9e, 0x47, 0xf4, 0x12, 0x42, 0xcd, 0x33,
+	0x31, 0x37, 0xb9, 0x42, 0x6f, 0xff, 0x40, 0x9c, 0x33, 0x3f, 0xfc, 0x24, 0x10, 0x8c, 0x6d, 0x4c,
+	0xf4, 0xd1, 0x5f, 0x75, 0xea, 0x6e, 0x44, 0x5b, 0x65, 0x7e, 0x3f, 0x6f, 0xdd, 0x6d, 0x9f, 0xb0,
+	0xce, 0x74, 0xf2, 0x27, 0x25, 0x37, 0x84, 0x8e, 0x20, 0x7c, 0x46, 0xe3, 0xf6, 0xa1, 0xed, 0xb3,
+	0xf9, 0x77, 0x3b, 0xa7, 0x74, 0xa9, 0xb0, 0x08, 0xec, 0x2b, 0xbb, 0xfd, 0x1e, 0x00, 0xf7, 0x00,
+	0x4d, 0xd4, 0x75, 0x02, 0x00, 0x00,
+}
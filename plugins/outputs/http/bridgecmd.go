@@ -0,0 +1,277 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/logger"
+	"github.com/kardianos/osext"
+)
+
+// Commands the bridge can send in an envelope.  No other command is run, and
+// none of them passes arguments from the bridge to a program.
+const (
+	commandRestart    = "restart"
+	commandRotateLogs = "rotate_logs"
+	commandTest       = "test"
+	commandFlush      = "flush"
+	commandUploadLogs = "upload_logs"
+	commandDebug      = "debug"
+)
+
+const (
+	// maxCommandOutput is the most output of a command sent to the bridge.
+	maxCommandOutput = 64 * 1024
+
+	testCommandTimeout = time.Minute
+
+	defaultCommandMaxAge = 5 * time.Minute
+	defaultCommandsFile  = "bridge-commands.json"
+)
+
+// commandResult is the outcome of a bridge command, posted to
+// command_status_url.
+type commandResult struct {
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Source  string `json:"source"`
+	Success bool   `json:"success"`
+	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Time    string `json:"time"`
+}
+
+// runCommands runs the commands of an envelope and reports their results,
+// returning whether Telegraf must restart.  Commands are only run when the
+// envelope is signed, as they act on the agent rather than on its config,
+// and only once: the id and time of each command are covered by the
+// signature, so that sending the envelope again does not run them again.
+func (h *HTTP) runCommands(cmds []bridgeCommand) bool {
+	if len(cmds) == 0 {
+		return false
+	}
+	if h.ConfigHMACKey == "" && h.configPublicKey == nil {
+		log.Printf("E! [outputs.http] Ignoring %d bridge commands, commands require config_hmac_key or config_public_key", len(cmds))
+		return false
+	}
+
+	path := filepath.Join(h.ConfigFilePath, defaultCommandsFile)
+	now := time.Now()
+	seen, err := loadCommandLog(path, now, h.CommandMaxAge.Duration)
+	if err != nil {
+		log.Printf("E! [outputs.http] Ignoring %d bridge commands: %s", len(cmds), err)
+		return false
+	}
+
+	var run []bridgeCommand
+	for _, cmd := range cmds {
+		err := seen.add(&cmd, now, h.CommandMaxAge.Duration)
+		if err != nil {
+			log.Printf("E! [outputs.http] Rejecting bridge command %q (id %q): %s", cmd.Name, cmd.ID, err)
+			h.reportCommandResult(&cmd, "", err)
+			continue
+		}
+		run = append(run, cmd)
+	}
+	if len(run) == 0 {
+		return false
+	}
+	// the commands are remembered before they run, as a restart does not
+	// return
+	err = seen.save(path)
+	if err != nil {
+		log.Printf("E! [outputs.http] Ignoring %d bridge commands: %s", len(run), err)
+		return false
+	}
+
+	restart := false
+	for _, cmd := range run {
+		log.Printf("I! [outputs.http] Running bridge command %q (id %q)", cmd.Name, cmd.ID)
+		output, err := h.runCommand(&cmd)
+		if err != nil {
+			log.Printf("E! [outputs.http] Bridge command %q failed: %s", cmd.Name, err)
+		} else if cmd.Name == commandRestart {
+			restart = true
+		}
+		h.reportCommandResult(&cmd, output, err)
+	}
+	return restart
+}
+
+// skipCommands reports the commands of an envelope as not run, as the rest
+// of the envelope was rejected.
+func (h *HTTP) skipCommands(cmds []bridgeCommand, reason error) {
+	if h.ConfigHMACKey == "" && h.configPublicKey == nil {
+		return
+	}
+	for _, cmd := range cmds {
+		log.Printf("E! [outputs.http] Not running bridge command %q (id %q), the envelope was rejected", cmd.Name, cmd.ID)
+		h.reportCommandResult(&cmd, "", fmt.Errorf("envelope rejected: %s", reason))
+	}
+}
+
+// commandLog holds the ids of the commands run with the time they were
+// issued at, until they expire.  It is kept in a file of config_file_path so
+// that the commands are not run again after Telegraf restarts.
+type commandLog map[string]time.Time
+
+// loadCommandLog reads the commands run from path, leaving out the ones
+// issued more than maxAge before now.
+func loadCommandLog(path string, now time.Time, maxAge time.Duration) (commandLog, error) {
+	seen := commandLog{}
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return seen, nil
+	case err != nil:
+		return nil, err
+	}
+	err = json.Unmarshal(b, &seen)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commands file %s: %s", path, err)
+	}
+
+	for id, issued := range seen {
+		if now.Sub(issued) > maxAge {
+			delete(seen, id)
+		}
+	}
+	return seen, nil
+}
+
+// add adds cmd to the commands run, returning an error when it has no id or
+// time, when it was issued more than maxAge from now, or when it was run
+// already.
+func (l commandLog) add(cmd *bridgeCommand, now time.Time, maxAge time.Duration) error {
+	if cmd.ID == "" {
+		return errors.New("command has no id")
+	}
+	issued, err := time.Parse(time.RFC3339, cmd.Time)
+	if err != nil {
+		return fmt.Errorf("invalid command time %q", cmd.Time)
+	}
+	if now.Sub(issued) > maxAge || issued.Sub(now) > maxAge {
+		return fmt.Errorf("command issued at %s has expired", cmd.Time)
+	}
+	if _, ok := l[cmd.ID]; ok {
+		return errors.New("command was run already")
+	}
+	l[cmd.ID] = issued
+	return nil
+}
+
+func (l commandLog) save(path string) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// runCommand runs a command sent by the bridge, returning its output.  A
+// restart is only acknowledged here, Telegraf restarts once the rest of the
+// envelope is applied.
+func (h *HTTP) runCommand(cmd *bridgeCommand) (string, error) {
+	switch cmd.Name {
+	case commandRestart:
+		return "", nil
+	case commandRotateLogs:
+		return "", logger.RotateLogFile()
+	case commandTest:
+		return h.runTest()
+	case commandUploadLogs:
+		return h.uploadLogs(cmd)
+	case commandDebug:
+		return h.enableDebug(cmd)
+	case commandFlush:
+		if !h.Async {
+			return "", errors.New("flush requires async mode")
+		}
+		// writes need the config lock held while the envelope is applied
+		err := h.startFlush()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d batches queued", len(h.queue)), nil
+	}
+	return "", fmt.Errorf("unsupported command")
+}
+
+// enableDebug raises the log level of Telegraf to debug for the "duration"
+// argument of the command, at most debug_max_duration.
+func (h *HTTP) enableDebug(cmd *bridgeCommand) (string, error) {
+	d := h.DebugMaxDuration.Duration
+	if s, ok := cmd.Args["duration"]; ok {
+		argDuration, err := time.ParseDuration(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid duration %q: %s", s, err)
+		}
+		if argDuration <= 0 {
+			return "", fmt.Errorf("invalid duration %q: must be positive", s)
+		}
+		if argDuration < d {
+			d = argDuration
+		}
+	}
+	if d <= 0 {
+		return "", errors.New("debug_max_duration is not set")
+	}
+
+	logger.EnableDebugFor(d)
+	log.Printf("I! [outputs.http] Debug logging enabled for %s", d)
+	return fmt.Sprintf("debug logging enabled for %s", d), nil
+}
+
+// runTest runs "telegraf --test" on the local config, which gathers the
+// inputs once and prints their metrics.
+func (h *HTTP) runTest() (string, error) {
+	file, err := osext.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"--config", filepath.Join(h.ConfigFilePath, "telegraf.conf")}
+	if h.ConfigDirectory != "" {
+		args = append(args, "--config-directory", filepath.Join(h.ConfigFilePath, h.ConfigDirectory))
+	}
+	args = append(args, "--test")
+
+	out, err := internal.CombinedOutputTimeout(exec.Command(file, args...), testCommandTimeout)
+	if len(out) > maxCommandOutput {
+		out = out[len(out)-maxCommandOutput:]
+	}
+	return string(out), err
+}
+
+// reportCommandResult posts the result of a command to the bridge.  Failures
+// are only logged.
+func (h *HTTP) reportCommandResult(cmd *bridgeCommand, output string, cmdErr error) {
+	if h.CommandStatusURL == "" {
+		return
+	}
+
+	result := commandResult{
+		ID:      cmd.ID,
+		Name:    cmd.Name,
+		Source:  h.SourceAddress,
+		Success: cmdErr == nil,
+		Output:  output,
+		Time:    time.Now().Format(time.RFC3339),
+	}
+	if cmdErr != nil {
+		result.Error = cmdErr.Error()
+	}
+
+	err := h.postJSON(h.CommandStatusURL, &result)
+	if err != nil {
+		log.Printf("E! [outputs.http] Reporting command result to [%s]: %s", h.CommandStatusURL, err)
+	}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/wlog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommands(t *testing.T) {
+	var results []commandResult
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result commandResult
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+		results = append(results, result)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		URL:              ts.URL,
+		ConfigFilePath:   dir,
+		ConfigHMACKey:    "secret",
+		CommandStatusURL: ts.URL,
+		CommandMaxAge:    internal.Duration{Duration: time.Minute},
+		SourceAddress:    "10.0.0.1",
+		Async:            true,
+		QueueSize:        1,
+	}
+	require.NoError(t, plugin.Connect())
+
+	now := time.Now().Format(time.RFC3339)
+	restart := plugin.runCommands([]bridgeCommand{
+		{ID: "1", Time: now, Name: "flush"},
+		{ID: "2", Time: now, Name: "shell", Args: map[string]string{"cmd": "rm -rf /"}},
+	})
+	require.False(t, restart)
+
+	require.Len(t, results, 2)
+	require.Equal(t, "1", results[0].ID)
+	require.True(t, results[0].Success)
+	require.Equal(t, "10.0.0.1", results[0].Source)
+	require.Equal(t, "2", results[1].ID)
+	require.False(t, results[1].Success)
+	require.Equal(t, "unsupported command", results[1].Error)
+
+	// the restart itself happens once the envelope is applied
+	require.True(t, plugin.runCommands([]bridgeCommand{{ID: "3", Time: now, Name: "restart"}}))
+
+	// the queue is no longer flushed once the plugin is closed
+	require.NoError(t, plugin.Close())
+	plugin.runCommands([]bridgeCommand{{ID: "4", Time: now, Name: "flush"}})
+	require.Len(t, results, 4)
+	require.Equal(t, "output closed", results[3].Error)
+}
+
+func TestFlushCommandSync(t *testing.T) {
+	plugin := &HTTP{URL: "http://127.0.0.1:1"}
+	require.NoError(t, plugin.Connect())
+
+	_, err := plugin.runCommand(&bridgeCommand{Name: commandFlush})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "async mode")
+}
+
+func TestRunCommandsOnce(t *testing.T) {
+	var results []commandResult
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result commandResult
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+		results = append(results, result)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	newPlugin := func() *HTTP {
+		plugin := &HTTP{
+			URL:              ts.URL,
+			ConfigFilePath:   dir,
+			ConfigHMACKey:    "secret",
+			CommandStatusURL: ts.URL,
+			CommandMaxAge:    internal.Duration{Duration: time.Minute},
+		}
+		require.NoError(t, plugin.Connect())
+		return plugin
+	}
+	plugin := newPlugin()
+
+	now := time.Now()
+	restart := bridgeCommand{ID: "1", Time: now.Format(time.RFC3339), Name: "restart"}
+	require.True(t, plugin.runCommands([]bridgeCommand{restart}))
+
+	// the commands run are remembered after Telegraf restarts
+	require.False(t, newPlugin().runCommands([]bridgeCommand{restart}))
+
+	require.False(t, plugin.runCommands([]bridgeCommand{
+		{Name: "restart"},
+		{ID: "2", Name: "restart"},
+		{ID: "3", Time: now.Add(-2 * time.Minute).Format(time.RFC3339), Name: "restart"},
+		{ID: "4", Time: now.Add(2 * time.Minute).Format(time.RFC3339), Name: "restart"},
+	}))
+
+	require.Len(t, results, 6)
+	require.True(t, results[0].Success)
+	require.Equal(t, "command was run already", results[1].Error)
+	require.Equal(t, "command has no id", results[2].Error)
+	require.Contains(t, results[3].Error, "invalid command time")
+	require.Contains(t, results[4].Error, "has expired")
+	require.Contains(t, results[5].Error, "has expired")
+}
+
+func TestCommandLogExpiry(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+	path := filepath.Join(dir, defaultCommandsFile)
+
+	now := time.Now()
+	seen := commandLog{
+		"old": now.Add(-2 * time.Minute),
+		"new": now.Add(-30 * time.Second),
+	}
+	require.NoError(t, seen.save(path))
+
+	seen, err := loadCommandLog(path, now, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, seen, 1)
+	require.Contains(t, seen, "new")
+}
+
+func TestRunCommandsUnsigned(t *testing.T) {
+	posted := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posted = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:              ts.URL,
+		CommandStatusURL: ts.URL,
+	}
+	require.NoError(t, plugin.Connect())
+
+	require.False(t, plugin.runCommands([]bridgeCommand{{Name: "restart"}}))
+	require.False(t, posted)
+}
+
+func TestEnableDebug(t *testing.T) {
+	defer logger.SetupLogging(logger.LogConfig{LogTarget: logger.LogTargetStderr, Quiet: true})
+
+	plugin := &HTTP{DebugMaxDuration: internal.Duration{Duration: time.Hour}}
+
+	out, err := plugin.enableDebug(&bridgeCommand{Name: commandDebug, Args: map[string]string{"duration": "15m"}})
+	require.NoError(t, err)
+	require.Equal(t, "debug logging enabled for 15m0s", out)
+	require.Equal(t, wlog.DEBUG, wlog.LogLevel())
+
+	// longer durations are capped
+	out, err = plugin.enableDebug(&bridgeCommand{Name: commandDebug, Args: map[string]string{"duration": "24h"}})
+	require.NoError(t, err)
+	require.Equal(t, "debug logging enabled for 1h0m0s", out)
+
+	_, err = plugin.enableDebug(&bridgeCommand{Name: commandDebug, Args: map[string]string{"duration": "-1m"}})
+	require.Error(t, err)
+}
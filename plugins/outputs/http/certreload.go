@@ -0,0 +1,75 @@
+package http
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader provides the client certificate of tls_cert and tls_key,
+// loading them again whenever either file is modified, so renewed
+// certificates are used without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	_, err := r.certificate()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// certificate returns the current certificate.  While a renewed pair does
+// not load, for example because only one of the files has been written yet,
+// the previous certificate is kept.
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return r.keep(err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return r.keep(err)
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certMod) && keyInfo.ModTime().Equal(r.keyMod) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return r.keep(err)
+	}
+	if r.cert != nil {
+		log.Printf("I! [outputs.http] Reloaded client certificate %s", r.certFile)
+	}
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	return r.cert, nil
+}
+
+func (r *certReloader) keep(err error) (*tls.Certificate, error) {
+	if r.cert == nil {
+		return nil, err
+	}
+	log.Printf("W! [outputs.http] Loading client certificate %s: %s, keeping the previous one", r.certFile, err)
+	return r.cert, nil
+}
+
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var pki = testutil.NewPKI("../../../testutil/pki")
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "http-cert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	install := func(cert, key string, mod time.Time) {
+		require.NoError(t, ioutil.WriteFile(certFile, []byte(cert), 0600))
+		require.NoError(t, ioutil.WriteFile(keyFile, []byte(key), 0600))
+		require.NoError(t, os.Chtimes(certFile, mod, mod))
+		require.NoError(t, os.Chtimes(keyFile, mod, mod))
+	}
+
+	now := time.Now()
+	install(pki.ReadClientCert(), pki.ReadClientKey(), now.Add(-time.Hour))
+	r, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	first, err := r.certificate()
+	require.NoError(t, err)
+
+	// the certificate is renewed
+	install(pki.ReadServerCert(), pki.ReadServerKey(), now)
+	renewed, err := r.certificate()
+	require.NoError(t, err)
+	require.NotEqual(t, first.Certificate[0], renewed.Certificate[0])
+
+	// a broken renewal keeps the previous certificate
+	install("broken", "broken", now.Add(time.Hour))
+	kept, err := r.certificate()
+	require.NoError(t, err)
+	require.Equal(t, renewed.Certificate[0], kept.Certificate[0])
+}
+
+func TestCertReloaderMissing(t *testing.T) {
+	_, err := newCertReloader("/nonexistent/cert.pem", "/nonexistent/key.pem")
+	require.Error(t, err)
+}
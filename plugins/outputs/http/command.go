@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/influxdata/telegraf/internal"
+)
+
+// commandMessage is a notification received on the MQTT command topic.
+type commandMessage struct {
+	// Type is "config_available" or "update_available".
+	Type string `json:"type"`
+}
+
+// commandTopic returns the MQTT topic the agent receives commands on.
+func (h *HTTP) commandTopic() string {
+	if h.CommandTopic != "" {
+		return h.CommandTopic
+	}
+	return "telegraf/" + h.SourceAddress + "/commands"
+}
+
+// startCommandChannel connects to the MQTT brokers of command_servers and
+// subscribes to the command topic of this agent.  The client reconnects and
+// subscribes again on its own once connected; until then the connection is
+// retried with increasing delays.
+func (h *HTTP) startCommandChannel() error {
+	opts, err := h.createCommandOpts()
+	if err != nil {
+		return err
+	}
+	h.commandClient = paho.NewClient(opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancelCommand = cancel
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		backoff := minPushBackoff
+		for {
+			token := h.commandClient.Connect()
+			token.Wait()
+			err := token.Error()
+			if err == nil {
+				// the channel may have been stopped while connecting
+				h.commandMu.Lock()
+				defer h.commandMu.Unlock()
+				if ctx.Err() != nil {
+					h.commandClient.Disconnect(200)
+				}
+				return
+			}
+			log.Printf("E! [outputs.http] Connecting to command servers %v: %s, retrying in %s",
+				h.CommandServers, err, backoff)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > maxPushBackoff {
+				backoff = maxPushBackoff
+			}
+		}
+	}()
+	return nil
+}
+
+func (h *HTTP) stopCommandChannel() {
+	h.commandMu.Lock()
+	defer h.commandMu.Unlock()
+
+	if h.cancelCommand != nil {
+		h.cancelCommand()
+		h.cancelCommand = nil
+	}
+	if h.commandClient != nil && h.commandClient.IsConnected() {
+		h.commandClient.Disconnect(200)
+	}
+}
+
+func (h *HTTP) createCommandOpts() (*paho.ClientOptions, error) {
+	opts := paho.NewClientOptions()
+
+	opts.SetClientID("Telegraf-Command-" + internal.RandomString(5))
+	opts.SetConnectTimeout(h.Timeout.Duration)
+	if h.CommandUsername != "" {
+		opts.SetUsername(h.CommandUsername)
+	}
+	if h.CommandPassword != "" {
+		opts.SetPassword(h.CommandPassword)
+	}
+
+	tlsCfg, err := h.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		opts.SetTLSConfig(tlsCfg)
+	}
+
+	for _, server := range h.CommandServers {
+		if !strings.Contains(server, "://") {
+			server = "tcp://" + server
+		}
+		opts.AddBroker(server)
+	}
+
+	opts.SetAutoReconnect(true)
+	opts.SetOnConnectHandler(func(c paho.Client) {
+		topic := h.commandTopic()
+		token := c.Subscribe(topic, 1, func(_ paho.Client, msg paho.Message) {
+			h.handleCommand(msg.Payload())
+		})
+		token.Wait()
+		if token.Error() != nil {
+			log.Printf("E! [outputs.http] Subscribing to command topic %q: %s", topic, token.Error())
+			return
+		}
+		log.Printf("I! [outputs.http] Subscribed to command topic %q", topic)
+	})
+	opts.SetConnectionLostHandler(func(_ paho.Client, err error) {
+		log.Printf("W! [outputs.http] Connection to command servers lost: %s", err)
+	})
+	return opts, nil
+}
+
+// handleCommand acts on a message received on the command topic.  The
+// message only notifies the agent, the config itself is requested by the
+// polling loop.
+func (h *HTTP) handleCommand(payload []byte) {
+	var msg commandMessage
+	err := json.Unmarshal(payload, &msg)
+	if err != nil {
+		log.Printf("E! [outputs.http] Invalid command message: %s", err)
+		return
+	}
+
+	switch msg.Type {
+	case "config_available":
+		log.Printf("D! [outputs.http] Config available, polling [%s]", h.bridgeURL())
+		h.requestConfigPoll()
+	case "update_available":
+		log.Printf("W! [outputs.http] Agent updates are not supported, ignoring update notification")
+	default:
+		log.Printf("W! [outputs.http] Ignoring command message of type %q", msg.Type)
+	}
+}
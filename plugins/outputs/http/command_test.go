@@ -0,0 +1,96 @@
+package http
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandTopic(t *testing.T) {
+	plugin := &HTTP{SourceAddress: "10.0.0.1"}
+	require.Equal(t, "telegraf/10.0.0.1/commands", plugin.commandTopic())
+
+	plugin.CommandTopic = "site/a/telegraf"
+	require.Equal(t, "site/a/telegraf", plugin.commandTopic())
+}
+
+func TestHandleCommand(t *testing.T) {
+	plugin := &HTTP{pollNow: make(chan struct{}, 1)}
+
+	plugin.handleCommand([]byte(`{"type": "update_available"}`))
+	plugin.handleCommand([]byte(`not json`))
+	require.Len(t, plugin.pollNow, 0)
+
+	plugin.handleCommand([]byte(`{"type": "config_available"}`))
+	plugin.handleCommand([]byte(`{"type": "config_available"}`))
+	require.Len(t, plugin.pollNow, 1)
+}
+
+func TestCommandServersRequireConfigURL(t *testing.T) {
+	plugin := &HTTP{
+		URL:            "http://127.0.0.1:8080/telegraf",
+		CommandServers: []string{"tcp://127.0.0.1:1883"},
+	}
+	require.Error(t, plugin.Connect())
+}
+
+func TestCommandServersRequireTopic(t *testing.T) {
+	plugin := &HTTP{
+		URL:            "http://127.0.0.1:8080/telegraf",
+		ConfigURL:      "http://127.0.0.1:8080/config",
+		ManageConfig:   true,
+		CommandServers: []string{"tcp://127.0.0.1:1883"},
+	}
+	err := plugin.Connect()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "source_address or command_topic")
+}
+
+func TestCommandChannelStoppedWhileConnecting(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	// the broker accepts the connection once the channel is stopped
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+	}()
+
+	plugin := &HTTP{
+		CommandServers: []string{listener.Addr().String()},
+		CommandTopic:   "telegraf/commands",
+		Timeout:        internal.Duration{Duration: 5 * time.Second},
+	}
+	require.NoError(t, plugin.startCommandChannel())
+
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection to the broker")
+	}
+	defer conn.Close()
+
+	plugin.stopCommandChannel()
+
+	// CONNACK, connection accepted
+	_, err = conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+	require.NoError(t, err)
+	plugin.wg.Wait()
+
+	// the client disconnects rather than staying connected
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.Copy(ioutil.Discard, conn)
+	require.NoError(t, err)
+	require.False(t, plugin.commandClient.IsConnected())
+}
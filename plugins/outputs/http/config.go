@@ -0,0 +1,425 @@
+package http
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/validate"
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
+	"github.com/kardianos/osext"
+)
+
+const (
+	// configLockFile guards telegraf.conf against concurrent rewrites by
+	// other telegraf instances sharing the config directory.
+	configLockFile = "telegraf.conf.lock"
+
+	revisionPrefix = "# Revision: "
+)
+
+// configSections are the plugin tables of telegraf.conf that can be managed
+// by the bridge.
+var configSections = []string{"outputs", "processors", "aggregators", "inputs"}
+
+// pluginBlock is the range of lines, [begin, end), holding one plugin table
+// together with its subtables and the comment lines directly above it.
+type pluginBlock struct {
+	section string
+	name    string
+	begin   int
+	end     int
+}
+
+// lastLine returns the highest line number used by a table or its contents.
+func lastLine(tbl *ast.Table) int {
+	last := tbl.Line
+	for _, val := range tbl.Fields {
+		line := 0
+		switch v := val.(type) {
+		case *ast.KeyValue:
+			line = v.Line
+		case *ast.Table:
+			line = lastLine(v)
+		case []*ast.Table:
+			for _, t := range v {
+				if l := lastLine(t); l > line {
+					line = l
+				}
+			}
+		}
+		if line > last {
+			last = line
+		}
+	}
+	return last
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// pluginBlocks parses a config and returns the blocks of every managed
+// plugin table, ordered by position.
+func pluginBlocks(lines []string) ([]pluginBlock, error) {
+	tbl, err := toml.Parse([]byte(strings.Join(lines, "")))
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []pluginBlock
+	addBlock := func(section, name string, t *ast.Table) {
+		// ast line numbers start at 1
+		blocks = append(blocks, pluginBlock{
+			section: section,
+			name:    name,
+			begin:   t.Line - 1,
+			end:     lastLine(t),
+		})
+	}
+
+	for _, section := range configSections {
+		sectionTbl, ok := tbl.Fields[section].(*ast.Table)
+		if !ok {
+			continue
+		}
+		for name, val := range sectionTbl.Fields {
+			switch v := val.(type) {
+			case *ast.Table:
+				addBlock(section, name, v)
+			case []*ast.Table:
+				for _, t := range v {
+					addBlock(section, name, t)
+				}
+			}
+		}
+	}
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].begin < blocks[j].begin })
+
+	// attach the comments directly above each table
+	for i := range blocks {
+		floor := 0
+		if i > 0 {
+			floor = blocks[i-1].end
+		}
+		for blocks[i].begin > floor && isComment(lines[blocks[i].begin-1]) {
+			blocks[i].begin--
+		}
+	}
+	return blocks, nil
+}
+
+// sectionText returns the text of all blocks of a section, without the
+// revision comment.
+func sectionText(lines []string, blocks []pluginBlock, section string) string {
+	text := ""
+	for _, block := range blocks {
+		if block.section != section {
+			continue
+		}
+		for _, line := range lines[block.begin:block.end] {
+			if strings.HasPrefix(line, revisionPrefix) {
+				continue
+			}
+			text += line
+		}
+	}
+	return text
+}
+
+// sectionRevision returns the revision of a section of a config.
+func sectionRevision(lines []string, blocks []pluginBlock, section string) string {
+	return textRevision(sectionText(lines, blocks, section))
+}
+
+// textRevision returns the md5 of the non-blank lines of a config text.
+func textRevision(text string) string {
+	pluginConfigStr := ""
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if len(strings.TrimSpace(line)) > 0 {
+			pluginConfigStr += line
+		}
+	}
+
+	pluginConfigStr = strings.TrimSuffix(strings.TrimSuffix(pluginConfigStr, "\n"), "\r")
+	pluginConfMd5 := md5.New()
+	io.WriteString(pluginConfMd5, pluginConfigStr)
+	return fmt.Sprintf("%x", pluginConfMd5.Sum(nil))
+}
+
+// splitConfigSections splits a config body received from the bridge into the
+// managed sections it contains.  Tables other than plugins are ignored.
+func splitConfigSections(body string) (map[string]string, error) {
+	lines := splitLines(body)
+	blocks, err := pluginBlocks(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]string)
+	for _, section := range configSections {
+		if text := sectionText(lines, blocks, section); len(strings.TrimSpace(text)) > 0 {
+			sections[section] = text
+		}
+	}
+	return sections, nil
+}
+
+// splitLines splits text into lines, keeping the line endings.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// dropUnchangedSections removes the sections whose content matches the
+// current revision of that section, returning how many sections are left.
+func dropUnchangedSections(sections map[string]string, revisions map[string]string) int {
+	for section, content := range sections {
+		if current, ok := revisions[section]; ok && current == textRevision(content) {
+			delete(sections, section)
+		}
+	}
+	return len(sections)
+}
+
+// checkConfigTables returns an error when a config body has top-level
+// tables or keys other than the plugin sections.  Telegraf loads any other
+// table as a legacy input, such as [exec] for [[inputs.exec]].
+func checkConfigTables(body string) error {
+	tbl, err := toml.Parse([]byte(body))
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	for name := range tbl.Fields {
+		if !isConfigSection(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("tables not allowed: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// configPlugins returns the plugins defined in a config body, as
+// "section.name", for example "inputs.cpu".  Config with other tables than
+// plugin tables is rejected.
+func configPlugins(body string) ([]string, error) {
+	err := checkConfigTables(body)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := pluginBlocks(splitLines(body))
+	if err != nil {
+		return nil, err
+	}
+
+	plugins := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		plugins = append(plugins, block.section+"."+block.name)
+	}
+	return plugins, nil
+}
+
+// readConfigLines reads a config file, keeping the line endings.
+func readConfigLines(path string) ([]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return splitLines(string(buf)), nil
+}
+
+// calculateConfigRevisions returns the revision of every managed section of
+// telegraf.conf, keyed by section name.
+func calculateConfigRevisions(configFilePath string) (map[string]string, error) {
+	revisions := make(map[string]string)
+
+	err := os.Chdir(configFilePath)
+	if err != nil {
+		return revisions, err
+	}
+
+	lock, err := lockFile(configLockFile)
+	if err != nil {
+		return revisions, err
+	}
+	defer unlockFile(lock)
+
+	lines, err := readConfigLines("telegraf.conf")
+	if err != nil {
+		return revisions, err
+	}
+
+	blocks, err := pluginBlocks(lines)
+	if err != nil {
+		return revisions, err
+	}
+
+	for _, section := range configSections {
+		revisions[section] = sectionRevision(lines, blocks, section)
+	}
+
+	log.Printf("D! Config revisions : %v", revisions)
+	return revisions, nil
+}
+
+// spliceSection replaces the plugin tables of a section with content,
+// preceded by a revision and timestamp comment.  The new tables take the
+// place of the first existing table of the section, or are appended when the
+// section has none.  Everything else in the config is left untouched.
+func spliceSection(lines []string, section string, content string, revision string) ([]string, error) {
+	blocks, err := pluginBlocks(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	insert := -1
+	spliced := make([]string, 0, len(lines))
+	next := 0
+	for _, block := range blocks {
+		if block.section != section {
+			continue
+		}
+		if insert < 0 {
+			insert = len(spliced) + block.begin - next
+		}
+		spliced = append(spliced, lines[next:block.begin]...)
+		next = block.end
+		// drop the blank line separating the removed table from the next one
+		if next < len(lines) && len(strings.TrimSpace(lines[next])) == 0 {
+			next++
+		}
+	}
+	spliced = append(spliced, lines[next:]...)
+
+	var added []string
+	if insert < 0 {
+		insert = len(spliced)
+		if insert > 0 && !strings.HasSuffix(spliced[insert-1], "\n") {
+			added = append(added, "\n")
+		}
+		added = append(added, "\n")
+	}
+	added = append(added,
+		fmt.Sprintf("%s%s, Time: %s #\n", revisionPrefix, revision, time.Now().Format(time.RFC3339)),
+		strings.Trim(content, "\r\n")+"\n",
+		"\n",
+	)
+
+	result := make([]string, 0, len(spliced)+len(added))
+	result = append(result, spliced[:insert]...)
+	result = append(result, added...)
+	return append(result, spliced[insert:]...), nil
+}
+
+// updatePluginConfig replaces the sections of telegraf.conf present in
+// sections and applies the agent settings, when not nil, validates the result
+// and swaps it in place of the current file.  A config that fails validation
+// is returned as a *validate.Error.  It returns false when there is nothing
+// to change.
+func updatePluginConfig(sections map[string]string, revisions map[string]string, settings *agentSettings, configFilePath string, timeout time.Duration) (bool, error) {
+	err := os.Chdir(configFilePath)
+	if err != nil {
+		return false, err
+	}
+
+	lock, err := lockFile(configLockFile)
+	if err != nil {
+		return false, err
+	}
+	defer unlockFile(lock)
+
+	lines, err := agentConfig(settings)
+	if err != nil {
+		return false, err
+	}
+	if lines == nil {
+		if len(sections) == 0 {
+			return false, nil
+		}
+		lines, err = readConfigLines("telegraf.conf")
+		if err != nil {
+			return false, err
+		}
+	}
+
+	for _, section := range configSections {
+		content, ok := sections[section]
+		if !ok {
+			continue
+		}
+
+		lines, err = spliceSection(lines, section, content, revisions[section])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = replaceConfig(lines, nil, timeout)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// replaceConfig replaces telegraf.conf with lines once the new config, along
+// with the fragment files given, passes validation.  It must be called from
+// the config directory with the config lock held.
+func replaceConfig(lines []string, fragments []string, timeout time.Duration) error {
+	err := ioutil.WriteFile("telegraf.conf.new", []byte(strings.Join(lines, "")), 0644)
+	if err != nil {
+		return err
+	}
+
+	// make sure the new config works before replacing the running one
+	err = validate.Configs(append([]string{"telegraf.conf.new"}, fragments...), timeout)
+	if err != nil {
+		os.Remove("telegraf.conf.new")
+		return err
+	}
+
+	// remove current config file
+	err = os.Remove("telegraf.conf")
+	if err != nil {
+		return err
+	}
+
+	// rename new config file
+	err = os.Rename("telegraf.conf.new", "telegraf.conf")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func reloadConfig() error {
+	file, err := osext.Executable()
+	if err != nil {
+		return err
+	}
+
+	log.Println("Restarting Telegraf to load new plugin configuration ...")
+	err = syscall.Exec(file, os.Args, os.Environ())
+	if err != nil {
+		return err
+	}
+	return nil
+}
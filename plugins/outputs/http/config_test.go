@@ -0,0 +1,266 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	_ "github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/stretchr/testify/require"
+)
+
+type mockInput struct{}
+
+func (m *mockInput) SampleConfig() string {
+	return ""
+}
+
+func (m *mockInput) Description() string {
+	return ""
+}
+
+func (m *mockInput) Gather(acc telegraf.Accumulator) error {
+	return nil
+}
+
+func init() {
+	inputs.Add("mock", func() telegraf.Input {
+		return &mockInput{}
+	})
+}
+
+const testConfig = `[agent]
+  interval = "10s"
+
+###############################################################################
+#                            OUTPUT PLUGINS                                   #
+###############################################################################
+
+[[outputs.http]]
+  url = "http://127.0.0.1:8080/telegraf"
+
+###############################################################################
+#                            PROCESSOR PLUGINS                                #
+###############################################################################
+
+###############################################################################
+#                            AGGREGATOR PLUGINS                               #
+###############################################################################
+
+###############################################################################
+#                            INPUT PLUGINS                                    #
+###############################################################################
+
+[[inputs.mock]]
+
+###############################################################################
+#                            SERVICE INPUT PLUGINS                            #
+###############################################################################
+`
+
+func writeTestConfig(t *testing.T, content string) (string, func()) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "telegraf.conf"), []byte(content), 0644)
+	require.NoError(t, err)
+
+	return dir, func() {
+		os.Chdir(cwd)
+		os.RemoveAll(dir)
+	}
+}
+
+func readTestConfig(t *testing.T, dir string) string {
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "telegraf.conf"))
+	require.NoError(t, err)
+	return string(buf)
+}
+
+func TestSplitConfigSections(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected map[string]string
+	}{
+		{
+			name: "input plugins only",
+			body: "[[inputs.cpu]]\n",
+			expected: map[string]string{
+				"inputs": "[[inputs.cpu]]\n",
+			},
+		},
+		{
+			name: "sections split by plugin type",
+			body: "# Write to a file\n" +
+				"[[outputs.file]]\n" +
+				"  files = [\"stdout\"]\n" +
+				"\n" +
+				"[[inputs.cpu]]\n" +
+				"  [inputs.cpu.tags]\n" +
+				"    dc = \"a\"\n",
+			expected: map[string]string{
+				"outputs": "# Write to a file\n[[outputs.file]]\n  files = [\"stdout\"]\n",
+				"inputs":  "[[inputs.cpu]]\n  [inputs.cpu.tags]\n    dc = \"a\"\n",
+			},
+		},
+		{
+			name: "other tables are ignored",
+			body: "[agent]\n" +
+				"  interval = \"1s\"\n" +
+				"[[inputs.cpu]]\n",
+			expected: map[string]string{
+				"inputs": "[[inputs.cpu]]\n",
+			},
+		},
+		{
+			name:     "comments only",
+			body:     "# [[inputs.cpu]]\n",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections, err := splitConfigSections(tt.body)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, sections)
+		})
+	}
+}
+
+func TestSplitConfigSectionsInvalid(t *testing.T) {
+	_, err := splitConfigSections("[[inputs.cpu]\n")
+	require.Error(t, err)
+}
+
+func TestUpdatePluginConfig(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, testConfig)
+	defer cleanup()
+
+	sections := map[string]string{
+		"outputs": "[[outputs.http]]\n  url = \"http://10.0.0.1:8080/telegraf\"\n",
+	}
+	revisions := map[string]string{
+		"outputs": "abc",
+	}
+	_, err := updatePluginConfig(sections, revisions, nil, dir, time.Second)
+	require.NoError(t, err)
+
+	actual := readTestConfig(t, dir)
+	require.Contains(t, actual, "# Revision: abc, Time: ")
+	require.Contains(t, actual, "url = \"http://10.0.0.1:8080/telegraf\"")
+	require.NotContains(t, actual, "url = \"http://127.0.0.1:8080/telegraf\"")
+	require.Contains(t, actual, "PROCESSOR PLUGINS")
+
+	revs, err := calculateConfigRevisions(dir)
+	require.NoError(t, err)
+	require.Len(t, revs, 4)
+}
+
+func TestUpdatePluginConfigRejectsInvalid(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, testConfig)
+	defer cleanup()
+
+	sections := map[string]string{
+		"outputs": "[[outputs.doesnotexist]]\n",
+	}
+	_, err := updatePluginConfig(sections, map[string]string{}, nil, dir, time.Second)
+	require.Error(t, err)
+
+	require.Equal(t, testConfig, readTestConfig(t, dir))
+	_, err = os.Stat(filepath.Join(dir, "telegraf.conf.new"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestUpdatePluginConfigPreservesUnmanaged(t *testing.T) {
+	content := `[agent]
+  interval = "10s"
+
+# Send to the bridge
+[[outputs.http]]
+  url = "http://127.0.0.1:8080/telegraf"
+
+# # Read metrics about memory usage
+# [[inputs.mem]]
+
+# Read metrics about cpu usage
+[[inputs.cpu]]
+  percpu = [
+    true,
+  ]
+  [inputs.cpu.tags]
+    dc = "a"
+
+[[inputs.disk]]
+`
+	dir, cleanup := writeTestConfig(t, content)
+	defer cleanup()
+
+	sections := map[string]string{
+		"inputs": "[[inputs.mock]]\n",
+	}
+	_, err := updatePluginConfig(sections, map[string]string{"inputs": "abc"}, nil, dir, time.Second)
+	require.NoError(t, err)
+
+	actual := readTestConfig(t, dir)
+	require.True(t, strings.HasPrefix(actual, `[agent]
+  interval = "10s"
+
+# Send to the bridge
+[[outputs.http]]
+  url = "http://127.0.0.1:8080/telegraf"
+
+# # Read metrics about memory usage
+# [[inputs.mem]]
+
+# Revision: abc, Time: `), actual)
+	require.True(t, strings.HasSuffix(actual, "[[inputs.mock]]\n\n"), actual)
+	require.NotContains(t, actual, "inputs.cpu")
+	require.NotContains(t, actual, "inputs.disk")
+
+	// the revision matches the content sent by the bridge
+	revisions, err := calculateConfigRevisions(dir)
+	require.NoError(t, err)
+	expected := sectionRevision([]string{"[[inputs.mock]]\n"},
+		[]pluginBlock{{section: "inputs", begin: 0, end: 1}}, "inputs")
+	require.Equal(t, expected, revisions["inputs"])
+}
+
+func TestUpdatePluginConfigAppendsMissingSection(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[agent]\n  interval = \"10s\"\n\n[[inputs.mock]]\n")
+	defer cleanup()
+
+	sections := map[string]string{
+		"outputs": "[[outputs.http]]\n",
+	}
+	_, err := updatePluginConfig(sections, map[string]string{"outputs": "abc"}, nil, dir, time.Second)
+	require.NoError(t, err)
+
+	actual := readTestConfig(t, dir)
+	require.True(t, strings.HasPrefix(actual, "[agent]\n  interval = \"10s\"\n\n[[inputs.mock]]\n\n# Revision: abc"), actual)
+	require.True(t, strings.HasSuffix(actual, "#\n[[outputs.http]]\n\n"), actual)
+}
+
+func TestDropUnchangedSections(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[inputs.mock]]\n  interval = \"5s\"\n\n[[outputs.http]]\n")
+	defer cleanup()
+
+	revisions, err := calculateConfigRevisions(dir)
+	require.NoError(t, err)
+
+	sections, err := splitConfigSections("[[inputs.mock]]\n\n  interval = \"5s\"\n[[outputs.http]]\n  url = \"http://localhost\"\n")
+	require.NoError(t, err)
+
+	require.Equal(t, 1, dropUnchangedSections(sections, revisions))
+	require.Contains(t, sections, "outputs")
+	require.NotContains(t, sections, "inputs")
+}
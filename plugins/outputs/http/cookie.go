@@ -0,0 +1,146 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// cookieAuthTransport logs in at auth_url and sends the session cookies it
+// receives with the requests it forwards to next.  It logs in again and
+// resends a request answered with 401 Unauthorized.
+type cookieAuthTransport struct {
+	next        http.RoundTripper
+	jar         http.CookieJar
+	url         string
+	method      string
+	body        string
+	contentType string
+
+	mu sync.Mutex
+	// logins counts successful logins, so that concurrent requests rejected
+	// with the same session log in only once
+	logins int
+}
+
+func (h *HTTP) newCookieAuthTransport(next http.RoundTripper) (*cookieAuthTransport, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &cookieAuthTransport{
+		next:        next,
+		jar:         jar,
+		url:         h.AuthURL,
+		method:      h.AuthMethod,
+		body:        h.AuthBody,
+		contentType: h.AuthContentType,
+	}, nil
+}
+
+// login sends the login request unless another one succeeded since the
+// session seen by the caller.
+func (t *cookieAuthTransport) login(ctx context.Context, seen int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.logins != seen {
+		return nil
+	}
+
+	req, err := http.NewRequest(t.method, t.url, strings.NewReader(t.body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	if t.body != "" {
+		req.Header.Set("Content-Type", t.contentType)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("when logging in to [%s] received status code: %d", t.url, resp.StatusCode)
+	}
+	t.jar.SetCookies(req.URL, resp.Cookies())
+	t.logins++
+	return nil
+}
+
+func (t *cookieAuthTransport) session() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.logins
+}
+
+// send forwards a copy of req carrying the session cookies.
+func (t *cookieAuthTransport) send(req *http.Request, body []byte) (*http.Response, error) {
+	withCookies := new(http.Request)
+	*withCookies = *req
+	withCookies.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		withCookies.Header[k] = append([]string(nil), v...)
+	}
+	for _, c := range t.jar.Cookies(req.URL) {
+		withCookies.AddCookie(c)
+	}
+	if body != nil {
+		withCookies.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := t.next.RoundTrip(withCookies)
+	if err != nil {
+		return nil, err
+	}
+	t.jar.SetCookies(req.URL, resp.Cookies())
+	return resp, nil
+}
+
+func (t *cookieAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the body is kept to resend the request after logging in again
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := t.session()
+	if seen == 0 {
+		err := t.login(req.Context(), seen)
+		if err != nil {
+			return nil, err
+		}
+		seen = t.session()
+	}
+
+	resp, err := t.send(req, body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	err = t.login(req.Context(), seen)
+	if err != nil {
+		return nil, err
+	}
+	return t.send(req, body)
+}
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCookieAuth(t *testing.T) {
+	logins := 0
+	session := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, "user=telegraf", string(body))
+			require.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+
+			logins++
+			session = fmt.Sprintf("s%d", logins)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: session})
+			w.WriteHeader(http.StatusOK)
+		case "/write":
+			c, err := r.Cookie("session")
+			if err != nil || c.Value != session {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), "cpu value=42")
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:      ts.URL + "/write",
+		AuthURL:  ts.URL + "/login",
+		AuthBody: "user=telegraf",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, 1, logins)
+
+	// the session expires, the write logs in again
+	session = "expired"
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, 2, logins)
+}
+
+func TestCookieAuthLoginFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:     ts.URL + "/write",
+		AuthURL: ts.URL + "/login",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	err := plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "logging in")
+}
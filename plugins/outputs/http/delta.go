@@ -0,0 +1,188 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Operations on single plugin tables the bridge can send in an envelope.
+const (
+	operationAdd    = "add"
+	operationUpdate = "update"
+	operationRemove = "remove"
+)
+
+// configOperation changes one plugin table of the config directory, keyed by
+// its plugin_id.
+type configOperation struct {
+	Op       string `json:"op"`
+	PluginID string `json:"plugin_id"`
+	// Config is the plugin table of add and update.
+	Config string `json:"config,omitempty"`
+}
+
+// check returns an error when an operation is malformed.
+func (op *configOperation) check() error {
+	if !validPluginID.MatchString(op.PluginID) {
+		return fmt.Errorf("invalid plugin_id %q", op.PluginID)
+	}
+
+	switch op.Op {
+	case operationAdd, operationUpdate:
+		if strings.TrimSpace(op.Config) == "" {
+			return fmt.Errorf("%s of %q has no config", op.Op, op.PluginID)
+		}
+	case operationRemove:
+		if op.Config != "" {
+			return fmt.Errorf("remove of %q has config", op.PluginID)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", op.Op)
+	}
+	return nil
+}
+
+// fragment returns the plugin table of an add or update.  The table is
+// given a "# plugin_id: <id>" comment when it has none, so that it matches
+// the same table sent in full plugin config.
+func (op *configOperation) fragment() (fragment, error) {
+	text := strings.Trim(op.Config, "\r\n") + "\n"
+	err := checkConfigTables(text)
+	if err != nil {
+		return fragment{}, fmt.Errorf("%s of %q: %s", op.Op, op.PluginID, err)
+	}
+	lines := splitLines(text)
+	blocks, err := pluginBlocks(lines)
+	if err != nil {
+		return fragment{}, fmt.Errorf("%s of %q: %s", op.Op, op.PluginID, err)
+	}
+	if len(blocks) != 1 {
+		return fragment{}, fmt.Errorf("%s of %q must hold exactly one plugin table", op.Op, op.PluginID)
+	}
+
+	hasID := false
+	for _, line := range lines {
+		if m := pluginIDRe.FindStringSubmatch(line); m != nil {
+			if m[1] != op.PluginID {
+				return fragment{}, fmt.Errorf("%s of %q has plugin_id %q", op.Op, op.PluginID, m[1])
+			}
+			hasID = true
+		}
+	}
+	if !hasID {
+		text = "# plugin_id: " + op.PluginID + "\n" + text
+	}
+	return fragment{section: blocks[0].section, text: text}, nil
+}
+
+// applyOperations returns the fragments resulting from applying ops in order
+// to current, which is left unchanged.  Adding an existing plugin_id, or
+// updating or removing a missing one, fails all operations.
+func applyOperations(current map[string]fragment, ops []configOperation) (map[string]fragment, error) {
+	fragments := make(map[string]fragment, len(current))
+	for id, f := range current {
+		fragments[id] = f
+	}
+
+	for _, op := range ops {
+		err := op.check()
+		if err != nil {
+			return nil, err
+		}
+
+		_, exists := fragments[op.PluginID]
+		switch {
+		case op.Op == operationAdd && exists:
+			return nil, fmt.Errorf("add of %q: plugin_id exists", op.PluginID)
+		case op.Op != operationAdd && !exists:
+			return nil, fmt.Errorf("%s of %q: no such plugin_id", op.Op, op.PluginID)
+		}
+
+		if op.Op == operationRemove {
+			delete(fragments, op.PluginID)
+			continue
+		}
+		f, err := op.fragment()
+		if err != nil {
+			return nil, err
+		}
+		fragments[op.PluginID] = f
+	}
+	return fragments, nil
+}
+
+// updateFragmentOperations applies ops to the fragment files of dir, and the
+// agent settings, when not nil, to telegraf.conf.  They are applied together:
+// the resulting config is validated before any file is replaced.  It returns
+// the resulting fragments and whether any file changed.
+func updateFragmentOperations(ops []configOperation, settings *agentSettings, configFilePath string, dir string, timeout time.Duration) (map[string]fragment, bool, error) {
+	err := os.Chdir(configFilePath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	lock, err := lockFile(configLockFile)
+	if err != nil {
+		return nil, false, err
+	}
+	defer unlockFile(lock)
+
+	current, err := readFragments(dir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	fragments, err := applyOperations(current, ops)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid config operations: %s", err)
+	}
+
+	conf, err := agentConfig(settings)
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed, err := replaceFragments(current, fragments, conf, dir, timeout)
+	return fragments, changed, err
+}
+
+// applyConfigOperations applies the config operations of an envelope, along
+// with its agent settings when not nil, returning whether the config changed.
+// Operations need config_directory, as plugin tables merged into
+// telegraf.conf have no plugin_id.
+func (h *HTTP) applyConfigOperations(ops []configOperation, settings *agentSettings) (bool, error) {
+	if h.ConfigDirectory == "" {
+		return false, h.rejectPluginConfig(nil, errors.New("config operations require config_directory"))
+	}
+
+	for _, op := range ops {
+		if op.Op == operationRemove {
+			continue
+		}
+		err := h.checkRemotePlugins(op.Config)
+		if err != nil {
+			return false, h.rejectPluginConfig(nil, err)
+		}
+	}
+
+	fragments, changed, err := updateFragmentOperations(ops, settings, h.ConfigFilePath, h.ConfigDirectory, h.ConfigValidationTimeout.Duration)
+	var sections map[string]string
+	if fragments != nil {
+		sections = fragmentSections(fragments)
+	}
+	if err != nil {
+		return false, h.rejectPluginConfig(sections, err)
+	}
+	if !changed {
+		log.Printf("D! No plugin config changes from [%s]", h.bridgeURL())
+		return false, nil
+	}
+
+	h.stats.configApplied()
+	h.reportConfigStatus(sections, nil)
+	return true, nil
+}
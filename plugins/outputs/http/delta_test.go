@@ -0,0 +1,164 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOperations(t *testing.T) {
+	current := map[string]fragment{
+		"cpu": {section: "inputs", text: "# plugin_id: cpu\n[[inputs.cpu]]\n"},
+		"mem": {section: "inputs", text: "# plugin_id: mem\n[[inputs.mem]]\n"},
+	}
+
+	fragments, err := applyOperations(current, []configOperation{
+		{Op: "add", PluginID: "disk", Config: "[[inputs.disk]]"},
+		{Op: "update", PluginID: "cpu", Config: "# plugin_id: cpu\n[[inputs.cpu]]\n  percpu = false\n"},
+		{Op: "remove", PluginID: "mem"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]fragment{
+		"cpu":  {section: "inputs", text: "# plugin_id: cpu\n[[inputs.cpu]]\n  percpu = false\n"},
+		"disk": {section: "inputs", text: "# plugin_id: disk\n[[inputs.disk]]\n"},
+	}, fragments)
+	require.Len(t, current, 2)
+}
+
+func TestApplyOperationsInvalid(t *testing.T) {
+	current := map[string]fragment{
+		"cpu": {section: "inputs", text: "# plugin_id: cpu\n[[inputs.cpu]]\n"},
+	}
+
+	tests := []struct {
+		name string
+		op   configOperation
+	}{
+		{
+			name: "unknown operation",
+			op:   configOperation{Op: "rename", PluginID: "cpu"},
+		},
+		{
+			name: "invalid plugin_id",
+			op:   configOperation{Op: "remove", PluginID: "../cpu"},
+		},
+		{
+			name: "add existing",
+			op:   configOperation{Op: "add", PluginID: "cpu", Config: "[[inputs.cpu]]"},
+		},
+		{
+			name: "update missing",
+			op:   configOperation{Op: "update", PluginID: "mem", Config: "[[inputs.mem]]"},
+		},
+		{
+			name: "remove missing",
+			op:   configOperation{Op: "remove", PluginID: "mem"},
+		},
+		{
+			name: "several tables",
+			op:   configOperation{Op: "update", PluginID: "cpu", Config: "[[inputs.cpu]]\n[[inputs.mem]]\n"},
+		},
+		{
+			name: "other plugin_id",
+			op:   configOperation{Op: "update", PluginID: "cpu", Config: "# plugin_id: mem\n[[inputs.cpu]]\n"},
+		},
+		{
+			name: "other top-level table",
+			op:   configOperation{Op: "update", PluginID: "cpu", Config: "[[inputs.cpu]]\n[exec]\n  commands = [\"id\"]\n"},
+		},
+		{
+			name: "no config",
+			op:   configOperation{Op: "add", PluginID: "mem"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := applyOperations(current, []configOperation{tt.op})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestUpdateFragmentOperations(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	fragmentDir := filepath.Join(dir, "telegraf.d")
+	require.NoError(t, os.Mkdir(fragmentDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fragmentDir, "old.conf"), []byte("# plugin_id: old\n[[inputs.mock]]\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fragmentDir, "kept.conf"), []byte("# plugin_id: kept\n[[inputs.mock]]\n"), 0644))
+
+	// a failing operation leaves every file alone
+	_, _, err := updateFragmentOperations([]configOperation{
+		{Op: "remove", PluginID: "old"},
+		{Op: "add", PluginID: "broken", Config: "[[inputs.doesnotexist]]"},
+	}, nil, dir, "telegraf.d", time.Second)
+	require.Error(t, err)
+
+	files, err := filepath.Glob(filepath.Join(fragmentDir, "*"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(fragmentDir, "kept.conf"),
+		filepath.Join(fragmentDir, "old.conf"),
+	}, files)
+
+	fragments, changed, err := updateFragmentOperations([]configOperation{
+		{Op: "remove", PluginID: "old"},
+		{Op: "add", PluginID: "new", Config: "[[inputs.mock]]\n  interval = \"5s\""},
+	}, nil, dir, "telegraf.d", time.Second)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Len(t, fragments, 2)
+
+	files, err = filepath.Glob(filepath.Join(fragmentDir, "*"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(fragmentDir, "kept.conf"),
+		filepath.Join(fragmentDir, "new.conf"),
+	}, files)
+
+	buf, err := ioutil.ReadFile(filepath.Join(fragmentDir, "new.conf"))
+	require.NoError(t, err)
+	require.Equal(t, "# plugin_id: new\n[[inputs.mock]]\n  interval = \"5s\"\n", string(buf))
+}
+
+func TestConfigOperationsRequireConfigDirectory(t *testing.T) {
+	plugin := &HTTP{URL: "http://127.0.0.1:8080/telegraf"}
+	changed, err := plugin.applyConfigOperations([]configOperation{{Op: "remove", PluginID: "cpu"}}, nil)
+	require.Error(t, err)
+	require.False(t, changed)
+}
+
+func TestConfigOperationsAllowedRemotePlugins(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "telegraf.d"), 0755))
+
+	plugin := &HTTP{
+		URL:                  "http://127.0.0.1:8080/telegraf",
+		ConfigFilePath:       dir,
+		ConfigDirectory:      "telegraf.d",
+		AllowedRemotePlugins: []string{"inputs.cpu"},
+	}
+	remoteFilter, err := filter.Compile(plugin.AllowedRemotePlugins)
+	require.NoError(t, err)
+	plugin.remoteFilter = remoteFilter
+
+	// a legacy table is loaded as an input that is not allowed
+	changed, err := plugin.applyConfigOperations([]configOperation{
+		{Op: "add", PluginID: "cpu", Config: "[[inputs.cpu]]\n[exec]\n  commands = [\"id\"]\n"},
+	}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tables not allowed: exec")
+	require.False(t, changed)
+
+	files, err := filepath.Glob(filepath.Join(dir, "telegraf.d", "*"))
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
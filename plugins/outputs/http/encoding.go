@@ -0,0 +1,81 @@
+package http
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/klauspost/compress/zstd"
+)
+
+// checkContentEncoding returns an error for an unsupported content_encoding
+// and creates the zstd encoder when needed.
+func (h *HTTP) checkContentEncoding() error {
+	switch h.ContentEncoding {
+	case "", "identity", "gzip", "snappy":
+		return nil
+	case "zstd":
+		if h.zstdEncoder == nil {
+			encoder, err := zstd.NewWriter(nil)
+			if err != nil {
+				return err
+			}
+			h.zstdEncoder = encoder
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid content_encoding %q", h.ContentEncoding)
+}
+
+// isEncoded reports whether request bodies are compressed, so that they carry
+// a Content-Encoding header.
+func (h *HTTP) isEncoded() bool {
+	return h.ContentEncoding != "" && h.ContentEncoding != "identity"
+}
+
+// encodeBody applies content_encoding to a request body.  Snappy uses the
+// block format, as a whole body is always compressed at once.
+func (h *HTTP) encodeBody(body []byte) ([]byte, error) {
+	switch h.ContentEncoding {
+	case "gzip":
+		r, err := internal.CompressWithGzip(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case "zstd":
+		return h.zstdEncoder.EncodeAll(body, nil), nil
+	case "snappy":
+		return snappy.Encode(nil, body), nil
+	}
+	return body, nil
+}
+
+// checkPayloadChecksum returns an error for an unsupported payload_checksum.
+func (h *HTTP) checkPayloadChecksum() error {
+	switch h.PayloadChecksum {
+	case "", "md5", "sha256":
+		return nil
+	}
+	return fmt.Errorf("invalid payload_checksum %q", h.PayloadChecksum)
+}
+
+// setPayloadChecksum adds the payload_checksum header of an encoded request
+// body to req.
+func (h *HTTP) setPayloadChecksum(req *http.Request, body []byte) {
+	switch h.PayloadChecksum {
+	case "md5":
+		sum := md5.Sum(body)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+	case "sha256":
+		sum := sha256.Sum256(body)
+		req.Header.Set("x-content-sha256", hex.EncodeToString(sum[:]))
+	}
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// protocolHeader negotiates the bridge protocol.  Requests offer version 2;
+// a bridge answering with version 2 sets it on the response, any other
+// response is handled as version 1, where the body is plugin config.
+const protocolHeader = "X-Bridge-Protocol"
+
+// envelope is a response of the bridge with protocol version 2.
+type envelope struct {
+	Version int `json:"version"`
+	// Sections holds plugin config keyed by section, for example "inputs".
+	// Sections that are left out are not changed.
+	Sections map[string]string `json:"sections,omitempty"`
+	// Operations change single plugin tables of the config directory, in
+	// place of sending whole sections.
+	Operations []configOperation `json:"operations,omitempty"`
+	// Commands for the agent to run.
+	Commands []bridgeCommand `json:"commands,omitempty"`
+	// Agent holds settings of the agent itself.
+	Agent *agentSettings `json:"agent,omitempty"`
+	// Metadata is informational, such as the revision of the config on the
+	// bridge.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// bridgeCommand is a command sent by the bridge in an envelope.
+type bridgeCommand struct {
+	// ID identifies the command, which is only run once, and is returned
+	// with its result.
+	ID string `json:"id,omitempty"`
+	// Time is when the bridge issued the command, in RFC 3339 format.  The
+	// command expires command_max_age after it.
+	Time string            `json:"time,omitempty"`
+	Name string            `json:"name"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// parseEnvelope decodes and checks an envelope.
+func parseEnvelope(body []byte) (*envelope, error) {
+	var env envelope
+	err := json.Unmarshal(body, &env)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.Version != 2 {
+		return nil, fmt.Errorf("unsupported version %d", env.Version)
+	}
+	for section := range env.Sections {
+		if !isConfigSection(section) {
+			return nil, fmt.Errorf("unknown config section %q", section)
+		}
+	}
+	if len(env.Sections) > 0 && len(env.Operations) > 0 {
+		return nil, fmt.Errorf("sections and operations cannot be combined")
+	}
+	return &env, nil
+}
+
+// pluginConfig joins the sections of an envelope into plugin config.
+func (env *envelope) pluginConfig() string {
+	var parts []string
+	for _, section := range configSections {
+		if text := strings.Trim(env.Sections[section], "\r\n"); text != "" {
+			parts = append(parts, text+"\n")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func isConfigSection(name string) bool {
+	for _, section := range configSections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnvelope handles a protocol 2 response of the bridge.  The signature
+// covers the whole envelope.  Its commands are only run once the rest of it
+// is applied, and not at all when it is rejected.
+func (h *HTTP) applyEnvelope(body []byte, signature string, revisions map[string]string) error {
+	log.Printf("D! [outputs.http] Bridge response received : >>%s<<", string(body))
+
+	err := h.verifyConfigSignature(body, signature)
+	if err != nil {
+		return h.rejectPluginConfig(nil, err)
+	}
+
+	env, err := parseEnvelope(body)
+	if err != nil {
+		return h.rejectPluginConfig(nil, fmt.Errorf("invalid bridge response: %s", err))
+	}
+	if len(env.Metadata) > 0 {
+		log.Printf("D! [outputs.http] Bridge metadata : %v", env.Metadata)
+	}
+
+	changed, err := h.applyEnvelopeConfig(env, revisions)
+	if err != nil {
+		h.skipCommands(env.Commands, err)
+		return err
+	}
+
+	restart := h.runCommands(env.Commands)
+
+	if !changed && !restart {
+		return nil
+	}
+
+	// restart Telegraf to load the new config
+	return reloadConfig()
+}
+
+// applyEnvelopeConfig applies the agent settings and the plugin config of an
+// envelope, returning whether the config changed.  Agent settings sent along
+// with plugin config are written with it, so that neither is applied when the
+// other is rejected.
+func (h *HTTP) applyEnvelopeConfig(env *envelope, revisions map[string]string) (bool, error) {
+	if env.Agent != nil {
+		err := env.Agent.check()
+		if err != nil {
+			return false, h.rejectPluginConfig(nil, fmt.Errorf("agent settings rejected: %s", err))
+		}
+	}
+
+	if pluginConfig := env.pluginConfig(); pluginConfig != "" {
+		return h.applyPluginConfig(pluginConfig, revisions, env.Agent)
+	}
+	if len(env.Operations) > 0 {
+		return h.applyConfigOperations(env.Operations, env.Agent)
+	}
+	if env.Agent == nil {
+		return false, nil
+	}
+
+	changed, err := updateAgentSettings(env.Agent, h.ConfigFilePath, h.ConfigDirectory,
+		h.ConfigValidationTimeout.Duration)
+	if err != nil {
+		return false, h.rejectPluginConfig(nil, fmt.Errorf("agent settings rejected: %s", err))
+	}
+	if changed {
+		h.reportConfigStatus(nil, nil)
+	}
+	return changed, nil
+}
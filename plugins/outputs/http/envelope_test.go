@@ -0,0 +1,141 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnvelope(t *testing.T) {
+	env, err := parseEnvelope([]byte(`{
+		"version": 2,
+		"sections": {
+			"inputs": "[[inputs.cpu]]\n",
+			"outputs": "[[outputs.file]]"
+		},
+		"commands": [{"name": "noop"}],
+		"metadata": {"revision": "42"}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, "[[outputs.file]]\n\n[[inputs.cpu]]\n", env.pluginConfig())
+	require.Equal(t, []bridgeCommand{{Name: "noop"}}, env.Commands)
+	require.Equal(t, "42", env.Metadata["revision"])
+}
+
+func TestParseEnvelopeInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "not json",
+			body: "[[inputs.cpu]]\n",
+		},
+		{
+			name: "wrong version",
+			body: `{"version": 3}`,
+		},
+		{
+			name: "unknown section",
+			body: `{"version": 2, "sections": {"agent": "interval = \"1s\""}}`,
+		},
+		{
+			name: "sections and operations",
+			body: `{"version": 2, "sections": {"inputs": "[[inputs.cpu]]"}, "operations": [{"op": "remove", "plugin_id": "cpu"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseEnvelope([]byte(tt.body))
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestEnvelopeResponse(t *testing.T) {
+	var offered string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offered = r.Header.Get(protocolHeader)
+		w.Header().Set(protocolHeader, "2")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"version": 2, "sections": {"inputs": "[[inputs.exec]]\n"}}`))
+	}))
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:         true,
+		ConfigFilePath:       dir,
+		URL:                  ts.URL,
+		AllowedRemotePlugins: []string{"inputs.cpu"},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	err := plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "plugins not allowed: inputs.exec")
+	require.Equal(t, "2", offered)
+}
+
+func TestEnvelopeRejectedCommands(t *testing.T) {
+	var results []commandResult
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result commandResult
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+		results = append(results, result)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:         true,
+		ConfigFilePath:       dir,
+		URL:                  ts.URL,
+		ConfigHMACKey:        "secret",
+		CommandStatusURL:     ts.URL,
+		CommandMaxAge:        internal.Duration{Duration: time.Minute},
+		AllowedRemotePlugins: []string{"inputs.cpu"},
+	}
+	require.NoError(t, plugin.Connect())
+
+	body := []byte(fmt.Sprintf(`{
+		"version": 2,
+		"sections": {"inputs": "[[inputs.exec]]\n"},
+		"commands": [{"id": "1", "time": %q, "name": "restart"}]
+	}`, time.Now().Format(time.RFC3339)))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	// the restart is neither run nor acknowledged
+	err := plugin.applyEnvelope(body, signature, nil)
+	require.Error(t, err)
+	require.Len(t, results, 1)
+	require.False(t, results[0].Success)
+	require.Contains(t, results[0].Error, "envelope rejected")
+
+	// the bridge can send the command again
+	_, err = os.Stat(filepath.Join(dir, defaultCommandsFile))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusError is returned when the bridge answers with an unexpected status.
+type statusError struct {
+	action     string
+	url        string
+	statusCode int
+	// retryAfter is the delay asked for with a Retry-After header
+	retryAfter time.Duration
+}
+
+func newStatusError(action string, url string, resp *http.Response) *statusError {
+	e := &statusError{action: action, url: url, statusCode: resp.StatusCode}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		e.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return e
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, given either
+// in seconds or as a date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("when %s [%s] received status code: %d", e.action, e.url, e.statusCode)
+}
+
+// isFailoverError reports whether err means the bridge is unavailable, so
+// the request should go to another one.  Failing to get an OAuth2 token is
+// not the fault of the bridge.  Over gRPC, the codes of a bridge that cannot
+// be reached or does not answer in time count.
+func isFailoverError(err error) bool {
+	switch e := err.(type) {
+	case *url.Error:
+		return !isTokenError(err)
+	case *statusError:
+		return e.statusCode >= 500
+	}
+	if s, ok := status.FromError(err); ok && err != nil {
+		switch s.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+			return true
+		}
+	}
+	return false
+}
+
+// failover picks which of several bridges requests are sent to.  Requests
+// go to the active bridge, moving down the list when it fails.  Once moved
+// away from the first bridge, it is tried first again every failback
+// interval.  Bridges failing their health check are tried last.
+type failover struct {
+	urls     []string
+	failback time.Duration
+
+	mu        sync.Mutex
+	active    int
+	lastProbe time.Time
+	unhealthy []bool
+}
+
+func newFailover(urls []string, failback time.Duration) *failover {
+	return &failover{
+		urls:      urls,
+		failback:  failback,
+		unhealthy: make([]bool, len(urls)),
+	}
+}
+
+// order returns the indexes of the bridges in the order to try them for the
+// next request.
+func (f *failover) order() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order := make([]int, 0, len(f.urls))
+	probe := f.active != 0 && !f.unhealthy[0] && time.Since(f.lastProbe) >= f.failback
+	if probe {
+		f.lastProbe = time.Now()
+		order = append(order, 0)
+	}
+	var unhealthy []int
+	for i := range f.urls {
+		j := (f.active + i) % len(f.urls)
+		switch {
+		case j == 0 && probe:
+		case f.unhealthy[j]:
+			unhealthy = append(unhealthy, j)
+		default:
+			order = append(order, j)
+		}
+	}
+	return append(order, unhealthy...)
+}
+
+// setHealthy records the result of the health check of the bridge at index
+// i.
+func (f *failover) setHealthy(i int, healthy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unhealthy[i] = !healthy
+}
+
+// use makes the bridge at index i the active one.
+func (f *failover) use(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if i == f.active {
+		return
+	}
+	log.Printf("I! [outputs.http] Switching from [%s] to [%s]", f.urls[f.active], f.urls[i])
+	if f.active == 0 {
+		f.lastProbe = time.Now()
+	}
+	f.active = i
+}
+
+func (f *failover) activeURL() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.urls[f.active]
+}
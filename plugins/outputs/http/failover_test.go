@@ -0,0 +1,91 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverOrder(t *testing.T) {
+	f := newFailover([]string{"a", "b", "c"}, time.Hour)
+	require.Equal(t, []int{0, 1, 2}, f.order())
+
+	f.use(1)
+	require.Equal(t, "b", f.activeURL())
+	require.Equal(t, []int{1, 2, 0}, f.order())
+
+	// probe the first bridge once the failback interval has passed
+	f.lastProbe = time.Now().Add(-2 * time.Hour)
+	require.Equal(t, []int{0, 1, 2}, f.order())
+	require.Equal(t, []int{1, 2, 0}, f.order())
+}
+
+func TestFailover(t *testing.T) {
+	var primaryDown bool
+	var hits []string
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, "primary")
+		if primaryDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits = append(hits, "secondary")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer secondary.Close()
+
+	plugin := &HTTP{
+		URLs:             []string{primary.URL, secondary.URL},
+		FailbackInterval: internal.Duration{Duration: time.Hour},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+
+	primaryDown = true
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, secondary.URL, plugin.bridgeURL())
+
+	// fail back once the primary answers a probe
+	primaryDown = false
+	plugin.failover.lastProbe = time.Time{}
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, primary.URL, plugin.bridgeURL())
+
+	require.Equal(t, []string{"primary", "primary", "secondary", "secondary", "primary"}, hits)
+}
+
+func TestFailoverKeepsClientErrors(t *testing.T) {
+	var secondaryHit bool
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryHit = true
+	}))
+	defer secondary.Close()
+
+	plugin := &HTTP{
+		URLs: []string{primary.URL, secondary.URL},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.Error(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.False(t, secondaryHit)
+}
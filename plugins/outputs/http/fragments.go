@@ -0,0 +1,275 @@
+package http
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/validate"
+)
+
+const fragmentExt = ".conf"
+
+// pluginIDRe matches the comment giving the id of a plugin table sent by the
+// bridge, for example "# plugin_id: cpu-1".
+var pluginIDRe = regexp.MustCompile(`^\s*#\s*plugin_id:\s*(\S+)\s*$`)
+
+// validPluginID restricts ids so they can be used as file names.
+var validPluginID = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+// fragment is a plugin table stored in its own file of the config directory.
+type fragment struct {
+	section string
+	text    string
+}
+
+// splitFragments splits a config body received from the bridge into its
+// plugin tables, keyed by the id given in a "# plugin_id: <id>" comment
+// above each table.
+func splitFragments(body string) (map[string]fragment, error) {
+	lines := splitLines(body)
+	blocks, err := pluginBlocks(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	fragments := make(map[string]fragment, len(blocks))
+	for _, block := range blocks {
+		id := ""
+		for _, line := range lines[block.begin:block.end] {
+			if m := pluginIDRe.FindStringSubmatch(line); m != nil {
+				id = m[1]
+				break
+			}
+		}
+
+		switch {
+		case id == "":
+			return nil, fmt.Errorf("%s.%s has no plugin_id", block.section, block.name)
+		case !validPluginID.MatchString(id):
+			return nil, fmt.Errorf("invalid plugin_id %q", id)
+		}
+		if _, ok := fragments[id]; ok {
+			return nil, fmt.Errorf("duplicate plugin_id %q", id)
+		}
+
+		fragments[id] = fragment{
+			section: block.section,
+			text:    strings.Join(lines[block.begin:block.end], ""),
+		}
+	}
+	return fragments, nil
+}
+
+// fragmentIDs returns the ids of fragments in sorted order.
+func fragmentIDs(fragments map[string]fragment) []string {
+	ids := make([]string, 0, len(fragments))
+	for id := range fragments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// fragmentSections joins the text of fragments by section, ordered by id.
+func fragmentSections(fragments map[string]fragment) map[string]string {
+	sections := make(map[string]string)
+	for _, id := range fragmentIDs(fragments) {
+		f := fragments[id]
+		sections[f.section] += f.text
+	}
+	return sections
+}
+
+// readFragments reads the fragment files of dir.  A missing directory has no
+// fragments.
+func readFragments(dir string) (map[string]fragment, error) {
+	fragments := make(map[string]fragment)
+
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return fragments, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fragmentExt) {
+			continue
+		}
+
+		lines, err := readConfigLines(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		blocks, err := pluginBlocks(lines)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.Name(), err)
+		}
+
+		f := fragment{text: strings.Join(lines, "")}
+		if len(blocks) > 0 {
+			f.section = blocks[0].section
+		}
+		fragments[strings.TrimSuffix(file.Name(), fragmentExt)] = f
+	}
+	return fragments, nil
+}
+
+// calculateFragmentRevisions returns the revision of every managed section
+// of the fragments in dir, keyed by section name.  The text of a section is
+// its fragments joined in order of id.
+func calculateFragmentRevisions(configFilePath string, dir string) (map[string]string, error) {
+	revisions := make(map[string]string)
+
+	err := os.Chdir(configFilePath)
+	if err != nil {
+		return revisions, err
+	}
+
+	lock, err := lockFile(configLockFile)
+	if err != nil {
+		return revisions, err
+	}
+	defer unlockFile(lock)
+
+	fragments, err := readFragments(dir)
+	if err != nil {
+		return revisions, err
+	}
+
+	sections := fragmentSections(fragments)
+	for _, section := range configSections {
+		revisions[section] = textRevision(sections[section])
+	}
+
+	log.Printf("D! Config revisions : %v", revisions)
+	return revisions, nil
+}
+
+// updateFragments makes the fragment files of dir match fragments: new and
+// changed fragments are written and fragments that are no longer sent are
+// removed.  The agent settings, when not nil, are applied to telegraf.conf.
+// The resulting config, telegraf.conf together with the fragments, is
+// validated before any file is replaced.  It returns false when nothing
+// changed.
+func updateFragments(fragments map[string]fragment, settings *agentSettings, configFilePath string, dir string, timeout time.Duration) (bool, error) {
+	err := os.Chdir(configFilePath)
+	if err != nil {
+		return false, err
+	}
+
+	lock, err := lockFile(configLockFile)
+	if err != nil {
+		return false, err
+	}
+	defer unlockFile(lock)
+
+	current, err := readFragments(dir)
+	if err != nil {
+		return false, err
+	}
+	conf, err := agentConfig(settings)
+	if err != nil {
+		return false, err
+	}
+	return replaceFragments(current, fragments, conf, dir, timeout)
+}
+
+// replaceFragments replaces the current fragments of dir with fragments, and
+// telegraf.conf with conf when not nil, once the resulting config passes
+// validation.  It must be called from the config directory with the config
+// lock held.
+func replaceFragments(current map[string]fragment, fragments map[string]fragment, conf []string, dir string, timeout time.Duration) (bool, error) {
+	var changed, removed []string
+	for _, id := range fragmentIDs(fragments) {
+		if f, ok := current[id]; !ok || f.text != fragments[id].text {
+			changed = append(changed, id)
+		}
+	}
+	for _, id := range fragmentIDs(current) {
+		if _, ok := fragments[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	if len(changed) == 0 && len(removed) == 0 && conf == nil {
+		return false, nil
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return false, err
+	}
+
+	fragmentPath := func(id string) string {
+		return filepath.Join(dir, id+fragmentExt)
+	}
+
+	// stage the changed files next to the current ones
+	var staged []string
+	removeStaged := func() {
+		for _, path := range staged {
+			os.Remove(path)
+		}
+	}
+	confPath := "telegraf.conf"
+	if conf != nil {
+		confPath = "telegraf.conf.new"
+		err = ioutil.WriteFile(confPath, []byte(strings.Join(conf, "")), 0644)
+		if err != nil {
+			return false, err
+		}
+		staged = append(staged, confPath)
+	}
+	for _, id := range changed {
+		path := fragmentPath(id) + ".new"
+		err = ioutil.WriteFile(path, []byte(fragments[id].text), 0644)
+		if err != nil {
+			removeStaged()
+			return false, err
+		}
+		staged = append(staged, path)
+	}
+
+	// make sure the new config works before replacing the running one
+	paths := []string{confPath}
+	for _, id := range fragmentIDs(fragments) {
+		path := fragmentPath(id)
+		if f, ok := current[id]; !ok || f.text != fragments[id].text {
+			path += ".new"
+		}
+		paths = append(paths, path)
+	}
+	err = validate.Configs(paths, timeout)
+	if err != nil {
+		removeStaged()
+		return false, err
+	}
+
+	if conf != nil {
+		err = os.Rename(confPath, "telegraf.conf")
+		if err != nil {
+			return true, err
+		}
+	}
+	for _, id := range changed {
+		err = os.Rename(fragmentPath(id)+".new", fragmentPath(id))
+		if err != nil {
+			return true, err
+		}
+	}
+	for _, id := range removed {
+		err = os.Remove(fragmentPath(id))
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
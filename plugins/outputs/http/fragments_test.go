@@ -0,0 +1,109 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitFragments(t *testing.T) {
+	fragments, err := splitFragments("# plugin_id: cpu-1\n" +
+		"[[inputs.cpu]]\n" +
+		"  percpu = true\n" +
+		"\n" +
+		"# Write to a file\n" +
+		"# plugin_id: file\n" +
+		"[[outputs.file]]\n")
+	require.NoError(t, err)
+	require.Equal(t, map[string]fragment{
+		"cpu-1": {section: "inputs", text: "# plugin_id: cpu-1\n[[inputs.cpu]]\n  percpu = true\n"},
+		"file":  {section: "outputs", text: "# Write to a file\n# plugin_id: file\n[[outputs.file]]\n"},
+	}, fragments)
+}
+
+func TestSplitFragmentsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "missing id",
+			body: "[[inputs.cpu]]\n",
+		},
+		{
+			name: "duplicate id",
+			body: "# plugin_id: a\n[[inputs.cpu]]\n# plugin_id: a\n[[inputs.mem]]\n",
+		},
+		{
+			name: "id is a path",
+			body: "# plugin_id: ../telegraf\n[[inputs.cpu]]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := splitFragments(tt.body)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestUpdateFragments(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	fragmentDir := filepath.Join(dir, "telegraf.d")
+	require.NoError(t, os.Mkdir(fragmentDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fragmentDir, "old.conf"), []byte("[[inputs.mock]]\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fragmentDir, "kept.conf"), []byte("# plugin_id: kept\n[[inputs.mock]]\n"), 0644))
+
+	fragments, err := splitFragments("# plugin_id: kept\n[[inputs.mock]]\n\n# plugin_id: new\n[[inputs.mock]]\n  interval = \"5s\"\n")
+	require.NoError(t, err)
+
+	changed, err := updateFragments(fragments, nil, dir, "telegraf.d", time.Second)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	files, err := filepath.Glob(filepath.Join(fragmentDir, "*"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(fragmentDir, "kept.conf"),
+		filepath.Join(fragmentDir, "new.conf"),
+	}, files)
+
+	buf, err := ioutil.ReadFile(filepath.Join(fragmentDir, "new.conf"))
+	require.NoError(t, err)
+	require.Equal(t, "# plugin_id: new\n[[inputs.mock]]\n  interval = \"5s\"\n", string(buf))
+
+	// sending the same tables again changes nothing
+	changed, err = updateFragments(fragments, nil, dir, "telegraf.d", time.Second)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	revisions, err := calculateFragmentRevisions(dir, "telegraf.d")
+	require.NoError(t, err)
+	require.Equal(t, textRevision(fragmentSections(fragments)["inputs"]), revisions["inputs"])
+}
+
+func TestUpdateFragmentsRejectsInvalid(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	fragmentDir := filepath.Join(dir, "telegraf.d")
+	require.NoError(t, os.Mkdir(fragmentDir, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(fragmentDir, "mock.conf"), []byte("[[inputs.mock]]\n"), 0644))
+
+	fragments, err := splitFragments("# plugin_id: broken\n[[inputs.doesnotexist]]\n")
+	require.NoError(t, err)
+
+	_, err = updateFragments(fragments, nil, dir, "telegraf.d", time.Second)
+	require.Error(t, err)
+
+	files, err := filepath.Glob(filepath.Join(fragmentDir, "*"))
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(fragmentDir, "mock.conf")}, files)
+}
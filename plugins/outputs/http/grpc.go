@@ -0,0 +1,168 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs/http/bridge"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+// connectGRPC dials the bridge at grpc_address.  The connection is
+// established in the background, failures are returned by the calls made
+// over it.
+func (h *HTTP) connectGRPC() error {
+	tlsCfg, err := h.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	opts := []grpc.DialOption{grpc.WithUserAgent("Telegraf/" + internal.Version())}
+	if tlsCfg != nil {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg)))
+	} else {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	if h.localAddr != nil {
+		dialer := h.newDialer()
+		opts = append(opts, grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			d := *dialer
+			d.Timeout = timeout
+			return d.Dial("tcp", addr)
+		}))
+	}
+	if h.MaxResponseSize.Size > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(int(h.MaxResponseSize.Size))))
+	}
+
+	conn, err := grpc.Dial(h.GRPCAddress, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s: %s", h.GRPCAddress, err)
+	}
+	h.grpcConn = conn
+	h.grpcClient = bridge.NewBridgeClient(conn)
+	return nil
+}
+
+func (h *HTTP) closeGRPC() {
+	h.grpcMu.Lock()
+	h.resetGRPCStream()
+	h.grpcMu.Unlock()
+
+	if h.grpcConn != nil {
+		h.grpcConn.Close()
+		h.grpcConn = nil
+	}
+}
+
+// grpcContext adds the credentials and headers of the plugin to ctx as
+// request metadata.
+func (h *HTTP) grpcContext(ctx context.Context) context.Context {
+	md := metadata.MD{}
+	if h.Username != "" || h.Password != "" {
+		md.Set("authorization", basicAuth(h.Username, h.Password))
+	}
+	for k, v := range h.Headers {
+		md.Set(strings.ToLower(k), v)
+	}
+	if h.identity != nil {
+		md.Set(strings.ToLower(agentIDHeader), h.identity.AgentID)
+		if token := h.identity.token(); token != "" {
+			md.Set(strings.ToLower(agentTokenHeader), token)
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// writeGRPC sends a batch of metrics over the WriteMetrics stream and waits
+// for the bridge to acknowledge it.  The stream is opened again on the next
+// write after any failure.
+func (h *HTTP) writeGRPC(reqBody []byte) error {
+	h.grpcMu.Lock()
+	defer h.grpcMu.Unlock()
+
+	if h.grpcStream == nil {
+		ctx, cancel := context.WithCancel(h.grpcContext(context.Background()))
+		stream, err := h.grpcClient.WriteMetrics(ctx)
+		if err != nil {
+			cancel()
+			return err
+		}
+		h.grpcStream = stream
+		h.cancelGRPCStream = cancel
+	}
+
+	// give up on the stream when the bridge does not answer in time
+	timer := time.AfterFunc(h.Timeout.Duration, h.cancelGRPCStream)
+	defer timer.Stop()
+
+	err := h.grpcStream.Send(&bridge.WriteRequest{
+		Source:  h.SourceAddress,
+		Metrics: reqBody,
+	})
+	if err == io.EOF {
+		// the stream is broken, its status tells why
+		_, err = h.grpcStream.Recv()
+	}
+	if err != nil {
+		h.resetGRPCStream()
+		return err
+	}
+
+	resp, err := h.grpcStream.Recv()
+	if err != nil {
+		h.resetGRPCStream()
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("when writing to [%s] received error: %s", h.GRPCAddress, resp.Error)
+	}
+	return nil
+}
+
+func (h *HTTP) resetGRPCStream() {
+	if h.cancelGRPCStream != nil {
+		h.cancelGRPCStream()
+	}
+	h.grpcStream = nil
+	h.cancelGRPCStream = nil
+}
+
+// getConfigGRPC requests plugin config from the bridge with GetConfig.
+func (h *HTTP) getConfigGRPC() error {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+
+	revisions, err := h.configRevisions()
+	if err != nil {
+		return fmt.Errorf("reading plugin config revisions: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(h.grpcContext(context.Background()), h.Timeout.Duration)
+	defer cancel()
+
+	resp, err := h.grpcClient.GetConfig(ctx, &bridge.ConfigRequest{
+		Source:      h.SourceAddress,
+		Revisions:   revisions,
+		ConfigError: h.configError,
+		Etag:        h.etagFor(revisions),
+	})
+	if err != nil {
+		return err
+	}
+
+	// the bridge has been told about the last rejected config
+	h.configError = ""
+
+	if resp.NotModified {
+		return nil
+	}
+	return h.applyConfig([]byte(resp.Config), resp.Signature, resp.Etag, revisions, 1)
+}
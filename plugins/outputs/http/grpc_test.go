@@ -0,0 +1,209 @@
+package http
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs/http/bridge"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type bridgeServer struct {
+	batches  chan *bridge.WriteRequest
+	requests chan *bridge.ConfigRequest
+	users    chan []string
+}
+
+func (s *bridgeServer) WriteMetrics(stream bridge.Bridge_WriteMetricsServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.users <- md.Get("authorization")
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.batches <- req
+
+		resp := &bridge.WriteResponse{}
+		if len(s.batches) > 1 {
+			resp.Error = "queue full"
+		}
+		err = stream.Send(resp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *bridgeServer) GetConfig(ctx context.Context, req *bridge.ConfigRequest) (*bridge.ConfigResponse, error) {
+	s.requests <- req
+	if req.Etag == "v1" {
+		return &bridge.ConfigResponse{NotModified: true}, nil
+	}
+	return &bridge.ConfigResponse{Config: "[[inputs.mock]]\n", Etag: "v1"}, nil
+}
+
+// flakyBridgeServer fails the stream a batch is sent on while failures is
+// above zero.
+type flakyBridgeServer struct {
+	bridgeServer
+	failures int32
+	attempts int32
+}
+
+func (s *flakyBridgeServer) WriteMetrics(stream bridge.Bridge_WriteMetricsServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		atomic.AddInt32(&s.attempts, 1)
+		if atomic.AddInt32(&s.failures, -1) >= 0 {
+			return status.Error(codes.Unavailable, "bridge busy")
+		}
+		s.batches <- req
+
+		err = stream.Send(&bridge.WriteResponse{})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n\n[[inputs.mock]]\n")
+	defer cleanup()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &bridgeServer{
+		batches:  make(chan *bridge.WriteRequest, 2),
+		requests: make(chan *bridge.ConfigRequest, 2),
+		users:    make(chan []string, 1),
+	}
+	grpcServer := grpc.NewServer()
+	bridge.RegisterBridgeServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	plugin := &HTTP{
+		ManageConfig:   true,
+		GRPCAddress:    listener.Addr().String(),
+		ConfigFilePath: dir,
+		SourceAddress:  "10.0.0.1",
+		Username:       "telegraf",
+		Password:       "secret",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// config is requested when connecting
+	req := <-server.requests
+	require.Equal(t, "10.0.0.1", req.Source)
+	require.Len(t, req.Revisions, 4)
+	require.Empty(t, req.Etag)
+
+	require.NoError(t, plugin.pollConfig())
+	require.Equal(t, "v1", (<-server.requests).Etag)
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	batch := <-server.batches
+	require.Equal(t, "10.0.0.1", batch.Source)
+	require.Contains(t, string(batch.Metrics), "cpu value=42")
+	require.Equal(t, []string{basicAuth("telegraf", "secret")}, <-server.users)
+
+	// errors reported by the bridge fail the write
+	server.batches <- nil
+	err = plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "queue full")
+}
+
+func TestGRPCConfigRevisionsError(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[inputs.cpu]\n")
+	defer cleanup()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &bridgeServer{
+		requests: make(chan *bridge.ConfigRequest, 1),
+	}
+	grpcServer := grpc.NewServer()
+	bridge.RegisterBridgeServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	plugin := &HTTP{
+		ManageConfig:   true,
+		GRPCAddress:    listener.Addr().String(),
+		ConfigFilePath: dir,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	err = plugin.pollConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "reading plugin config revisions")
+	require.Len(t, server.requests, 0)
+}
+
+func TestGRPCWriteRetry(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &flakyBridgeServer{
+		bridgeServer: bridgeServer{batches: make(chan *bridge.WriteRequest, 1)},
+		failures:     1,
+	}
+	grpcServer := grpc.NewServer()
+	bridge.RegisterBridgeServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+
+	plugin := &HTTP{
+		GRPCAddress:             listener.Addr().String(),
+		RetryMaxAttempts:        2,
+		RetryInitialBackoff:     internal.Duration{Duration: time.Millisecond},
+		RetryMaxBackoff:         internal.Duration{Duration: time.Millisecond},
+		CircuitBreakerThreshold: 1,
+		CircuitBreakerCooldown:  internal.Duration{Duration: time.Minute},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// an unavailable bridge is tried again
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Contains(t, string((<-server.batches).Metrics), "cpu value=42")
+	require.Equal(t, int32(2), atomic.LoadInt32(&server.attempts))
+
+	// and opens the circuit breaker once the attempts are used up
+	atomic.StoreInt32(&server.failures, 2)
+	err = plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	require.Equal(t, int32(4), atomic.LoadInt32(&server.attempts))
+
+	err = plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "circuit breaker open")
+	require.Equal(t, int32(4), atomic.LoadInt32(&server.attempts))
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// resolveHealthcheckURLs returns the health check URL of every bridge.  A
+// healthcheck_url without a scheme and host is relative to the URL of each
+// bridge.
+func (h *HTTP) resolveHealthcheckURLs() ([]string, error) {
+	ref, err := url.Parse(h.HealthcheckURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid healthcheck_url: %s", err)
+	}
+	if ref.IsAbs() {
+		return []string{h.HealthcheckURL}, nil
+	}
+	if h.urlTemplate != nil || h.GRPCAddress != "" {
+		return nil, fmt.Errorf("healthcheck_url must be absolute with a url template or grpc_address")
+	}
+
+	bridges := []string{h.URL}
+	if len(h.URLs) > 0 {
+		bridges = h.URLs
+	}
+	urls := make([]string, 0, len(bridges))
+	for _, bridge := range bridges {
+		base, err := url.Parse(bridge)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, base.ResolveReference(ref).String())
+	}
+	return urls, nil
+}
+
+// checkHealth probes a health check URL, which must answer with a 2xx
+// status.
+func (h *HTTP) checkHealth(u string) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	if h.Username != "" || h.Password != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	for k, v := range h.Headers {
+		if strings.ToLower(k) == "host" {
+			req.Host = v
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newStatusError("checking health of", u, resp)
+	}
+	return nil
+}
+
+// probeBridges checks the health of every bridge, so that failover tries
+// the unhealthy ones last.  An error is returned when none is healthy.
+func (h *HTTP) probeBridges() error {
+	var err error
+	healthy := 0
+	for i, u := range h.healthcheckURLs {
+		err = h.checkHealth(u)
+		if h.failover != nil && len(h.healthcheckURLs) > 1 {
+			h.failover.setHealthy(i, err == nil)
+		}
+		if err != nil {
+			log.Printf("W! [outputs.http] Health check of [%s] failed: %s", u, err)
+			continue
+		}
+		healthy++
+	}
+	if healthy == 0 {
+		return fmt.Errorf("no healthy bridge: %s", err)
+	}
+	return nil
+}
+
+// startHealthcheck probes the bridges every healthcheck_interval until Close
+// is called.
+func (h *HTTP) startHealthcheck() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancelHealthcheck = cancel
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(h.HealthcheckInterval.Duration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := h.probeBridges()
+				if err != nil {
+					log.Printf("E! [outputs.http] %s", err)
+				}
+			}
+		}
+	}()
+}
+
+func (h *HTTP) stopHealthcheck() {
+	if h.cancelHealthcheck != nil {
+		h.cancelHealthcheck()
+		h.cancelHealthcheck = nil
+	}
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveHealthcheckURLs(t *testing.T) {
+	plugin := &HTTP{
+		URLs:           []string{"http://bridge1:8080/telegraf", "https://bridge2/telegraf"},
+		HealthcheckURL: "/health",
+	}
+	urls, err := plugin.resolveHealthcheckURLs()
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://bridge1:8080/health", "https://bridge2/health"}, urls)
+
+	plugin.HealthcheckURL = "http://monitor/bridges"
+	urls, err = plugin.resolveHealthcheckURLs()
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://monitor/bridges"}, urls)
+
+	plugin.HealthcheckURL = "/health"
+	plugin.GRPCAddress = "127.0.0.1:9090"
+	_, err = plugin.resolveHealthcheckURLs()
+	require.Error(t, err)
+}
+
+func TestHealthcheckConnect(t *testing.T) {
+	healthy := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/health", r.URL.Path)
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:            ts.URL + "/telegraf",
+		HealthcheckURL: "/health",
+	}
+	require.Error(t, plugin.Connect())
+
+	healthy = true
+	require.NoError(t, plugin.Connect())
+}
+
+func TestHealthcheckFailover(t *testing.T) {
+	var hits []string
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		hits = append(hits, "primary")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		hits = append(hits, "secondary")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer secondary.Close()
+
+	plugin := &HTTP{
+		URLs:           []string{primary.URL + "/telegraf", secondary.URL + "/telegraf"},
+		HealthcheckURL: "/health",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, []string{"secondary"}, hits)
+}
+
+func TestFailoverOrderUnhealthy(t *testing.T) {
+	f := newFailover([]string{"a", "b", "c"}, time.Hour)
+	f.setHealthy(0, false)
+	require.Equal(t, []int{1, 2, 0}, f.order())
+
+	// an unhealthy first bridge is not probed
+	f.use(2)
+	f.lastProbe = time.Now().Add(-2 * time.Hour)
+	require.Equal(t, []int{2, 1, 0}, f.order())
+
+	f.setHealthy(0, true)
+	require.Equal(t, []int{0, 2, 1}, f.order())
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// heartbeat summarizes the health of the agent, posted to heartbeat_url.
+type heartbeat struct {
+	Source  string         `json:"source"`
+	Version string         `json:"version"`
+	Time    string         `json:"time"`
+	Inputs  []pluginHealth `json:"inputs"`
+	Outputs []pluginHealth `json:"outputs"`
+	// Revisions are those of the plugin config managed by the bridge.
+	Revisions map[string]string `json:"revisions,omitempty"`
+}
+
+// pluginHealth is the health of a running plugin.  Errors are counted since
+// the previous heartbeat.
+type pluginHealth struct {
+	Name            string  `json:"name"`
+	Alias           string  `json:"alias,omitempty"`
+	Errors          int64   `json:"errors"`
+	MetricsGathered int64   `json:"metrics_gathered,omitempty"`
+	BufferSize      int64   `json:"buffer_size,omitempty"`
+	BufferLimit     int64   `json:"buffer_limit,omitempty"`
+	BufferFullness  float64 `json:"buffer_fullness,omitempty"`
+}
+
+// newHeartbeat builds a heartbeat from the internal metrics of the agent,
+// remembering the error counts so that the next one only has new errors.
+func (h *HTTP) newHeartbeat() *heartbeat {
+	hb := &heartbeat{
+		Source:  h.SourceAddress,
+		Version: internal.Version(),
+		Time:    time.Now().Format(time.RFC3339),
+		Inputs:  []pluginHealth{},
+		Outputs: []pluginHealth{},
+	}
+
+	errCounts := make(map[string]int64)
+	for _, m := range selfstat.Snapshot() {
+		switch m.Name() {
+		case "internal_gather":
+			p := newPluginHealth(m, "input")
+			p.MetricsGathered = statValue(m, "metrics_gathered")
+			p.Errors = h.newErrors(errCounts, "inputs."+p.Name+"."+p.Alias, statValue(m, "errors"))
+			hb.Inputs = append(hb.Inputs, p)
+		case "internal_write":
+			p := newPluginHealth(m, "output")
+			p.BufferSize = statValue(m, "buffer_size")
+			p.BufferLimit = statValue(m, "buffer_limit")
+			if p.BufferLimit > 0 {
+				p.BufferFullness = float64(p.BufferSize) / float64(p.BufferLimit)
+			}
+			p.Errors = h.newErrors(errCounts, "outputs."+p.Name+"."+p.Alias, statValue(m, "errors"))
+			hb.Outputs = append(hb.Outputs, p)
+		}
+	}
+	h.heartbeatErrors = errCounts
+
+	sortPluginHealth(hb.Inputs)
+	sortPluginHealth(hb.Outputs)
+
+	if h.ManageConfig {
+		h.configMu.Lock()
+		revisions, err := h.configRevisions()
+		h.configMu.Unlock()
+		if err != nil {
+			log.Printf("D! [outputs.http] Reading config revisions for heartbeat: %s", err)
+		} else {
+			hb.Revisions = revisions
+		}
+	}
+	return hb
+}
+
+func newPluginHealth(m telegraf.Metric, tag string) pluginHealth {
+	name, _ := m.GetTag(tag)
+	alias, _ := m.GetTag("alias")
+	return pluginHealth{Name: name, Alias: alias}
+}
+
+// newErrors records the error count of a plugin in errCounts and returns the
+// errors since the previous heartbeat.
+func (h *HTTP) newErrors(errCounts map[string]int64, key string, count int64) int64 {
+	errCounts[key] = count
+	if prev, ok := h.heartbeatErrors[key]; ok && prev <= count {
+		return count - prev
+	}
+	return count
+}
+
+func statValue(m telegraf.Metric, field string) int64 {
+	v, _ := m.GetField(field)
+	n, _ := v.(int64)
+	return n
+}
+
+func sortPluginHealth(plugins []pluginHealth) {
+	sort.Slice(plugins, func(i, j int) bool {
+		if plugins[i].Name != plugins[j].Name {
+			return plugins[i].Name < plugins[j].Name
+		}
+		return plugins[i].Alias < plugins[j].Alias
+	})
+}
+
+// sendHeartbeat posts a heartbeat to the bridge.  Failures are only logged.
+func (h *HTTP) sendHeartbeat() {
+	err := h.postJSON(h.HeartbeatURL, h.newHeartbeat())
+	if err != nil {
+		log.Printf("E! [outputs.http] Sending heartbeat to [%s]: %s", h.HeartbeatURL, err)
+	}
+}
+
+// startHeartbeat sends a heartbeat at once and then every
+// heartbeat_interval until Close is called, whether or not metrics are
+// written.
+func (h *HTTP) startHeartbeat() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancelHeartbeat = cancel
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		h.sendHeartbeat()
+
+		ticker := time.NewTicker(h.HeartbeatInterval.Duration)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.sendHeartbeat()
+			}
+		}
+	}()
+}
+
+func (h *HTTP) stopHeartbeat() {
+	if h.cancelHeartbeat != nil {
+		h.cancelHeartbeat()
+		h.cancelHeartbeat = nil
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/stretchr/testify/require"
+)
+
+func findPluginHealth(plugins []pluginHealth, name string) *pluginHealth {
+	for i := range plugins {
+		if plugins[i].Name == name {
+			return &plugins[i]
+		}
+	}
+	return nil
+}
+
+func TestHeartbeat(t *testing.T) {
+	inputTags := map[string]string{"input": "heartbeat_test", "alias": ""}
+	outputTags := map[string]string{"output": "heartbeat_test", "alias": ""}
+	gatherErrors := selfstat.Register("gather", "errors", inputTags)
+	selfstat.Register("gather", "metrics_gathered", inputTags).Incr(10)
+	selfstat.Register("write", "buffer_size", outputTags).Set(25)
+	selfstat.Register("write", "buffer_limit", outputTags).Set(100)
+	gatherErrors.Incr(2)
+
+	plugin := &HTTP{SourceAddress: "10.0.0.1"}
+
+	hb := plugin.newHeartbeat()
+	require.Equal(t, "10.0.0.1", hb.Source)
+	input := findPluginHealth(hb.Inputs, "heartbeat_test")
+	require.NotNil(t, input)
+	require.Equal(t, int64(2), input.Errors)
+	require.Equal(t, int64(10), input.MetricsGathered)
+	output := findPluginHealth(hb.Outputs, "heartbeat_test")
+	require.NotNil(t, output)
+	require.Equal(t, int64(25), output.BufferSize)
+	require.Equal(t, 0.25, output.BufferFullness)
+
+	// only errors since the previous heartbeat are reported
+	gatherErrors.Incr(1)
+	hb = plugin.newHeartbeat()
+	require.Equal(t, int64(1), findPluginHealth(hb.Inputs, "heartbeat_test").Errors)
+}
+
+func TestHeartbeatSentOnConnect(t *testing.T) {
+	heartbeats := make(chan heartbeat, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hb heartbeat
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&hb))
+		heartbeats <- hb
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:           ts.URL,
+		HeartbeatURL:  ts.URL,
+		SourceAddress: "10.0.0.1",
+	}
+	require.NoError(t, plugin.Connect())
+	hb := <-heartbeats
+	require.NoError(t, plugin.Close())
+
+	require.Equal(t, "10.0.0.1", hb.Source)
+	require.Equal(t, defaultHeartbeatInterval, plugin.HeartbeatInterval.Duration)
+}
@@ -1,28 +1,33 @@
 package http
 
 import (
-	"bufio"
 	"bytes"
 	"context"
-	"crypto/md5"
 	"fmt"
-	"github.com/kardianos/osext"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
+	"path/filepath"
+	"reflect"
 	"strings"
-	"syscall"
+	"sync"
+	"text/template"
 	"time"
 
+	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/tls"
 	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/outputs/http/bridge"
 	"github.com/influxdata/telegraf/plugins/serializers"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/clientcredentials"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/crypto/ed25519"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -30,9 +35,88 @@ const (
 )
 
 var sampleConfig = `
-  ## URL is the address to send metrics to
+  ## URL is the address to send metrics to.  A unix socket is given as
+  ## "unix:///var/run/collector.sock/telegraf", where the socket path ends
+  ## with ".sock" and the rest is the request path.
+  ##
+  ## The url can be a template sending metrics to different endpoints based
+  ## on their tags, for example:
+  ##   url = 'https://{{ .Tag "datacenter" }}.example.com/telegraf'
+  ## Metrics are grouped by the resulting URL.  {{ .Name }} is the measurement
+  ## name; tag values are escaped and missing tags are empty.
   url = "http://127.0.0.1:8080/telegraf"
 
+  ## Bridges to fail over to, in order of preference.  When set, url is not
+  ## used.  A write that fails to connect or gets a 5xx status is sent to the
+  ## next bridge, and the first one is tried again every failback_interval.
+  # urls = ["https://bridge1:8080/telegraf", "https://bridge2:8080/telegraf"]
+  # failback_interval = "1m"
+
+  ## Address probed with a GET during Connect, which fails when no bridge
+  ## answers with a 2xx status, and every healthcheck_interval when set.
+  ## A path such as "/health" is relative to each of the bridges, and those
+  ## failing it are tried last on failover.
+  # healthcheck_url = "/health"
+  # healthcheck_interval = "30s"
+
+  ## URL to post a heartbeat to every heartbeat_interval, whether or not
+  ## metrics are written.  The heartbeat is JSON with the running inputs and
+  ## outputs, their errors since the previous heartbeat, the fullness of the
+  ## output buffers and the revisions of the plugin config.
+  # heartbeat_url = "http://127.0.0.1:8080/telegraf/heartbeat"
+  # heartbeat_interval = "1m"
+
+  ## Number of times a write is attempted before the error is returned to the
+  ## agent, which keeps the batch for the next flush.  Writes that cannot
+  ## connect, or get a 429 or 5xx status, are retried after a delay starting
+  ## at retry_initial_backoff, doubling up to retry_max_backoff, plus a
+  ## random duration up to retry_jitter.
+  # retry_max_attempts = 1
+  # retry_initial_backoff = "1s"
+  # retry_max_backoff = "30s"
+  # retry_jitter = "0s"
+
+  ## Batches answered with one of these status codes are dropped instead of
+  ## being kept for the next flush, and are not retried.
+  # drop_status_codes = [400, 413]
+
+  ## Maximum size of a request body, after content_encoding is applied.
+  ## Larger batches are split and sent in several requests.  By default
+  ## there is no limit.
+  # max_body_size = "1MB"
+
+  ## Maximum size of a response of the bridge, such as plugin config.  Larger
+  ## responses fail the request.  Bodies of other responses are discarded
+  ## without being kept in memory.
+  # max_response_size = "4MB"
+
+  ## Number of batches sent at the same time.  Each flush is split into this
+  ## many batches, which are written concurrently over kept-alive
+  ## connections.
+  # workers = 1
+
+  ## Number of consecutive failed writes after which the circuit breaker
+  ## opens, 0 disables it.  While open, writes fail at once without
+  ## contacting the bridge; after circuit_breaker_cooldown a single write is
+  ## let through to probe it.  The state is reported by the internal input as
+  ## the circuit_breaker_state field of internal_http_output: 0 closed, 1
+  ## open, 2 half-open.
+  # circuit_breaker_threshold = 0
+  # circuit_breaker_cooldown = "1m"
+
+  ## When async is true, writes return as soon as the batches are queued and
+  ## a background sender delivers them, so a slow bridge does not hold up the
+  ## flush.  Batches that fail are retried by the sender until they are sent
+  ## or dropped.  queue_size is the number of batches the queue holds; when it
+  ## is full, queue_overflow either blocks the write until there is room
+  ## ("block") or discards the oldest batch ("drop-oldest").
+  # async = false
+  # queue_size = 100
+  # queue_overflow = "block"
+
+  ## A Retry-After header on a 429 or 503 status is honored by waiting at
+  ## least that long before the next write, returning an error meanwhile.
+
   ## Timeout for HTTP message
   # timeout = "5s"
 
@@ -48,27 +132,244 @@ var sampleConfig = `
   # client_secret = "secret"
   # token_url = "https://indentityprovider/oauth2/v1/token"
   # scopes = ["urn:opc:idm:__myscopes__"]
-
-  ## Optional TLS Config
+  ## Audience of the token and other parameters of the token request.  A
+  ## token that cannot be obtained fails the write, it is requested again on
+  ## the next one.
+  # audience = "https://bridge.example.com"
+  # endpoint_params = {resource = "telegraf"}
+
+  ## HTTP proxy used for requests to the bridge instead of the one set by the
+  ## HTTP_PROXY and HTTPS_PROXY environment variables.  Hosts, domains and
+  ## networks in no_proxy are reached directly, replacing NO_PROXY.
+  # http_proxy_url = "http://corporate.proxy:3128"
+  # no_proxy = ["localhost", ".internal.example.com", "10.0.0.0/8"]
+
+  ## Session login, for bridges that need a login request returning a
+  ## session cookie.  The request is sent to auth_url before the first
+  ## request to the bridge, and again when the bridge answers with 401.
+  # auth_url = "https://bridge.example.com/login"
+  # auth_method = "POST"
+  # auth_body = "username=telegraf&password=secret"
+  # auth_content_type = "application/x-www-form-urlencoded"
+
+  ## Kerberos authentication with SPNEGO ("Negotiate"), for a bridge behind
+  ## integrated Windows authentication.  On Windows the account Telegraf runs
+  ## as is used through SSPI.  Elsewhere Telegraf logs in as
+  ## kerberos_principal with its key from kerberos_keytab, using the realms
+  ## of kerberos_config.  The service principal defaults to HTTP/<host>.
+  ## Cannot be combined with other authentication.
+  # kerberos = false
+  # kerberos_spn = "HTTP/bridge.example.com"
+  # kerberos_principal = "telegraf@EXAMPLE.COM"
+  # kerberos_keytab = "/etc/telegraf/telegraf.keytab"
+  # kerberos_config = "/etc/krb5.conf"
+
+  ## Sign requests with AWS Signature Version 4 for this service, for a
+  ## bridge behind API Gateway ("execute-api") or a load balancer with IAM
+  ## authentication.  Cannot be combined with OAuth2.
+  # aws_service = "execute-api"
+  # region = "us-east-1"
+
+  ## Amazon Credentials
+  ## Credentials are loaded in the following order
+  ## 1) Assumed credentials via STS if role_arn is specified
+  ## 2) explicit credentials from 'access_key' and 'secret_key'
+  ## 3) shared profile from 'profile'
+  ## 4) environment variables
+  ## 5) shared credentials file
+  ## 6) EC2 Instance Profile
+  # access_key = ""
+  # secret_key = ""
+  # token = ""
+  # role_arn = ""
+  # profile = ""
+  # shared_credential_file = ""
+
+  ## Optional TLS Config.  The client certificate is loaded again when
+  ## tls_cert or tls_key is modified, for short-lived certificates renewed in
+  ## place.
   # tls_ca = "/etc/telegraf/ca.pem"
   # tls_cert = "/etc/telegraf/cert.pem"
   # tls_key = "/etc/telegraf/key.pem"
   ## Use TLS but skip chain & host verification
   # insecure_skip_verify = false
 
+  ## As this connection can change the config of the agent, the TLS versions
+  ## and cipher suites accepted can be restricted.
+  # tls_min_version = "TLS12"
+  # tls_cipher_suites = ["TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"]
+
+  ## Only accept a server presenting a certificate, anywhere in its chain,
+  ## with one of these public keys.  Each is the SHA-256 digest of the
+  ## SubjectPublicKeyInfo in base64, as printed by:
+  ##   openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+  # tls_pinned_keys = ["sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="]
+
   ## Data format to output.
   ## Each data format has it's own unique set of configuration options, read
   ## more about them here:
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
   # data_format = "influx"
 
-  ## HTTP Content-Encoding for write request body, can be set to "gzip" to
-  ## compress body or "identity" to apply no encoding.
+  ## HTTP Content-Encoding for write request body, can be set to "gzip",
+  ## "zstd" or "snappy" to compress body or "identity" to apply no encoding.
   # content_encoding = "identity"
 
-  ## Additional HTTP headers
+  ## Every batch carries an Idempotency-Key header derived from its body and
+  ## URL, the same for each attempt and when the agent writes the metrics
+  ## again, so the bridge can ignore retried batches it already has.  The checksum
+  ## of the request body is sent in the Content-MD5 header when set to "md5",
+  ## or in the x-content-sha256 header as hex when set to "sha256".
+  # payload_checksum = ""
+
+  ## Set to false to send metrics to an ordinary HTTP collector: plugin config
+  ## is never requested and the responses to writes are ignored, so the output
+  ## never changes telegraf.conf.  The config_url, config_websocket_url and
+  ## command_servers options cannot be used then.
+  # manage_config = true
+
+  ## Directory holding the telegraf.conf managed by the bridge.  When the
+  ## bridge answers a write with new plugin config, it is merged into this
+  ## file and Telegraf is restarted.
+  # config_file_path = "/etc/telegraf"
+
+  ## Directory, relative to config_file_path, in which every plugin table sent
+  ## by the bridge is kept as its own file instead of being merged into
+  ## telegraf.conf.  Each table must be preceded by a "# plugin_id: <id>"
+  ## comment and is written to "<id>.conf".  The bridge owns the directory:
+  ## tables it no longer sends are removed.  Telegraf must be started with
+  ## --config-directory pointing to it.  With protocol 2 the bridge can send
+  ## operations instead, each adding, updating or removing one table by its
+  ## plugin_id; they are validated together before any file is changed.
+  # config_directory = "telegraf.d"
+
+  ## Identifies this agent to the bridge, sent as the source query parameter.
+  # source_address = ""
+
+  ## Register the agent with the bridge by posting its hostname, OS, version
+  ## and a generated agent ID to this address.  The bridge answers with a
+  ## token, kept with the agent ID in identity_file, and both are sent in
+  ## the X-Agent-ID and X-Agent-Token headers of every request.  Registration
+  ## happens once; remove identity_file to register again.  identity_file
+  ## defaults to "bridge-identity.json" in config_file_path.
+  # registration_url = "http://127.0.0.1:8080/telegraf/register"
+  # identity_file = "/etc/telegraf/bridge-identity.json"
+
+  ## Local IP address, or name of the network interface, that connections to
+  ## the bridge are made from, such as the management interface of a
+  ## multi-homed host.
+  # local_address = ""
+
+  ## Verify plugin config received from the bridge before it is written.  The
+  ## bridge signs the response body and sends the base64 signature in the
+  ## X-Config-Signature header, using either HMAC-SHA256 with a shared key or
+  ## an ed25519 key whose base64 public key is given here.  Use environment
+  ## variables to keep the keys out of this file.
+  # config_hmac_key = "$BRIDGE_CONFIG_KEY"
+  # config_public_key = "$BRIDGE_CONFIG_PUBLIC_KEY"
+
+  ## New plugin config is loaded and its inputs are gathered once before it
+  ## replaces the current config.  Inputs that take longer than this to
+  ## gather fail the validation.
+  # config_validation_timeout = "10s"
+
+  ## Plugins the bridge is allowed to configure, as "<type>.<name>".  Globs
+  ## are supported.  Config containing any other plugin is rejected.  By
+  ## default all plugins are allowed.
+  # allowed_remote_plugins = ["inputs.cpu", "inputs.mem", "inputs.disk*"]
+
+  ## URL to post the result of applying plugin config received from the
+  ## bridge to, as JSON with the new section revisions, whether the config
+  ## was accepted and the validation error if it was not.  The reason for
+  ## rejecting a config is also sent with the next write as the config_error
+  ## query parameter.
+  # config_status_url = "http://127.0.0.1:8080/telegraf/config/status"
+
+  ## With protocol 2 the bridge can send commands along with plugin config.
+  ## Only these commands are run, and only when config_hmac_key or
+  ## config_public_key verifies the response:
+  ##   restart      - restart Telegraf
+  ##   rotate_logs  - rotate the log file
+  ##   test         - run "telegraf --test" on the local config
+  ##   flush        - send the batches queued, only in async mode
+  ##   upload_logs  - upload the log file to log_upload_url, or only its
+  ##                  end when the "size" argument is set, such as "256KB"
+  ##   debug        - log at debug level for the "duration" argument, such
+  ##                  as "15m", then return to the configured level
+  ## Every command is logged, and its result and output are posted as JSON
+  ## to command_status_url.
+  # command_status_url = "http://127.0.0.1:8080/telegraf/command/status"
+
+  ## Each command must have an "id" and the "time" the bridge issued it at,
+  ## in RFC 3339 format, and is only run once: commands issued more than
+  ## command_max_age ago, or with the id of a command run already, are
+  ## rejected.  The ids of the commands run are kept in
+  ## bridge-commands.json in config_file_path until they expire.
+  # command_max_age = "5m"
+
+  ## URL the upload_logs command posts the log file of the agent to, as the
+  ## "file" part of a multipart form.  Only the last log_upload_max_size of
+  ## the file is sent.
+  # log_upload_url = "http://127.0.0.1:8080/telegraf/logs"
+  # log_upload_max_size = "1MB"
+
+  ## Longest time the debug command enables debug logging for.
+  # debug_max_duration = "1h"
+
+  ## Request plugin config with a GET from this address every config_interval
+  ## instead of from the response to metric writes.  The responses to writes
+  ## to url are then ignored, so that url can be any HTTP collector.
+  # config_url = "http://127.0.0.1:8080/telegraf/config"
+  # config_interval = "1m"
+
+  ## Random delay of up to config_jitter added to every config_interval, so
+  ## that agents started together do not poll the bridge at the same time.
+  # config_jitter = "0s"
+
+  ## When plugin config comes with the response to writes, only send the
+  ## config revisions with a write once every config_check_interval, plus up
+  ## to config_jitter.  The responses to the writes in between are ignored.
+  ## By default every write checks for new plugin config.
+  # config_check_interval = "0s"
+
+  ## Keep a WebSocket connection open to this address over which the bridge
+  ## can push plugin config as soon as it changes, as JSON messages of the
+  ## form {"type": "config", "config": "<toml>", "signature": "<base64>"}.
+  ## Config is still polled as without it, and the connection is reopened
+  ## with increasing delays when it fails.  The connection only carries
+  ## basic auth, the headers and the agent identity; it does not go through
+  ## a proxy, so it cannot be combined with http_proxy_url, OAuth2, kerberos,
+  ## auth_url or aws_service.
+  # config_websocket_url = "ws://127.0.0.1:8080/telegraf/push"
+
+  ## Talk to the bridge over gRPC at this address instead of HTTP.  Metrics
+  ## are sent on a WriteMetrics stream and plugin config is requested with
+  ## GetConfig every config_interval, see bridge/bridge.proto.  The TLS
+  ## settings above enable TLS, and with tls_cert and tls_key mutual TLS.
+  ## Credentials and headers are sent as request metadata.  Writes are retried
+  ## and counted by the circuit breaker as over HTTP, a write failing with
+  ## the Unavailable, DeadlineExceeded or Canceled code as one that cannot
+  ## connect; urls and Retry-After do not apply.
+  # grpc_address = "127.0.0.1:9090"
+
+  ## Subscribe to a command topic on these MQTT brokers.  A message
+  ## {"type": "config_available"} on the topic makes the agent request
+  ## plugin config right away, so config_url or grpc_address is required.  The topic
+  ## defaults to "telegraf/<source_address>/commands", so either source_address
+  ## or command_topic is required.  The TLS settings above also apply to the
+  ## brokers.
+  # command_servers = ["tcp://127.0.0.1:1883"]
+  # command_topic = ""
+  # command_username = ""
+  # command_password = ""
+
+  ## When the bridge sends an ETag header with plugin config, it is returned
+  ## in the If-None-Match header of the following writes as long as the local
+  ## config is unchanged, and the bridge may answer 304 Not Modified.
+
+  ## Additional HTTP headers.  The Content-Type header defaults to the media
+  ## type of the data_format, such as "application/json" for json.
   # [outputs.http.headers]
-  #   # Should be set manually to "application/json" for json data_format
   #   Content-Type = "text/plain; charset=utf-8"
 `
 
@@ -76,6 +377,18 @@ const (
 	defaultClientTimeout = 5 * time.Second
 	defaultContentType   = "text/plain; charset=utf-8"
 	defaultMethod        = http.MethodPost
+
+	defaultConfigValidationTimeout = 10 * time.Second
+	defaultConfigInterval          = time.Minute
+	defaultFailbackInterval        = time.Minute
+	defaultHeartbeatInterval       = time.Minute
+	defaultRetryInitialBackoff     = time.Second
+	defaultRetryMaxBackoff         = 30 * time.Second
+	defaultCircuitBreakerCooldown  = time.Minute
+	defaultQueueSize               = 100
+	defaultMaxResponseSize         = 4 * 1024 * 1024
+	defaultLogUploadMaxSize        = 1024 * 1024
+	defaultDebugMaxDuration        = time.Hour
 )
 
 type HTTP struct {
@@ -89,13 +402,160 @@ type HTTP struct {
 	ClientSecret    string            `toml:"client_secret"`
 	TokenURL        string            `toml:"token_url"`
 	Scopes          []string          `toml:"scopes"`
+	Audience        string            `toml:"audience"`
+	EndpointParams  map[string]string `toml:"endpoint_params"`
+	HTTPProxyURL    string            `toml:"http_proxy_url"`
+	NoProxy         []string          `toml:"no_proxy"`
 	ContentEncoding string            `toml:"content_encoding"`
+	PayloadChecksum string            `toml:"payload_checksum"`
 	SourceAddress   string            `toml:"source_address"`
+	LocalAddress    string            `toml:"local_address"`
+	RegistrationURL string            `toml:"registration_url"`
+	IdentityFile    string            `toml:"identity_file"`
+	ManageConfig    bool              `toml:"manage_config"`
 	ConfigFilePath  string            `toml:"config_file_path"`
+	ConfigHMACKey   string            `toml:"config_hmac_key"`
+	ConfigPublicKey string            `toml:"config_public_key"`
+	ConfigDirectory string            `toml:"config_directory"`
+
+	AuthURL         string `toml:"auth_url"`
+	AuthMethod      string `toml:"auth_method"`
+	AuthBody        string `toml:"auth_body"`
+	AuthContentType string `toml:"auth_content_type"`
+
+	TLSCipherSuites []string `toml:"tls_cipher_suites"`
+	TLSPinnedKeys   []string `toml:"tls_pinned_keys"`
+
+	Kerberos          bool   `toml:"kerberos"`
+	KerberosSPN       string `toml:"kerberos_spn"`
+	KerberosPrincipal string `toml:"kerberos_principal"`
+	KerberosKeytab    string `toml:"kerberos_keytab"`
+	KerberosConfig    string `toml:"kerberos_config"`
+
+	AWSService string `toml:"aws_service"`
+	Region     string `toml:"region"`
+	AccessKey  string `toml:"access_key"`
+	SecretKey  string `toml:"secret_key"`
+	RoleARN    string `toml:"role_arn"`
+	Profile    string `toml:"profile"`
+	Filename   string `toml:"shared_credential_file"`
+	Token      string `toml:"token"`
+
+	AllowedRemotePlugins []string `toml:"allowed_remote_plugins"`
+	ConfigStatusURL      string   `toml:"config_status_url"`
+	CommandStatusURL     string   `toml:"command_status_url"`
+
+	CommandMaxAge internal.Duration `toml:"command_max_age"`
+
+	LogUploadURL     string        `toml:"log_upload_url"`
+	LogUploadMaxSize internal.Size `toml:"log_upload_max_size"`
+
+	DebugMaxDuration internal.Duration `toml:"debug_max_duration"`
+
+	ConfigURL           string            `toml:"config_url"`
+	ConfigInterval      internal.Duration `toml:"config_interval"`
+	ConfigJitter        internal.Duration `toml:"config_jitter"`
+	ConfigCheckInterval internal.Duration `toml:"config_check_interval"`
+
+	ConfigWebSocketURL string `toml:"config_websocket_url"`
+
+	GRPCAddress string `toml:"grpc_address"`
+
+	URLs             []string          `toml:"urls"`
+	FailbackInterval internal.Duration `toml:"failback_interval"`
+
+	HealthcheckURL      string            `toml:"healthcheck_url"`
+	HealthcheckInterval internal.Duration `toml:"healthcheck_interval"`
+
+	HeartbeatURL      string            `toml:"heartbeat_url"`
+	HeartbeatInterval internal.Duration `toml:"heartbeat_interval"`
+
+	RetryMaxAttempts    int               `toml:"retry_max_attempts"`
+	RetryInitialBackoff internal.Duration `toml:"retry_initial_backoff"`
+	RetryMaxBackoff     internal.Duration `toml:"retry_max_backoff"`
+	RetryJitter         internal.Duration `toml:"retry_jitter"`
+	DropStatusCodes     []int             `toml:"drop_status_codes"`
+	MaxBodySize         internal.Size     `toml:"max_body_size"`
+	MaxResponseSize     internal.Size     `toml:"max_response_size"`
+
+	Workers int `toml:"workers"`
+
+	CircuitBreakerThreshold int               `toml:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  internal.Duration `toml:"circuit_breaker_cooldown"`
+
+	Async         bool   `toml:"async"`
+	QueueSize     int    `toml:"queue_size"`
+	QueueOverflow string `toml:"queue_overflow"`
+
+	CommandServers  []string `toml:"command_servers"`
+	CommandTopic    string   `toml:"command_topic"`
+	CommandUsername string   `toml:"command_username"`
+	CommandPassword string   `toml:"command_password"`
+
+	ConfigValidationTimeout internal.Duration `toml:"config_validation_timeout"`
 	tls.ClientConfig
 
-	client     *http.Client
-	serializer serializers.Serializer
+	client          *http.Client
+	serializer      serializers.Serializer
+	configPublicKey ed25519.PublicKey
+	remoteFilter    filter.Filter
+	configError     string
+
+	// configETag is the ETag of the last plugin config received from the
+	// bridge, valid as long as the local config is at configETagRevisions.
+	configETag          string
+	configETagRevisions map[string]string
+	// nextConfigCheck is when a write carries the revisions again
+	nextConfigCheck time.Time
+
+	// configMu serializes applying plugin config received from the bridge
+	configMu      sync.Mutex
+	cancelPolling context.CancelFunc
+	cancelPush    context.CancelFunc
+	cancelCommand context.CancelFunc
+	pollNow       chan struct{}
+	commandClient paho.Client
+	failover      *failover
+	breaker       *circuitBreaker
+	stats         *httpStats
+	accepted      acceptedMetrics
+	queue         chan batch
+	cancelQueue   context.CancelFunc
+	queueCtx      context.Context
+	// queueMu keeps the queue from being flushed once Close is called
+	queueMu sync.Mutex
+	// commandMu keeps a command client that connects once Close is called
+	// from staying connected
+	commandMu sync.Mutex
+	// retryAfter is when the bridge asked writes to resume
+	retryAfter time.Time
+	retryMu    sync.Mutex
+	// done is closed by Close, ending the wait between write attempts
+	done chan struct{}
+
+	grpcConn         *grpc.ClientConn
+	grpcClient       bridge.BridgeClient
+	grpcMu           sync.Mutex
+	grpcStream       bridge.Bridge_WriteMetricsClient
+	cancelGRPCStream context.CancelFunc
+	wg               sync.WaitGroup
+
+	zstdEncoder  *zstd.Encoder
+	certReloader *certReloader
+	urlTemplate  *template.Template
+	localAddr    *net.TCPAddr
+	identity     *agentIdentity
+	// unixSockets maps the placeholder hosts of unix:// URLs to their socket
+	unixSockets map[string]string
+
+	// healthcheckURLs holds the health check URL of each bridge
+	healthcheckURLs   []string
+	cancelHealthcheck context.CancelFunc
+
+	cancelHeartbeat context.CancelFunc
+	// heartbeatErrors holds the error count of each plugin at the previous
+	// heartbeat
+	heartbeatErrors map[string]int64
 }
 
 func (h *HTTP) SetSerializer(serializer serializers.Serializer) {
@@ -103,30 +563,65 @@ func (h *HTTP) SetSerializer(serializer serializers.Serializer) {
 }
 
 func (h *HTTP) createClient(ctx context.Context) (*http.Client, error) {
-	tlsCfg, err := h.ClientConfig.TLSConfig()
+	tlsCfg, err := h.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := h.proxyFunc()
 	if err != nil {
 		return nil, err
 	}
 
+	dialer := h.newDialer()
+
 	client := &http.Client{
 		Transport: &http.Transport{
 			TLSClientConfig: tlsCfg,
-			Proxy:           http.ProxyFromEnvironment,
+			Proxy: func(req *http.Request) (*url.URL, error) {
+				if _, ok := h.unixSocket(req.URL.Host); ok {
+					return nil, nil
+				}
+				return proxy(req)
+			},
+			DialContext:         h.dialContext(dialer),
+			MaxIdleConnsPerHost: h.Workers,
 		},
 		Timeout: h.Timeout.Duration,
 	}
 
-	if h.ClientID != "" && h.ClientSecret != "" && h.TokenURL != "" {
-		oauthConfig := clientcredentials.Config{
-			ClientID:     h.ClientID,
-			ClientSecret: h.ClientSecret,
-			TokenURL:     h.TokenURL,
-			Scopes:       h.Scopes,
+	if h.AuthURL != "" {
+		client.Transport, err = h.newCookieAuthTransport(client.Transport)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if h.AWSService != "" {
+		client.Transport = h.newSigV4Transport(client.Transport)
+	}
+
+	if h.Kerberos {
+		n, err := h.newNegotiator()
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = &negotiateTransport{
+			next:       client.Transport,
+			negotiator: n,
+			spn:        h.KerberosSPN,
 		}
-		ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
-		client = oauthConfig.Client(ctx)
 	}
 
+	if h.ClientID != "" && h.ClientSecret != "" && h.TokenURL != "" {
+		client = h.oauth2Client(ctx, client)
+	}
+
+	if h.identity != nil {
+		client.Transport = &identityTransport{next: client.Transport, identity: h.identity}
+	}
+
+	client.Transport = &statsTransport{next: client.Transport, stats: h.stats}
 	return client, nil
 }
 
@@ -143,6 +638,132 @@ func (h *HTTP) Connect() error {
 		h.Timeout.Duration = defaultClientTimeout
 	}
 
+	if h.AWSService != "" && h.ClientID != "" {
+		return fmt.Errorf("aws_service cannot be combined with OAuth2")
+	}
+	if h.AuthMethod == "" {
+		h.AuthMethod = http.MethodPost
+	}
+	if h.AuthContentType == "" {
+		h.AuthContentType = "application/x-www-form-urlencoded"
+	}
+
+	if h.Kerberos && (h.AWSService != "" || h.ClientID != "" || h.Username != "") {
+		return fmt.Errorf("kerberos cannot be combined with other authentication")
+	}
+
+	if isURLTemplate(h.URL) {
+		if len(h.URLs) > 0 || h.GRPCAddress != "" {
+			return fmt.Errorf("a url template cannot be combined with urls or grpc_address")
+		}
+		tmpl, err := parseURLTemplate(h.URL)
+		if err != nil {
+			return err
+		}
+		h.urlTemplate = tmpl
+	}
+
+	err := h.resolveUnixURLs()
+	if err != nil {
+		return err
+	}
+
+	err = h.checkContentEncoding()
+	if err != nil {
+		return err
+	}
+
+	err = h.checkPayloadChecksum()
+	if err != nil {
+		return err
+	}
+
+	if h.RetryInitialBackoff.Duration <= 0 {
+		h.RetryInitialBackoff.Duration = defaultRetryInitialBackoff
+	}
+	if h.RetryMaxBackoff.Duration < h.RetryInitialBackoff.Duration {
+		h.RetryMaxBackoff.Duration = h.RetryInitialBackoff.Duration
+	}
+
+	if len(h.URLs) > 0 {
+		if h.FailbackInterval.Duration <= 0 {
+			h.FailbackInterval.Duration = defaultFailbackInterval
+		}
+		h.failover = newFailover(h.URLs, h.FailbackInterval.Duration)
+	}
+
+	switch h.QueueOverflow {
+	case "":
+		h.QueueOverflow = queueBlock
+	case queueBlock, queueDropOldest:
+	default:
+		return fmt.Errorf("invalid queue_overflow %q", h.QueueOverflow)
+	}
+	if h.QueueSize <= 0 {
+		h.QueueSize = defaultQueueSize
+	}
+
+	if h.CircuitBreakerThreshold > 0 {
+		if h.CircuitBreakerCooldown.Duration <= 0 {
+			h.CircuitBreakerCooldown.Duration = defaultCircuitBreakerCooldown
+		}
+		h.breaker = newCircuitBreaker(h.CircuitBreakerThreshold, h.CircuitBreakerCooldown.Duration, h.statTags())
+	}
+	h.stats = newHTTPStats(h.statTags())
+	h.done = make(chan struct{})
+
+	if !h.ManageConfig && (h.ConfigURL != "" || h.ConfigWebSocketURL != "" || len(h.CommandServers) > 0) {
+		return fmt.Errorf("config_url, config_websocket_url and command_servers require manage_config")
+	}
+
+	if len(h.CommandServers) > 0 && h.ConfigURL == "" && h.GRPCAddress == "" {
+		return fmt.Errorf("command_servers requires config_url or grpc_address")
+	}
+
+	if len(h.CommandServers) > 0 && h.CommandTopic == "" && h.SourceAddress == "" {
+		return fmt.Errorf("command_servers requires source_address or command_topic")
+	}
+
+	if h.ConfigWebSocketURL != "" {
+		err := h.checkPushChannel()
+		if err != nil {
+			return err
+		}
+	}
+
+	if h.ConfigValidationTimeout.Duration == 0 {
+		h.ConfigValidationTimeout.Duration = defaultConfigValidationTimeout
+	}
+
+	if h.ConfigPublicKey != "" {
+		key, err := parsePublicKey(h.ConfigPublicKey)
+		if err != nil {
+			return err
+		}
+		h.configPublicKey = key
+	}
+
+	remoteFilter, err := filter.Compile(h.AllowedRemotePlugins)
+	if err != nil {
+		return fmt.Errorf("invalid allowed_remote_plugins: %s", err)
+	}
+	h.remoteFilter = remoteFilter
+
+	h.localAddr, err = resolveLocalAddress(h.LocalAddress)
+	if err != nil {
+		return err
+	}
+
+	if h.RegistrationURL != "" {
+		if h.IdentityFile == "" {
+			h.IdentityFile = filepath.Join(h.ConfigFilePath, defaultIdentityFile)
+		}
+		h.identity, err = loadIdentity(h.IdentityFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	ctx := context.Background()
 	client, err := h.createClient(ctx)
 	if err != nil {
@@ -151,10 +772,80 @@ func (h *HTTP) Connect() error {
 
 	h.client = client
 
+	if h.identity != nil {
+		err = h.register()
+		if err != nil {
+			return fmt.Errorf("registering with [%s]: %s", h.RegistrationURL, err)
+		}
+	}
+
+	if h.GRPCAddress != "" {
+		err = h.connectGRPC()
+		if err != nil {
+			return err
+		}
+	}
+
+	if h.HealthcheckURL != "" {
+		h.healthcheckURLs, err = h.resolveHealthcheckURLs()
+		if err != nil {
+			return err
+		}
+		err = h.probeBridges()
+		if err != nil {
+			return err
+		}
+		if h.HealthcheckInterval.Duration > 0 {
+			h.startHealthcheck()
+		}
+	}
+
+	if h.HeartbeatURL != "" {
+		if h.HeartbeatInterval.Duration <= 0 {
+			h.HeartbeatInterval.Duration = defaultHeartbeatInterval
+		}
+		h.startHeartbeat()
+	}
+
+	if h.ManageConfig && (h.ConfigURL != "" || h.GRPCAddress != "") {
+		if h.ConfigInterval.Duration <= 0 {
+			h.ConfigInterval.Duration = defaultConfigInterval
+		}
+		h.startConfigPolling()
+	}
+	if h.ConfigWebSocketURL != "" {
+		h.startPushChannel()
+	}
+	if len(h.CommandServers) > 0 {
+		err = h.startCommandChannel()
+		if err != nil {
+			return err
+		}
+	}
+	if h.Async {
+		h.startQueue()
+	}
+
 	return nil
 }
 
 func (h *HTTP) Close() error {
+	if h.done != nil {
+		select {
+		case <-h.done:
+		default:
+			close(h.done)
+		}
+	}
+	h.stopConfigPolling()
+	h.stopPushChannel()
+	h.stopCommandChannel()
+	h.stopQueue()
+	h.stopHealthcheck()
+	h.stopHeartbeat()
+	h.wg.Wait()
+	h.flushQueue()
+	h.closeGRPC()
 	return nil
 }
 
@@ -167,30 +858,106 @@ func (h *HTTP) SampleConfig() string {
 }
 
 func (h *HTTP) Write(metrics []telegraf.Metric) error {
-	reqBody, err := h.serializer.SerializeBatch(metrics)
-	if err != nil {
-		return err
+	metrics = h.accepted.filter(metrics)
+	if len(metrics) == 0 {
+		h.accepted.clear()
+		return nil
 	}
 
-	if err := h.write(reqBody); err != nil {
-		return err
+	groups := []metricGroup{{metrics: metrics}}
+	if h.urlTemplate != nil {
+		var err error
+		groups, err = h.groupByURL(metrics)
+		if err != nil {
+			return err
+		}
+	}
+
+	var batches []batch
+	for _, group := range groups {
+		parts := [][]telegraf.Metric{group.metrics}
+		if h.Workers > 1 {
+			parts = splitMetrics(group.metrics, h.Workers)
+		}
+		for _, part := range parts {
+			partBatches, err := h.serializeBatches(part)
+			if err != nil {
+				return err
+			}
+			for _, b := range partBatches {
+				b.url = group.url
+				b.id = batchID(b)
+				batches = append(batches, b)
+			}
+		}
 	}
 
+	if h.Async {
+		return h.enqueue(batches)
+	}
+	if h.Workers > 1 {
+		err := h.writeConcurrently(batches)
+		if err != nil {
+			return err
+		}
+		h.accepted.clear()
+		return nil
+	}
+	for _, b := range batches {
+		err := h.writeBatch(b)
+		if err != nil {
+			return err
+		}
+		h.accepted.add(b)
+	}
+	h.accepted.clear()
 	return nil
 }
 
-func (h *HTTP) write(reqBody []byte) error {
-	var reqBodyBuffer io.Reader = bytes.NewBuffer(reqBody)
+// write sends a batch to its URL, or to the configured bridges.
+func (h *HTTP) write(b batch) error {
+	if h.GRPCAddress != "" {
+		return h.writeGRPC(b.body)
+	}
+	if b.url != "" {
+		return h.writeTo(b.url, b)
+	}
+	if h.failover == nil {
+		return h.writeTo(h.URL, b)
+	}
 
 	var err error
-	if h.ContentEncoding == "gzip" {
-		reqBodyBuffer, err = internal.CompressWithGzip(reqBodyBuffer)
+	for _, i := range h.failover.order() {
+		url := h.failover.urls[i]
+		err = h.writeTo(url, b)
+		if !isFailoverError(err) {
+			h.failover.use(i)
+			return err
+		}
+		log.Printf("W! [outputs.http] Writing to [%s] failed: %s", url, err)
+	}
+	return err
+}
+
+// contentType returns the media type of the serializer, if it has one.
+func (h *HTTP) contentType() string {
+	if mt, ok := h.serializer.(serializers.MediaTyper); ok {
+		return mt.MediaType()
+	}
+	return defaultContentType
+}
+
+func (h *HTTP) writeTo(url string, b batch) error {
+	reqBody := b.encoded
+	if reqBody == nil {
+		var err error
+		reqBody, err = h.encodeBody(b.body)
 		if err != nil {
 			return err
 		}
 	}
 
-	req, err := http.NewRequest(h.Method, h.URL, reqBodyBuffer)
+	req, err := http.NewRequest(h.Method, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return err
 	}
@@ -200,10 +967,14 @@ func (h *HTTP) write(reqBody []byte) error {
 	}
 
 	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
-	req.Header.Set("Content-Type", defaultContentType)
-	if h.ContentEncoding == "gzip" {
-		req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Type", h.contentType())
+	if h.isEncoded() {
+		req.Header.Set("Content-Encoding", h.ContentEncoding)
+	}
+	if b.id != "" {
+		req.Header.Set("Idempotency-Key", b.id)
 	}
+	h.setPayloadChecksum(req, reqBody)
 	for k, v := range h.Headers {
 		if strings.ToLower(k) == "host" {
 			req.Host = v
@@ -211,261 +982,320 @@ func (h *HTTP) write(reqBody []byte) error {
 		req.Header.Set(k, v)
 	}
 
-	inputPluginConfigMd5, err := calculateMd5OfInputPluginConfig(h.ConfigFilePath)
-	err = h.addConfigParams(req, inputPluginConfigMd5)
+	// plugin config is polled separately from config_url, or not at all
+	if h.ConfigURL != "" || !h.ManageConfig || !h.configCheckDue() {
+		resp, err := h.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(ioutil.Discard, resp.Body)
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return newStatusError("writing to", url, resp)
+		}
+		return nil
+	}
+
+	return h.doConfigRequest(req, "writing to")
+}
+
+// doConfigRequest sends a request carrying the revisions of the local plugin
+// config to the bridge and applies the plugin config it answers with.
+func (h *HTTP) doConfigRequest(req *http.Request, action string) error {
+	url := req.URL.String()
+	revisions, err := h.prepareConfigRequest(req)
 	if err != nil {
 		return err
 	}
 
+	// the lock is not held while waiting for the bridge, so that several
+	// workers can write at the same time
 	resp, err := h.client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+
+	if resp.StatusCode == http.StatusNotModified {
+		// the request succeeded and the plugin config is up to date
+		h.configError = ""
+		return nil
+	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("when writing to [%s] received status code: %d", h.URL, resp.StatusCode)
+		return newStatusError(action, url, resp)
 	}
 
+	// the bridge has been told about the last rejected config
+	h.configError = ""
+
 	if resp.StatusCode == http.StatusOK {
-		err = h.updateInputPluginConfig(bodyBytes, inputPluginConfigMd5)
+		bodyBytes, err := h.readResponse(resp)
 		if err != nil {
-			return err
+			return fmt.Errorf("%s [%s]: %s", action, url, err)
+		}
+
+		protocol := 1
+		if resp.Header.Get(protocolHeader) == "2" {
+			protocol = 2
 		}
+		return h.applyConfig(bodyBytes, resp.Header.Get(configSignatureHeader), resp.Header.Get("ETag"), revisions, protocol)
 	}
 
 	return nil
 }
 
-func (h *HTTP) addConfigParams(req *http.Request, inputPluginConfigMd5 string) error {
-	log.Printf("Bridge address : %s", h.URL)
-	q := req.URL.Query()
-	q.Add("md5", inputPluginConfigMd5)
-	q.Add("source", h.SourceAddress)
-	req.URL.RawQuery = q.Encode()
-	return nil
-}
-
-func (h *HTTP) updateInputPluginConfig(bodyBytes []byte, inputPluginConfigMd5 string) error {
-	inputPluginConfig := string(bodyBytes)
-	log.Printf("I! New input plugin config received : >>%s<<", inputPluginConfig)
-	if len(strings.TrimSpace(inputPluginConfig)) == 0 {
-		return nil
+// readResponse reads the body of a response of the bridge, failing when it is
+// larger than max_response_size.
+func (h *HTTP) readResponse(resp *http.Response) ([]byte, error) {
+	limit := h.MaxResponseSize.Size
+	if limit <= 0 {
+		return ioutil.ReadAll(resp.Body)
 	}
-	err := updateInputPluginConfig(inputPluginConfig, inputPluginConfigMd5, h.ConfigFilePath)
-	if err != nil {
-		return err
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("response of %d bytes exceeds max_response_size", resp.ContentLength)
 	}
-	return nil
-}
 
-func init() {
-	outputs.Add("http", func() telegraf.Output {
-		return &HTTP{
-			Timeout: internal.Duration{Duration: defaultClientTimeout},
-			Method:  defaultMethod,
-			URL:     defaultURL,
-		}
-	})
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response exceeds max_response_size of %d bytes", limit)
+	}
+	return body, nil
 }
 
-func updateInputPluginConfig(inputPluginConfig string, inputPluginConfigMd5 string, configFilePath string) error {
-	const InputPluginStart = "#                            INPUT PLUGINS                                    #"
-	const PluginEnd = "###############################################################################"
+// prepareConfigRequest adds the revisions of the local plugin config to req
+// and returns them.
+func (h *HTTP) prepareConfigRequest(req *http.Request) (map[string]string, error) {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
 
-	err := os.Chdir(configFilePath)
+	revisions, err := h.configRevisions()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("reading plugin config revisions: %s", err)
 	}
-
-	// create a new temp config file
-	fout, err := os.Create("telegraf.conf.new")
+	err = h.addConfigParams(req, revisions)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if etag := h.etagFor(revisions); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	req.Header.Set(protocolHeader, "2")
+	return revisions, nil
+}
+
+// etagFor returns the ETag of the last plugin config received when the local
+// config is still at the revisions it was received for.
+func (h *HTTP) etagFor(revisions map[string]string) string {
+	if reflect.DeepEqual(revisions, h.configETagRevisions) {
+		return h.configETag
+	}
+	return ""
+}
 
-	// read the current config file
-	fin, err := os.OpenFile("telegraf.conf", os.O_RDONLY, os.ModePerm)
+// applyConfig applies a response of the bridge for the local config at
+// revisions, and remembers its ETag.  The body is plugin config with
+// protocol 1, or an envelope with protocol 2.
+func (h *HTTP) applyConfig(body []byte, signature string, etag string, revisions map[string]string, protocol int) error {
+	var err error
+	if protocol == 2 {
+		err = h.applyEnvelope(body, signature, revisions)
+	} else {
+		err = h.updatePluginConfig(body, signature, revisions)
+	}
 	if err != nil {
+		h.configETag = ""
 		return err
 	}
+	h.configETag = etag
+	h.configETagRevisions = revisions
+	return nil
+}
 
-	rd := bufio.NewReader(fin)
-
-	// read the file and write to the ouptput file until the start of Input Plugin section
-	copyLineToOutput := true
-	lineNumber := 1
-	inputPluginLinesStart := 0
-
-	for {
-		line, err := rd.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-
-		// calculate the start line number of input plugin config section
-		if strings.Contains(line, InputPluginStart) && inputPluginLinesStart == 0 {
-			inputPluginLinesStart = lineNumber + 4
-		}
-
-		// insert revision (md5) and timestamp (This use two lines)
-		if lineNumber == inputPluginLinesStart-2 {
-			_, err2 := fmt.Fprint(fout, fmt.Sprintf("# Revision: %s, Time: %s #\n", inputPluginConfigMd5,
-				time.Now().Format(time.RFC3339)))
-			if err2 != nil {
-				return err
-			}
-		}
-
-		// do not output plugin config section and revsion/timestamp line (2 lines with the newline) to output file
-		if lineNumber == inputPluginLinesStart-2 {
-			copyLineToOutput = false
-
-			_, err := fmt.Fprintln(fout)
-			if err != nil {
-				return err
-			}
-
-			_, err = fmt.Fprint(fout, inputPluginConfig)
-			if err != nil {
-				return err
-			}
-
-			_, err = fmt.Fprintln(fout)
-			if err != nil {
-				return err
-			}
-		}
-
-		// start copying content to output file when input plugin config section end
-		if strings.Contains(line, PluginEnd) && lineNumber > inputPluginLinesStart {
-			copyLineToOutput = true
-		}
-
-		// write all lines from original config file to new config files excluding input plugin config section
-		if copyLineToOutput == true {
-			_, err := fmt.Fprint(fout, line)
-			if err != nil {
-				return err
-			}
+func (h *HTTP) addConfigParams(req *http.Request, revisions map[string]string) error {
+	log.Printf("Bridge address : %s", h.bridgeURL())
+	q := req.URL.Query()
+	q.Add("md5", revisions["inputs"])
+	for _, section := range configSections {
+		if revision, ok := revisions[section]; ok && section != "inputs" {
+			q.Add("md5_"+section, revision)
 		}
-
-		lineNumber++
 	}
+	q.Add("source", h.SourceAddress)
+	if h.configError != "" {
+		q.Add("config_error", h.configError)
+	}
+	req.URL.RawQuery = q.Encode()
+	return nil
+}
 
-	err = fout.Close()
-	if err != nil {
-		return err
+// bridgeURL returns the address plugin config is received from.
+func (h *HTTP) bridgeURL() string {
+	if h.GRPCAddress != "" {
+		return h.GRPCAddress
 	}
+	if h.ConfigURL != "" {
+		return h.ConfigURL
+	}
+	if h.failover != nil {
+		return h.failover.activeURL()
+	}
+	return h.URL
+}
 
-	err = fin.Close()
-	if err != nil {
-		return err
+// configRevisions returns the revisions of the plugin config managed by the
+// bridge.
+func (h *HTTP) configRevisions() (map[string]string, error) {
+	if h.ConfigDirectory != "" {
+		return calculateFragmentRevisions(h.ConfigFilePath, h.ConfigDirectory)
 	}
+	return calculateConfigRevisions(h.ConfigFilePath)
+}
 
-	// remove current config file
-	err = os.Remove("telegraf.conf")
-	if err != nil {
-		return err
+func (h *HTTP) updatePluginConfig(bodyBytes []byte, signature string, revisions map[string]string) error {
+	pluginConfig := string(bodyBytes)
+	log.Printf("I! New plugin config received : >>%s<<", pluginConfig)
+	if len(strings.TrimSpace(pluginConfig)) == 0 {
+		return nil
 	}
 
-	// rename new config file
-	err = os.Rename("telegraf.conf.new", "telegraf.conf")
+	err := h.verifyConfigSignature(bodyBytes, signature)
 	if err != nil {
-		return err
+		return h.rejectPluginConfig(nil, err)
 	}
 
-	// restart Telegraf to load new input plugin configs
-	err = reloadConfig()
-	if err != nil {
+	changed, err := h.applyPluginConfig(pluginConfig, revisions, nil)
+	if err != nil || !changed {
 		return err
 	}
 
-	return nil
+	// restart Telegraf to load new plugin configs
+	return reloadConfig()
 }
 
-func calculateMd5OfInputPluginConfig(configFilePath string) (string, error) {
-	const InputPluginStart = "[[inputs."
-	const PluginEnd = "###############################################################################"
-
-	err := os.Chdir(configFilePath)
-	if err != nil {
-		return "", err
+// applyPluginConfig updates the local config with plugin config received
+// from the bridge, and with agent settings when not nil, returning whether it
+// changed.
+func (h *HTTP) applyPluginConfig(pluginConfig string, revisions map[string]string, settings *agentSettings) (bool, error) {
+	var sections map[string]string
+	var changed bool
+	var err error
+	if h.ConfigDirectory != "" {
+		sections, changed, err = h.updateFragments(pluginConfig, settings)
+	} else {
+		sections, changed, err = h.updateSections(pluginConfig, revisions, settings)
 	}
-
-	// read the current config file
-	fin, err := os.OpenFile("telegraf.conf", os.O_RDONLY, os.ModePerm)
 	if err != nil {
-		return "", err
+		return false, h.rejectPluginConfig(sections, err)
+	}
+	if !changed {
+		log.Printf("D! No plugin config changes from [%s]", h.bridgeURL())
+		return false, nil
 	}
 
-	rd := bufio.NewReader(fin)
-
-	writeToBuf := false
-	lineNumber := 1
-	inputPluginLinesStart := 0
-	inputPluginConfMd5 := md5.New()
+	h.stats.configApplied()
+	h.reportConfigStatus(sections, nil)
+	return true, nil
+}
 
-	inputPluginConfigStr := ""
-	for {
-		line, err := rd.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return "", err
-		}
+// rejectPluginConfig reports plugin config that could not be applied to the
+// bridge and returns the error for the write.
+func (h *HTTP) rejectPluginConfig(sections map[string]string, err error) error {
+	h.reportConfigStatus(sections, err)
+	h.configError = err.Error()
+	return fmt.Errorf("plugin config from [%s] rejected: %s", h.bridgeURL(), err)
+}
 
-		// calculate the start line number of input plugin config section
-		if strings.Contains(line, InputPluginStart) && inputPluginLinesStart == 0 {
-			inputPluginLinesStart = lineNumber
-		}
+// updateSections merges the sections of config received from the bridge
+// that differ from the current config into telegraf.conf.
+func (h *HTTP) updateSections(pluginConfig string, revisions map[string]string, settings *agentSettings) (map[string]string, bool, error) {
+	sections, err := splitConfigSections(pluginConfig)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid plugin config: %s", err)
+	}
 
-		// write input plugin config section to the buffer
-		if lineNumber == inputPluginLinesStart {
-			writeToBuf = true
-		}
+	if dropUnchangedSections(sections, revisions) == 0 && settings == nil {
+		return sections, false, nil
+	}
 
-		// break the loop after finish reading input plugin config
-		if strings.Contains(line, PluginEnd) && inputPluginLinesStart > 0 && lineNumber > inputPluginLinesStart {
-			break
-		}
+	err = h.checkRemotePlugins(pluginConfig)
+	if err != nil {
+		return sections, true, err
+	}
 
-		if writeToBuf && len(strings.TrimSpace(line)) > 0 {
-			inputPluginConfigStr += line
-		}
+	changed, err := updatePluginConfig(sections, revisions, settings, h.ConfigFilePath, h.ConfigValidationTimeout.Duration)
+	return sections, changed, err
+}
 
-		lineNumber++
+// updateFragments writes the plugin tables of config received from the
+// bridge to the config directory.
+func (h *HTTP) updateFragments(pluginConfig string, settings *agentSettings) (map[string]string, bool, error) {
+	fragments, err := splitFragments(pluginConfig)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid plugin config: %s", err)
 	}
 
-	err = fin.Close()
+	sections := fragmentSections(fragments)
+	err = h.checkRemotePlugins(pluginConfig)
 	if err != nil {
-		return "", err
+		return sections, true, err
 	}
 
-	inputPluginConfigStr = strings.TrimSuffix(strings.TrimSuffix(inputPluginConfigStr, "\n"), "\r")
-	_, err = io.WriteString(inputPluginConfMd5, inputPluginConfigStr)
-
-	inputPluginConfigStr = ">>" + inputPluginConfigStr + "<<"
-
-	log.Printf("inputPluginConfMd5 : %s", inputPluginConfigStr)
-	return fmt.Sprintf("%x", inputPluginConfMd5.Sum(nil)), nil
+	changed, err := updateFragments(fragments, settings, h.ConfigFilePath, h.ConfigDirectory, h.ConfigValidationTimeout.Duration)
+	return sections, changed, err
 }
 
-func reloadConfig() error {
-	file, err := osext.Executable()
-	if err != nil {
-		return err
+// checkRemotePlugins returns an error when config received from the bridge
+// contains plugins that are not in allowed_remote_plugins.
+func (h *HTTP) checkRemotePlugins(pluginConfig string) error {
+	if h.remoteFilter == nil {
+		return nil
 	}
 
-	log.Println("Restarting Telegraf to load new input plugin configuration ...")
-	err = syscall.Exec(file, os.Args, os.Environ())
+	plugins, err := configPlugins(pluginConfig)
 	if err != nil {
 		return err
 	}
+
+	var denied []string
+	for _, plugin := range plugins {
+		if !h.remoteFilter.Match(plugin) {
+			denied = append(denied, plugin)
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("plugins not allowed: %s", strings.Join(denied, ", "))
+	}
 	return nil
 }
+
+func init() {
+	outputs.Add("http", func() telegraf.Output {
+		return &HTTP{
+			Timeout:                 internal.Duration{Duration: defaultClientTimeout},
+			Method:                  defaultMethod,
+			URL:                     defaultURL,
+			ConfigValidationTimeout: internal.Duration{Duration: defaultConfigValidationTimeout},
+			ConfigInterval:          internal.Duration{Duration: defaultConfigInterval},
+			FailbackInterval:        internal.Duration{Duration: defaultFailbackInterval},
+			RetryMaxAttempts:        1,
+			RetryInitialBackoff:     internal.Duration{Duration: defaultRetryInitialBackoff},
+			RetryMaxBackoff:         internal.Duration{Duration: defaultRetryMaxBackoff},
+			CircuitBreakerCooldown:  internal.Duration{Duration: defaultCircuitBreakerCooldown},
+			QueueSize:               defaultQueueSize,
+			MaxResponseSize:         internal.Size{Size: defaultMaxResponseSize},
+			LogUploadMaxSize:        internal.Size{Size: defaultLogUploadMaxSize},
+			DebugMaxDuration:        internal.Duration{Duration: defaultDebugMaxDuration},
+			CommandMaxAge:           internal.Duration{Duration: defaultCommandMaxAge},
+			QueueOverflow:           queueBlock,
+			ManageConfig:            true,
+		}
+	})
+}
@@ -2,18 +2,30 @@ package http
 
 import (
 	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/golang/snappy"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/plugins/serializers/json"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 )
 
@@ -190,10 +202,14 @@ func TestContentType(t *testing.T) {
 	u, err := url.Parse(fmt.Sprintf("http://%s", ts.Listener.Addr().String()))
 	require.NoError(t, err)
 
+	jsonSerializer, err := json.NewSerializer(time.Second)
+	require.NoError(t, err)
+
 	tests := []struct {
-		name     string
-		plugin   *HTTP
-		expected string
+		name       string
+		plugin     *HTTP
+		serializer serializers.Serializer
+		expected   string
 	}{
 		{
 			name: "default is text plain",
@@ -210,6 +226,23 @@ func TestContentType(t *testing.T) {
 			},
 			expected: "application/json",
 		},
+		{
+			name: "json data_format",
+			plugin: &HTTP{
+				URL: u.String(),
+			},
+			serializer: jsonSerializer,
+			expected:   "application/json",
+		},
+		{
+			name: "overwrite json data_format",
+			plugin: &HTTP{
+				URL:     u.String(),
+				Headers: map[string]string{"Content-Type": "application/vnd.metrics+json"},
+			},
+			serializer: jsonSerializer,
+			expected:   "application/vnd.metrics+json",
+		},
 	}
 
 	for _, tt := range tests {
@@ -219,7 +252,10 @@ func TestContentType(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 			})
 
-			serializer := influx.NewSerializer()
+			serializer := tt.serializer
+			if serializer == nil {
+				serializer = influx.NewSerializer()
+			}
 			tt.plugin.SetSerializer(serializer)
 			err = tt.plugin.Connect()
 			require.NoError(t, err)
@@ -290,6 +326,118 @@ func TestContentEncodingGzip(t *testing.T) {
 	}
 }
 
+func TestContentEncodingCompressed(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	decoders := map[string]func([]byte) ([]byte, error){
+		"zstd": func(b []byte) ([]byte, error) {
+			decoder, err := zstd.NewReader(nil)
+			if err != nil {
+				return nil, err
+			}
+			defer decoder.Close()
+			return decoder.DecodeAll(b, nil)
+		},
+		"snappy": func(b []byte) ([]byte, error) {
+			return snappy.Decode(nil, b)
+		},
+	}
+
+	for encoding, decode := range decoders {
+		t.Run(encoding, func(t *testing.T) {
+			ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, encoding, r.Header.Get("Content-Encoding"))
+
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				payload, err := decode(body)
+				require.NoError(t, err)
+				require.Contains(t, string(payload), "cpu value=42")
+
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			plugin := &HTTP{
+				URL:             ts.URL,
+				ContentEncoding: encoding,
+			}
+			plugin.SetSerializer(influx.NewSerializer())
+			err := plugin.Connect()
+			require.NoError(t, err)
+
+			err = plugin.Write([]telegraf.Metric{getMetric()})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPayloadChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum string
+		header   string
+		expected func(body []byte) string
+	}{
+		{
+			name:     "md5",
+			checksum: "md5",
+			header:   "Content-MD5",
+			expected: func(body []byte) string {
+				sum := md5.Sum(body)
+				return base64.StdEncoding.EncodeToString(sum[:])
+			},
+		},
+		{
+			name:     "sha256",
+			checksum: "sha256",
+			header:   "x-content-sha256",
+			expected: func(body []byte) string {
+				sum := sha256.Sum256(body)
+				return hex.EncodeToString(sum[:])
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, tt.expected(body), r.Header.Get(tt.header))
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer ts.Close()
+
+			plugin := &HTTP{
+				URL:             ts.URL,
+				ContentEncoding: "gzip",
+				PayloadChecksum: tt.checksum,
+			}
+			plugin.SetSerializer(influx.NewSerializer())
+			require.NoError(t, plugin.Connect())
+			require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+		})
+	}
+}
+
+func TestPayloadChecksumInvalid(t *testing.T) {
+	plugin := &HTTP{
+		URL:             "http://localhost",
+		PayloadChecksum: "crc32",
+	}
+	require.Error(t, plugin.Connect())
+}
+
+func TestContentEncodingInvalid(t *testing.T) {
+	plugin := &HTTP{
+		URL:             defaultURL,
+		ContentEncoding: "brotli",
+	}
+	err := plugin.Connect()
+	require.Error(t, err)
+}
+
 func TestBasicAuth(t *testing.T) {
 	ts := httptest.NewServer(http.NotFoundHandler())
 	defer ts.Close()
@@ -400,6 +548,32 @@ func TestOAuthClientCredentialsGrant(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 			},
 		},
+		{
+			name: "audience and endpoint params",
+			plugin: &HTTP{
+				URL:            u.String() + "/write",
+				ClientID:       "howdy",
+				ClientSecret:   "secret",
+				TokenURL:       u.String() + "/token",
+				Audience:       "https://bridge",
+				EndpointParams: map[string]string{"resource": "telegraf"},
+			},
+			tokenHandler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, r.ParseForm())
+				require.Equal(t, "https://bridge", r.PostForm.Get("audience"))
+				require.Equal(t, "telegraf", r.PostForm.Get("resource"))
+				w.WriteHeader(http.StatusOK)
+				values := url.Values{}
+				values.Add("access_token", token)
+				values.Add("token_type", "bearer")
+				values.Add("expires_in", "3600")
+				w.Write([]byte(values.Encode()))
+			},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, []string{"Bearer " + token}, r.Header["Authorization"])
+				w.WriteHeader(http.StatusOK)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -424,6 +598,42 @@ func TestOAuthClientCredentialsGrant(t *testing.T) {
 	}
 }
 
+func TestOAuthTokenFailure(t *testing.T) {
+	var tokenAvailable int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if atomic.LoadInt32(&tokenAvailable) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			w.Write([]byte("access_token=abc&token_type=bearer&expires_in=3600"))
+		case "/write":
+			require.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:          ts.URL + "/write",
+		ClientID:     "howdy",
+		ClientSecret: "secret",
+		TokenURL:     ts.URL + "/token",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	err := plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.True(t, isTokenError(err))
+	require.False(t, isFailoverError(err))
+
+	atomic.StoreInt32(&tokenAvailable, 1)
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+}
+
 func TestDefaultUserAgent(t *testing.T) {
 	ts := httptest.NewServer(http.NotFoundHandler())
 	defer ts.Close()
@@ -453,3 +663,287 @@ func TestDefaultUserAgent(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestLockFileIsExclusive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, configLockFile)
+	lock, err := lockFile(path)
+	require.NoError(t, err)
+
+	// the second lock sends the error of taking it, then of releasing it
+	errs := make(chan error, 2)
+	go func() {
+		second, err := lockFile(path)
+		errs <- err
+		if err == nil {
+			errs <- unlockFile(second)
+		}
+	}()
+
+	select {
+	case <-errs:
+		t.Fatal("second lock acquired while first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, unlockFile(lock))
+
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not acquired after release")
+	}
+	require.NoError(t, <-errs)
+}
+
+func TestRemotePluginNotAllowed(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	u, err := url.Parse(fmt.Sprintf("http://%s", ts.Listener.Addr().String()))
+	require.NoError(t, err)
+
+	var configErrors []string
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configErrors = append(configErrors, r.URL.Query().Get("config_error"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[[inputs.cpu]]\n[[inputs.exec]]\n  commands = [\"rm -rf /\"]\n"))
+	})
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:         true,
+		ConfigFilePath:       dir,
+		URL:                  u.String(),
+		AllowedRemotePlugins: []string{"inputs.cpu", "inputs.mem"},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	err = plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "inputs.exec")
+
+	err = plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+
+	require.Equal(t, []string{"", "plugins not allowed: inputs.exec"}, configErrors)
+}
+
+func TestConfigETag(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n\n[[inputs.mock]]\n")
+	defer cleanup()
+
+	var ifNoneMatch []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[[inputs.mock]]\n"))
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		ManageConfig:   true,
+		URL:            ts.URL,
+		ConfigFilePath: dir,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+
+	// a local change to the config invalidates the ETag
+	err := ioutil.WriteFile(filepath.Join(dir, "telegraf.conf"), []byte("[[outputs.http]]\n  timeout = \"1s\"\n\n[[inputs.mock]]\n"), 0644)
+	require.NoError(t, err)
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+
+	require.Equal(t, []string{"", `"v1"`, ""}, ifNoneMatch)
+}
+
+func TestConfigRevisionsError(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[inputs.cpu]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:   true,
+		ConfigFilePath: dir,
+		URL:            ts.URL,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	err := plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "reading plugin config revisions")
+	require.Equal(t, 0, requests)
+}
+
+func TestConfigCheckInterval(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:        true,
+		ConfigFilePath:      dir,
+		URL:                 ts.URL,
+		ConfigCheckInterval: internal.Duration{Duration: time.Hour},
+		ConfigJitter:        internal.Duration{Duration: time.Minute},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+
+	require.Len(t, queries, 2)
+	require.Contains(t, queries[0], "md5=")
+	require.Empty(t, queries[1])
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunked bool
+	}{
+		{
+			name: "content length",
+		},
+		{
+			name:    "chunked",
+			chunked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				if tt.chunked {
+					w.(http.Flusher).Flush()
+				}
+				w.Write([]byte(strings.Repeat("#", 2048)))
+			}))
+			defer ts.Close()
+
+			dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+			defer cleanup()
+
+			plugin := &HTTP{
+				ManageConfig:    true,
+				ConfigFilePath:  dir,
+				URL:             ts.URL,
+				MaxResponseSize: internal.Size{Size: 1024},
+			}
+			plugin.SetSerializer(influx.NewSerializer())
+			require.NoError(t, plugin.Connect())
+
+			err := plugin.Write([]telegraf.Metric{getMetric()})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "max_response_size")
+		})
+	}
+}
+
+func TestManageConfigDisabled(t *testing.T) {
+	config := "[[outputs.http]]\n\n[[inputs.mock]]\n"
+	dir, cleanup := writeTestConfig(t, config)
+	defer cleanup()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Empty(t, r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[[inputs.exec]]\n"))
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:            ts.URL,
+		ConfigFilePath: dir,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "telegraf.conf"))
+	require.NoError(t, err)
+	require.Equal(t, config, string(b))
+
+	plugin = &HTTP{
+		URL:       ts.URL,
+		ConfigURL: ts.URL,
+	}
+	require.Error(t, plugin.Connect())
+}
+
+func TestConfigURL(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n\n[[inputs.mock]]\n")
+	defer cleanup()
+
+	polled := make(chan url.Values, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Empty(t, r.URL.RawQuery)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("not a config"))
+		case "/config":
+			require.Equal(t, http.MethodGet, r.Method)
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("[[inputs.mock]]\n"))
+			select {
+			case polled <- r.URL.Query():
+			default:
+			}
+		}
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		ManageConfig:   true,
+		URL:            ts.URL + "/write",
+		ConfigURL:      ts.URL + "/config",
+		ConfigInterval: internal.Duration{Duration: time.Hour},
+		ConfigFilePath: dir,
+		SourceAddress:  "10.0.0.1",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	select {
+	case query := <-polled:
+		require.Equal(t, "10.0.0.1", query.Get("source"))
+		require.NotEmpty(t, query.Get("md5"))
+	case <-time.After(5 * time.Second):
+		t.Fatal("config not polled")
+	}
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+}
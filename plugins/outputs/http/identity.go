@@ -0,0 +1,180 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf/internal"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	agentIDHeader    = "X-Agent-ID"
+	agentTokenHeader = "X-Agent-Token"
+
+	defaultIdentityFile = "bridge-identity.json"
+)
+
+// agentIdentity identifies the agent to the bridge.  The agent ID is
+// generated once, and the token is issued by the bridge on registration.
+// Both are kept in identity_file so they survive restarts.
+type agentIdentity struct {
+	AgentID string `json:"agent_id"`
+	Token   string `json:"token,omitempty"`
+
+	mu sync.Mutex
+}
+
+// registration is the request body of registration_url.
+type registration struct {
+	AgentID       string `json:"agent_id"`
+	Hostname      string `json:"hostname"`
+	OS            string `json:"os"`
+	Arch          string `json:"arch"`
+	Version       string `json:"version"`
+	SourceAddress string `json:"source_address,omitempty"`
+}
+
+// loadIdentity reads the identity of the agent from path, generating a new
+// agent ID when the file does not exist yet.
+func loadIdentity(path string) (*agentIdentity, error) {
+	identity := &agentIdentity{}
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	default:
+		err = json.Unmarshal(b, identity)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity_file %s: %s", path, err)
+		}
+	}
+
+	if identity.AgentID == "" {
+		identity.AgentID = uuid.NewV4().String()
+	}
+	return identity, nil
+}
+
+// save writes the identity to path, readable by the owner only as the token
+// is a credential.
+func (i *agentIdentity) save(path string) error {
+	i.mu.Lock()
+	b, err := json.Marshal(i)
+	i.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+func (i *agentIdentity) token() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.Token
+}
+
+func (i *agentIdentity) setToken(token string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Token = token
+}
+
+// register sends the details of the agent to registration_url unless it
+// already has a token, and keeps the token the bridge answers with.
+func (h *HTTP) register() error {
+	if h.identity.token() != "" {
+		return nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(&registration{
+		AgentID:       h.identity.AgentID,
+		Hostname:      hostname,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		Version:       internal.Version(),
+		SourceAddress: h.SourceAddress,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.RegistrationURL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	if h.Username != "" || h.Password != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+	for k, v := range h.Headers {
+		if strings.ToLower(k) == "host" {
+			req.Host = v
+		}
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newStatusError("registering with", h.RegistrationURL, resp)
+	}
+	b, err := h.readResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	var answer struct {
+		Token string `json:"token"`
+	}
+	err = json.Unmarshal(b, &answer)
+	if err != nil {
+		return fmt.Errorf("invalid registration response: %s", err)
+	}
+	if answer.Token == "" {
+		return fmt.Errorf("registration response without token")
+	}
+
+	h.identity.setToken(answer.Token)
+	return h.identity.save(h.IdentityFile)
+}
+
+// identityTransport adds the identity of the agent to the requests sent
+// with next.
+type identityTransport struct {
+	next     http.RoundTripper
+	identity *agentIdentity
+}
+
+func (t *identityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+2)
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+
+	r.Header.Set(agentIDHeader, t.identity.AgentID)
+	if token := t.identity.token(); token != "" {
+		r.Header.Set(agentTokenHeader, token)
+	}
+	return t.next.RoundTrip(r)
+}
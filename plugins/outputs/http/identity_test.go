@@ -0,0 +1,87 @@
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	registrations := 0
+	var agentID string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/register":
+			registrations++
+			var reg registration
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&reg))
+			require.NotEmpty(t, reg.AgentID)
+			require.NotEmpty(t, reg.Hostname)
+			require.Equal(t, "10.0.0.1", reg.SourceAddress)
+			require.Equal(t, reg.AgentID, r.Header.Get(agentIDHeader))
+			require.Empty(t, r.Header.Get(agentTokenHeader))
+			agentID = reg.AgentID
+			w.Write([]byte(`{"token": "secret"}`))
+		case "/write":
+			require.Equal(t, agentID, r.Header.Get(agentIDHeader))
+			require.Equal(t, "secret", r.Header.Get(agentTokenHeader))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	newPlugin := func() *HTTP {
+		plugin := &HTTP{
+			URL:             ts.URL + "/write",
+			SourceAddress:   "10.0.0.1",
+			RegistrationURL: ts.URL + "/register",
+			ConfigFilePath:  dir,
+		}
+		plugin.SetSerializer(influx.NewSerializer())
+		return plugin
+	}
+
+	plugin := newPlugin()
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+
+	info, err := os.Stat(filepath.Join(dir, defaultIdentityFile))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// the identity is kept across restarts
+	plugin = newPlugin()
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, 1, registrations)
+}
+
+func TestRegistrationWithoutToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:             ts.URL,
+		RegistrationURL: ts.URL,
+		IdentityFile:    filepath.Join(dir, "identity.json"),
+	}
+	require.Error(t, plugin.Connect())
+}
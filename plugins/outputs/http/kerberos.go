@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+)
+
+// negotiator creates the token of a Negotiate authorization header for a
+// service principal name.
+type negotiator interface {
+	token(spn string) (string, error)
+}
+
+// negotiateTransport authenticates requests with Kerberos through SPNEGO
+// before sending them with next.
+type negotiateTransport struct {
+	next       http.RoundTripper
+	negotiator negotiator
+	spn        string
+}
+
+// RoundTrip authenticates a copy of req, as a RoundTripper must not modify
+// the request it is given.  Unless kerberos_spn is set, the service
+// principal is HTTP/<host of the request>.
+func (t *negotiateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	spn := t.spn
+	if spn == "" {
+		spn = "HTTP/" + req.URL.Hostname()
+	}
+
+	token, err := t.negotiator.token(spn)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	authenticated := new(http.Request)
+	*authenticated = *req
+	authenticated.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		authenticated.Header[k] = append([]string(nil), v...)
+	}
+	authenticated.Header.Set("Authorization", "Negotiate "+token)
+	return t.next.RoundTrip(authenticated)
+}
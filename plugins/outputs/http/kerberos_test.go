@@ -0,0 +1,56 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNegotiator struct {
+	spns []string
+	err  error
+}
+
+func (n *fakeNegotiator) token(spn string) (string, error) {
+	n.spns = append(n.spns, spn)
+	return "dG9rZW4=", n.err
+}
+
+func TestNegotiateTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Negotiate dG9rZW4=", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL: ts.URL,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	n := &fakeNegotiator{}
+	plugin.client.Transport = &negotiateTransport{
+		next:       plugin.client.Transport,
+		negotiator: n,
+	}
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, []string{"HTTP/127.0.0.1"}, n.spns)
+
+	n.err = fmt.Errorf("no ticket")
+	require.Error(t, plugin.Write([]telegraf.Metric{getMetric()}))
+}
+
+func TestKerberosWithBasicAuth(t *testing.T) {
+	plugin := &HTTP{
+		URL:      defaultURL,
+		Kerberos: true,
+		Username: "telegraf",
+	}
+	require.Error(t, plugin.Connect())
+}
@@ -0,0 +1,67 @@
+// +build !windows
+
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"gopkg.in/jcmturner/gokrb5.v7/client"
+	"gopkg.in/jcmturner/gokrb5.v7/config"
+	"gopkg.in/jcmturner/gokrb5.v7/keytab"
+	"gopkg.in/jcmturner/gokrb5.v7/spnego"
+)
+
+const defaultKerberosConfig = "/etc/krb5.conf"
+
+// keytabNegotiator logs in with the key of kerberos_principal read from
+// kerberos_keytab.
+type keytabNegotiator struct {
+	client *client.Client
+}
+
+func (h *HTTP) newNegotiator() (negotiator, error) {
+	if h.KerberosPrincipal == "" || h.KerberosKeytab == "" {
+		return nil, fmt.Errorf("kerberos requires kerberos_principal and kerberos_keytab")
+	}
+	parts := strings.SplitN(h.KerberosPrincipal, "@", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid kerberos_principal %q, expected user@REALM", h.KerberosPrincipal)
+	}
+
+	kt, err := keytab.Load(h.KerberosKeytab)
+	if err != nil {
+		return nil, fmt.Errorf("loading kerberos_keytab: %s", err)
+	}
+
+	configPath := h.KerberosConfig
+	if configPath == "" {
+		configPath = defaultKerberosConfig
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading kerberos_config: %s", err)
+	}
+
+	// the client logs in on its own when the first ticket is requested
+	cl := client.NewClientWithKeytab(parts[0], parts[1], kt, cfg, client.DisablePAFXFAST(true))
+	return &keytabNegotiator{client: cl}, nil
+}
+
+func (n *keytabNegotiator) token(spn string) (string, error) {
+	s := spnego.SPNEGOClient(n.client, spn)
+	err := s.AcquireCred()
+	if err != nil {
+		return "", fmt.Errorf("acquiring kerberos credentials: %s", err)
+	}
+	st, err := s.InitSecContext()
+	if err != nil {
+		return "", fmt.Errorf("initializing kerberos context for %s: %s", spn, err)
+	}
+	b, err := st.Marshal()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
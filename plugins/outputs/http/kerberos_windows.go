@@ -0,0 +1,34 @@
+// +build windows
+
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/alexbrainman/sspi"
+	"github.com/alexbrainman/sspi/negotiate"
+)
+
+// sspiNegotiator authenticates as the account Telegraf runs as, through
+// SSPI.
+type sspiNegotiator struct {
+	cred *sspi.Credentials
+}
+
+func (h *HTTP) newNegotiator() (negotiator, error) {
+	cred, err := negotiate.AcquireCurrentUserCredentials()
+	if err != nil {
+		return nil, fmt.Errorf("acquiring kerberos credentials: %s", err)
+	}
+	return &sspiNegotiator{cred: cred}, nil
+}
+
+func (n *sspiNegotiator) token(spn string) (string, error) {
+	ctx, token, err := negotiate.NewClientContext(n.cred, spn)
+	if err != nil {
+		return "", fmt.Errorf("initializing kerberos context for %s: %s", spn, err)
+	}
+	defer ctx.Release()
+	return base64.StdEncoding.EncodeToString(token), nil
+}
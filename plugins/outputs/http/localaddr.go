@@ -0,0 +1,59 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// resolveLocalAddress returns the address connections to the bridge are made
+// from, given local_address as an IP or as the name of a network interface.
+// The IPv4 address of an interface is preferred.
+func resolveLocalAddress(address string) (*net.TCPAddr, error) {
+	if address == "" {
+		return nil, nil
+	}
+	if ip := net.ParseIP(address); ip != nil {
+		return &net.TCPAddr{IP: ip}, nil
+	}
+
+	iface, err := net.InterfaceByName(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local_address %q: %s", address, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var found net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return &net.TCPAddr{IP: ipnet.IP}, nil
+		}
+		if found == nil {
+			found = ipnet.IP
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no address on interface %q of local_address", address)
+	}
+	return &net.TCPAddr{IP: found}, nil
+}
+
+// newDialer returns a dialer of connections to the bridge bound to
+// local_address.
+func (h *HTTP) newDialer() *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   h.Timeout.Duration,
+		KeepAlive: 30 * time.Second,
+	}
+	if h.localAddr != nil {
+		dialer.LocalAddr = h.localAddr
+	}
+	return dialer
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLocalAddress(t *testing.T) {
+	addr, err := resolveLocalAddress("")
+	require.NoError(t, err)
+	require.Nil(t, addr)
+
+	addr, err = resolveLocalAddress("127.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", addr.IP.String())
+
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		addr, err = resolveLocalAddress(iface.Name)
+		require.NoError(t, err)
+		require.True(t, addr.IP.IsLoopback())
+	}
+
+	_, err = resolveLocalAddress("no-such-interface0")
+	require.Error(t, err)
+}
+
+func TestLocalAddress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", host)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:          ts.URL,
+		LocalAddress: "127.0.0.1",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+}
@@ -0,0 +1,47 @@
+// +build solaris
+
+package http
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockMu serializes the locks taken within the process, as fcntl locks are
+// held by the process rather than by the open file.
+var lockMu sync.Mutex
+
+// lockFile opens path, creating it if needed, and blocks until an exclusive
+// advisory lock is held on it.
+func lockFile(path string) (*os.File, error) {
+	lockMu.Lock()
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		lockMu.Unlock()
+		return nil, err
+	}
+
+	lk := unix.Flock_t{Type: unix.F_WRLCK}
+	err = unix.FcntlFlock(f.Fd(), unix.F_SETLKW, &lk)
+	if err != nil {
+		f.Close()
+		lockMu.Unlock()
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile releases a lock taken with lockFile and closes the file.
+func unlockFile(f *os.File) error {
+	defer lockMu.Unlock()
+
+	lk := unix.Flock_t{Type: unix.F_UNLCK}
+	err := unix.FcntlFlock(f.Fd(), unix.F_SETLK, &lk)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,34 @@
+// +build !windows,!solaris
+
+package http
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile opens path, creating it if needed, and blocks until an exclusive
+// advisory lock is held on it.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile releases a lock taken with lockFile and closes the file.
+func unlockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,37 @@
+// +build windows
+
+package http
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile opens path, creating it if needed, and blocks until an exclusive
+// lock is held on it.
+func lockFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	ol := new(windows.Overlapped)
+	err = windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, ol)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockFile releases a lock taken with lockFile and closes the file.
+func unlockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	err := windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,94 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/units"
+	"github.com/influxdata/telegraf/logger"
+)
+
+// uploadLogs sends the log file of the agent to log_upload_url as the
+// "file" part of a multipart form.  The "size" argument of the command, such
+// as "256KB", asks for only the end of the log; at most log_upload_max_size
+// bytes are sent.
+func (h *HTTP) uploadLogs(cmd *bridgeCommand) (string, error) {
+	if h.LogUploadURL == "" {
+		return "", errors.New("log_upload_url is not set")
+	}
+
+	filename := logger.LogFile()
+	if filename == "" {
+		return "", errors.New("the log is not written to a file")
+	}
+
+	size := h.LogUploadMaxSize.Size
+	if s, ok := cmd.Args["size"]; ok {
+		n, err := units.ParseStrictBytes(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid size %q: %s", s, err)
+		}
+		if size <= 0 || n < size {
+			size = n
+		}
+	}
+
+	content, err := readLogTail(filename, size)
+	if err != nil {
+		return "", err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fields := map[string]string{"id": cmd.ID, "source": h.SourceAddress}
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return "", err
+		}
+	}
+	part, err := w.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(content); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	err = h.post(h.LogUploadURL, w.FormDataContentType(), &body)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("uploaded %d bytes of %s", len(content), filename), nil
+}
+
+// readLogTail returns the last size bytes of a file, or all of it when size
+// is not positive.
+func readLogTail(filename string, size int64) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 && stat.Size() > size {
+		if _, err := f.Seek(-size, io.SeekEnd); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	_, err = io.Copy(&buf, f)
+	return buf.Bytes(), err
+}
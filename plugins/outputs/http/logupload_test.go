@@ -0,0 +1,68 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "telegraf.log")
+	require.NoError(t, ioutil.WriteFile(logfile, []byte(strings.Repeat("a", 2048)+"tail"), 0644))
+	logger.SetupLogging(logger.LogConfig{LogTarget: logger.LogTargetFile, Logfile: logfile})
+	defer logger.SetupLogging(logger.LogConfig{LogTarget: logger.LogTargetStderr, Quiet: true})
+
+	var uploaded []byte
+	var id, filename string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		uploaded, err = ioutil.ReadAll(f)
+		require.NoError(t, err)
+		filename = header.Filename
+		id = r.FormValue("id")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:              ts.URL,
+		LogUploadURL:     ts.URL,
+		LogUploadMaxSize: internal.Size{Size: 1024},
+	}
+	require.NoError(t, plugin.Connect())
+
+	_, err = plugin.uploadLogs(&bridgeCommand{ID: "1", Name: commandUploadLogs})
+	require.NoError(t, err)
+	require.Equal(t, "1", id)
+	require.Equal(t, "telegraf.log", filename)
+	require.Len(t, uploaded, 1024)
+	require.True(t, strings.HasSuffix(string(uploaded), "tail"))
+
+	_, err = plugin.uploadLogs(&bridgeCommand{Name: commandUploadLogs, Args: map[string]string{"size": "1KB"}})
+	require.NoError(t, err)
+	require.Len(t, uploaded, 1000)
+
+	_, err = plugin.uploadLogs(&bridgeCommand{Name: commandUploadLogs, Args: map[string]string{"size": "lots"}})
+	require.Error(t, err)
+}
+
+func TestUploadLogsNoFile(t *testing.T) {
+	plugin := &HTTP{LogUploadURL: "http://127.0.0.1:8080/telegraf/logs"}
+	logger.SetupLogging(logger.LogConfig{LogTarget: logger.LogTargetStderr, Quiet: true})
+
+	_, err := plugin.uploadLogs(&bridgeCommand{Name: commandUploadLogs})
+	require.Error(t, err)
+}
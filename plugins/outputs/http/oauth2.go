@@ -0,0 +1,77 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// tokenError is returned when no OAuth2 token could be obtained.  It is not
+// a failure of the bridge, the token is requested again on the next write.
+type tokenError struct {
+	tokenURL string
+	err      error
+}
+
+func (e *tokenError) Error() string {
+	return fmt.Sprintf("getting OAuth2 token from [%s]: %s", e.tokenURL, e.err)
+}
+
+// tokenSource wraps the errors of a token source in a tokenError.
+type tokenSource struct {
+	source   oauth2.TokenSource
+	tokenURL string
+}
+
+func (s *tokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, &tokenError{tokenURL: s.tokenURL, err: err}
+	}
+	return token, nil
+}
+
+// isTokenError reports whether err is a failure to obtain an OAuth2 token.
+func isTokenError(err error) bool {
+	if e, ok := err.(*url.Error); ok {
+		err = e.Err
+	}
+	_, ok := err.(*tokenError)
+	return ok
+}
+
+// oauth2Client returns a client adding a token of the client credentials
+// flow to the requests of client.  Tokens are requested with client as well,
+// when first needed and once expired, so a token endpoint that is down only
+// fails the writes made meanwhile.
+func (h *HTTP) oauth2Client(ctx context.Context, client *http.Client) *http.Client {
+	oauthConfig := clientcredentials.Config{
+		ClientID:       h.ClientID,
+		ClientSecret:   h.ClientSecret,
+		TokenURL:       h.TokenURL,
+		Scopes:         h.Scopes,
+		EndpointParams: url.Values{},
+	}
+	if h.Audience != "" {
+		oauthConfig.EndpointParams.Set("audience", h.Audience)
+	}
+	for k, v := range h.EndpointParams {
+		oauthConfig.EndpointParams.Set(k, v)
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+	return &http.Client{
+		Transport: &oauth2.Transport{
+			Source: &tokenSource{
+				source:   oauthConfig.TokenSource(ctx),
+				tokenURL: h.TokenURL,
+			},
+			Base: client.Transport,
+		},
+		Timeout: client.Timeout,
+	}
+}
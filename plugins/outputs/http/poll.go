@@ -0,0 +1,104 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// startConfigPolling requests plugin config from the bridge right away and
+// then every config_interval plus up to config_jitter, or when requested with
+// requestConfigPoll, until Close is called.
+func (h *HTTP) startConfigPolling() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancelPolling = cancel
+	h.pollNow = make(chan struct{}, 1)
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		for {
+			err := h.pollConfig()
+			if err != nil {
+				log.Printf("E! [outputs.http] Polling config from [%s]: %s", h.bridgeURL(), err)
+			}
+
+			timer := time.NewTimer(h.ConfigInterval.Duration + internal.RandomDuration(h.ConfigJitter.Duration))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			case <-h.pollNow:
+				timer.Stop()
+			}
+		}
+	}()
+}
+
+// requestConfigPoll makes the polling loop request plugin config without
+// waiting for config_interval.  Requests made while one is pending are
+// merged.
+func (h *HTTP) requestConfigPoll() {
+	select {
+	case h.pollNow <- struct{}{}:
+	default:
+	}
+}
+
+func (h *HTTP) stopConfigPolling() {
+	if h.cancelPolling != nil {
+		h.cancelPolling()
+		h.cancelPolling = nil
+	}
+}
+
+// pollConfig requests plugin config from config_url, or with GetConfig when
+// using gRPC.
+func (h *HTTP) pollConfig() error {
+	if h.GRPCAddress != "" {
+		return h.getConfigGRPC()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, h.ConfigURL, nil)
+	if err != nil {
+		return err
+	}
+
+	if h.Username != "" || h.Password != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+
+	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	for k, v := range h.Headers {
+		if strings.ToLower(k) == "host" {
+			req.Host = v
+		}
+		req.Header.Set(k, v)
+	}
+
+	return h.doConfigRequest(req, "polling")
+}
+
+// configCheckDue reports whether a write should carry the revisions of the
+// local config, which happens at most once every config_check_interval.
+func (h *HTTP) configCheckDue() bool {
+	if h.ConfigCheckInterval.Duration <= 0 {
+		return true
+	}
+
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+
+	now := time.Now()
+	if now.Before(h.nextConfigCheck) {
+		return false
+	}
+	h.nextConfigCheck = now.Add(h.ConfigCheckInterval.Duration + internal.RandomDuration(h.ConfigJitter.Duration))
+	return true
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// proxyFunc returns the proxy selection of the HTTP transport.  The proxy
+// settings of the environment are used unless http_proxy_url or no_proxy
+// override them.
+func (h *HTTP) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if h.HTTPProxyURL == "" && len(h.NoProxy) == 0 {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	if h.HTTPProxyURL != "" {
+		_, err := url.Parse(h.HTTPProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing http_proxy_url [%s]: %v", h.HTTPProxyURL, err)
+		}
+		cfg.HTTPProxy = h.HTTPProxyURL
+		cfg.HTTPSProxy = h.HTTPProxyURL
+	}
+	if len(h.NoProxy) > 0 {
+		cfg.NoProxy = strings.Join(h.NoProxy, ",")
+	}
+
+	proxy := cfg.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
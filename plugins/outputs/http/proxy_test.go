@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyFunc(t *testing.T) {
+	plugin := &HTTP{
+		HTTPProxyURL: "http://proxy.example.com:3128",
+		NoProxy:      []string{".internal.example.com"},
+	}
+	proxy, err := plugin.proxyFunc()
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "https://bridge.example.com/telegraf", nil)
+	require.NoError(t, err)
+	u, err := proxy(req)
+	require.NoError(t, err)
+	require.NotNil(t, u)
+	require.Equal(t, "proxy.example.com:3128", u.Host)
+
+	req, err = http.NewRequest(http.MethodPost, "https://bridge.internal.example.com/telegraf", nil)
+	require.NoError(t, err)
+	u, err = proxy(req)
+	require.NoError(t, err)
+	require.Nil(t, u)
+}
+
+func TestProxyFuncInvalid(t *testing.T) {
+	plugin := &HTTP{
+		HTTPProxyURL: "http://proxy.example.com:port",
+	}
+	_, err := plugin.proxyFunc()
+	require.Error(t, err)
+}
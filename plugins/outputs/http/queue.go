@@ -0,0 +1,151 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Values of queue_overflow.
+const (
+	queueBlock      = "block"
+	queueDropOldest = "drop-oldest"
+)
+
+// startQueue starts the senders delivering the batches queued by Write in
+// async mode, one for each worker.
+func (h *HTTP) startQueue() {
+	h.queue = make(chan batch, h.QueueSize)
+	h.queueCtx, h.cancelQueue = context.WithCancel(context.Background())
+
+	senders := h.Workers
+	if senders < 1 {
+		senders = 1
+	}
+	for i := 0; i < senders; i++ {
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			for {
+				select {
+				case <-h.queueCtx.Done():
+					return
+				case b := <-h.queue:
+					h.stats.setQueueDepth(len(h.queue))
+					h.sendQueued(b)
+				}
+			}
+		}()
+	}
+}
+
+func (h *HTTP) stopQueue() {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	if h.cancelQueue != nil {
+		h.cancelQueue()
+		h.cancelQueue = nil
+	}
+}
+
+// startFlush sends the queued batches in the background, unless Close was
+// called.  Close waits for it to finish.
+func (h *HTTP) startFlush() error {
+	h.queueMu.Lock()
+	defer h.queueMu.Unlock()
+	if h.cancelQueue == nil {
+		return fmt.Errorf("output closed")
+	}
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.flushQueue()
+	}()
+	return nil
+}
+
+// enqueue adds batches to the queue, applying queue_overflow when it is
+// full.
+func (h *HTTP) enqueue(batches []batch) error {
+	for _, b := range batches {
+		if h.QueueOverflow == queueDropOldest {
+			h.enqueueDropOldest(b)
+			continue
+		}
+
+		select {
+		case h.queue <- b:
+		case <-h.queueCtx.Done():
+			return fmt.Errorf("output closed")
+		}
+	}
+	h.stats.setQueueDepth(len(h.queue))
+	return nil
+}
+
+func (h *HTTP) enqueueDropOldest(b batch) {
+	for {
+		select {
+		case h.queue <- b:
+			return
+		default:
+		}
+
+		select {
+		case old := <-h.queue:
+			log.Printf("W! [outputs.http] Queue full, dropping %d metrics", old.count)
+		default:
+		}
+	}
+}
+
+// sendQueued writes a batch, trying again with increasing delays until it is
+// sent, dropped or Close is called.
+func (h *HTTP) sendQueued(b batch) {
+	backoff := h.RetryInitialBackoff.Duration
+	for {
+		err := h.writeBatch(b)
+		if err == nil {
+			return
+		}
+		log.Printf("E! [outputs.http] Sending %d queued metrics: %s, retrying in %s", b.count, err, backoff)
+
+		select {
+		case <-h.queueCtx.Done():
+			// the batch is sent once more by flushQueue
+			select {
+			case h.queue <- b:
+			default:
+				log.Printf("E! [outputs.http] Dropping %d queued metrics on close: %s", b.count, err)
+			}
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > h.RetryMaxBackoff.Duration {
+			backoff = h.RetryMaxBackoff.Duration
+		}
+	}
+}
+
+// flushQueue makes a last attempt at sending the queued batches once the
+// senders have stopped.
+func (h *HTTP) flushQueue() {
+	if h.queue == nil {
+		return
+	}
+	for {
+		select {
+		case b := <-h.queue:
+			err := h.writeBatch(b)
+			if err != nil {
+				log.Printf("E! [outputs.http] Dropping %d queued metrics on close: %s", b.count, err)
+			}
+		default:
+			return
+		}
+	}
+}
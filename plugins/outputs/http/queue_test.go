@@ -0,0 +1,111 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncWrite(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:   ts.URL,
+		Async: true,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	// the write returns while the bridge has not answered
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	close(release)
+
+	require.NoError(t, plugin.Close())
+	require.Len(t, bodies, 2)
+	for _, body := range bodies {
+		require.Contains(t, body, "cpu value=42")
+	}
+}
+
+func TestAsyncWriteRetry(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		close(done)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:                 ts.URL,
+		Async:               true,
+		RetryInitialBackoff: internal.Duration{Duration: time.Millisecond},
+		RetryMaxBackoff:     internal.Duration{Duration: time.Millisecond},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued batch was not sent")
+	}
+	require.NoError(t, plugin.Close())
+}
+
+func TestQueueDropOldest(t *testing.T) {
+	plugin := &HTTP{
+		QueueSize:     2,
+		QueueOverflow: queueDropOldest,
+	}
+	plugin.queue = make(chan batch, plugin.QueueSize)
+
+	for _, body := range []string{"a", "b", "c"} {
+		require.NoError(t, plugin.enqueue([]batch{{body: []byte(body), count: 1}}))
+	}
+
+	var queued []string
+	for len(plugin.queue) > 0 {
+		queued = append(queued, string((<-plugin.queue).body))
+	}
+	require.Equal(t, "b,c", strings.Join(queued, ","))
+}
+
+func TestQueueOverflowInvalid(t *testing.T) {
+	plugin := &HTTP{
+		URL:           defaultURL,
+		QueueOverflow: "drop-newest",
+	}
+	require.Error(t, plugin.Connect())
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// isRetryableError reports whether a failed write may succeed when sent
+// again.
+func (h *HTTP) isRetryableError(err error) bool {
+	if h.isDropError(err) {
+		return false
+	}
+	if e, ok := err.(*statusError); ok && e.statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return isFailoverError(err)
+}
+
+// isDropError reports whether the batch of a failed write must be dropped,
+// according to drop_status_codes.
+func (h *HTTP) isDropError(err error) bool {
+	e, ok := err.(*statusError)
+	if !ok {
+		return false
+	}
+	for _, code := range h.DropStatusCodes {
+		if code == e.statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRetryAfter returns an error while writes must not be sent, when the
+// last attempt carried a Retry-After that has not passed yet.
+func (h *HTTP) checkRetryAfter() error {
+	h.retryMu.Lock()
+	resume := h.retryAfter
+	h.retryMu.Unlock()
+	if wait := time.Until(resume); wait > 0 {
+		return fmt.Errorf("bridge asked to retry after %s, %s left", resume.Format(time.RFC3339), wait)
+	}
+	return nil
+}
+
+// writeWithRetry writes a batch, trying again after failures that may be
+// transient up to retry_max_attempts times in total.  The delay between
+// attempts starts at retry_initial_backoff and doubles up to
+// retry_max_backoff, plus a random retry_jitter, and is at least what the
+// bridge asked for with Retry-After.  When the last attempt carries a
+// Retry-After, writes fail without being sent until it has passed, see
+// checkRetryAfter.  Close ends the wait, returning the last error.
+func (h *HTTP) writeWithRetry(b batch) error {
+	backoff := h.RetryInitialBackoff.Duration
+	for attempt := 1; ; attempt++ {
+		err := h.write(b)
+
+		var retryAfter time.Duration
+		if e, ok := err.(*statusError); ok {
+			retryAfter = e.retryAfter
+		}
+		if err == nil || attempt >= h.RetryMaxAttempts || !h.isRetryableError(err) {
+			if retryAfter > 0 {
+				h.retryMu.Lock()
+				h.retryAfter = time.Now().Add(retryAfter)
+				h.retryMu.Unlock()
+			}
+			return err
+		}
+
+		delay := backoff + internal.RandomDuration(h.RetryJitter.Duration)
+		if delay < retryAfter {
+			delay = retryAfter
+		}
+		log.Printf("D! [outputs.http] Write attempt %d failed: %s, retrying in %s", attempt, err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-h.done:
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		h.stats.retry()
+
+		backoff *= 2
+		if backoff > h.RetryMaxBackoff.Duration {
+			backoff = h.RetryMaxBackoff.Duration
+		}
+	}
+}
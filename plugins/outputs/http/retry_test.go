@@ -0,0 +1,197 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		hits     int
+		err      bool
+	}{
+		{
+			name:     "retried until success",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent},
+			attempts: 3,
+			hits:     3,
+		},
+		{
+			name:     "attempts exhausted",
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusNoContent},
+			attempts: 2,
+			hits:     2,
+			err:      true,
+		},
+		{
+			name:     "client errors are not retried",
+			statuses: []int{http.StatusBadRequest, http.StatusNoContent},
+			attempts: 3,
+			hits:     1,
+			err:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[hits])
+				hits++
+			}))
+			defer ts.Close()
+
+			plugin := &HTTP{
+				URL:                 ts.URL,
+				RetryMaxAttempts:    tt.attempts,
+				RetryInitialBackoff: internal.Duration{Duration: time.Millisecond},
+				RetryMaxBackoff:     internal.Duration{Duration: 2 * time.Millisecond},
+			}
+			plugin.SetSerializer(influx.NewSerializer())
+			require.NoError(t, plugin.Connect())
+
+			err := plugin.Write([]telegraf.Metric{getMetric()})
+			if tt.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.hits, hits)
+		})
+	}
+}
+
+func TestWriteRetryInterruptedByClose(t *testing.T) {
+	hits := make(chan struct{}, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits <- struct{}{}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:                 ts.URL,
+		RetryMaxAttempts:    2,
+		RetryInitialBackoff: internal.Duration{Duration: time.Hour},
+		RetryMaxBackoff:     internal.Duration{Duration: time.Hour},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- plugin.Write([]telegraf.Metric{getMetric()})
+	}()
+	<-hits
+
+	// the write stops waiting for its next attempt
+	require.NoError(t, plugin.Close())
+	select {
+	case err := <-errs:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("write not interrupted by Close")
+	}
+	require.Len(t, hits, 0)
+	require.NoError(t, plugin.Close())
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	require.Equal(t, time.Duration(0), parseRetryAfter(""))
+	require.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+
+	d := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	require.True(t, d > 59*time.Minute && d <= time.Hour, d)
+}
+
+func TestDropStatusCodes(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:              ts.URL,
+		RetryMaxAttempts: 3,
+		DropStatusCodes:  []int{http.StatusBadRequest, http.StatusRequestEntityTooLarge},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, 1, hits)
+}
+
+func TestRetryAfter(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL: ts.URL,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.Error(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Error(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, 1, hits)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:                 ts.URL,
+		RetryMaxAttempts:    2,
+		RetryInitialBackoff: internal.Duration{Duration: time.Millisecond},
+		RetryMaxBackoff:     internal.Duration{Duration: time.Millisecond},
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write(getMetrics(t, 1)))
+
+	require.Len(t, keys, 4)
+	require.NotEmpty(t, keys[0])
+	require.Equal(t, keys[0], keys[1])
+	// the same metrics written again, such as when the agent retries a
+	// write, carry the same key
+	require.Equal(t, keys[1], keys[2])
+	require.NotEqual(t, keys[2], keys[3])
+}
+
+func TestIdempotencyKeyURL(t *testing.T) {
+	b := batch{body: []byte("cpu value=42 0\n")}
+	other := b
+	other.url = "http://127.0.0.1:8080/other"
+	require.NotEqual(t, batchID(b), batchID(other))
+}
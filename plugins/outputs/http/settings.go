@@ -0,0 +1,231 @@
+package http
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/toml"
+	"github.com/influxdata/toml/ast"
+)
+
+// agentSettings are settings of the agent itself that the bridge can change.
+// Empty settings are left as they are.
+type agentSettings struct {
+	Interval      string `json:"interval,omitempty"`
+	FlushInterval string `json:"flush_interval,omitempty"`
+	// GlobalTags replace all global tags when set, an empty map removes
+	// them.
+	GlobalTags map[string]string `json:"global_tags"`
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func (s *agentSettings) check() error {
+	for key, value := range map[string]string{
+		"interval":       s.Interval,
+		"flush_interval": s.FlushInterval,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid %s: %s", key, err)
+		}
+	}
+	for key := range s.GlobalTags {
+		if key == "" {
+			return fmt.Errorf("empty global tag key")
+		}
+	}
+	return nil
+}
+
+// tomlKey quotes key unless it is a bare TOML key.
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return fmt.Sprintf("%q", key)
+}
+
+// topTable parses lines and returns the top-level table name, or nil when it
+// is not defined.
+func topTable(lines []string, name string) (*ast.Table, error) {
+	tbl, err := toml.Parse([]byte(strings.Join(lines, "")))
+	if err != nil {
+		return nil, err
+	}
+	t, ok := tbl.Fields[name].(*ast.Table)
+	if !ok || t.Line == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// firstTableLine returns the index of the first table header of a config.
+func firstTableLine(lines []string) int {
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+func insertLines(lines []string, at int, added ...string) []string {
+	result := make([]string, 0, len(lines)+len(added))
+	result = append(result, lines[:at]...)
+	result = append(result, added...)
+	return append(result, lines[at:]...)
+}
+
+// setAgentKey sets key of the [agent] table to a string value, adding the
+// key or the table when missing.
+func setAgentKey(lines []string, key string, value string) ([]string, error) {
+	agent, err := topTable(lines, "agent")
+	if err != nil {
+		return nil, err
+	}
+
+	if agent == nil {
+		at := firstTableLine(lines)
+		return insertLines(lines, at, "[agent]\n", fmt.Sprintf("  %s = %q\n", key, value), "\n"), nil
+	}
+
+	if kv, ok := agent.Fields[key].(*ast.KeyValue); ok {
+		line := lines[kv.Line-1]
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		lines[kv.Line-1] = fmt.Sprintf("%s%s = %q\n", indent, key, value)
+		return lines, nil
+	}
+	return insertLines(lines, agent.Line, fmt.Sprintf("  %s = %q\n", key, value)), nil
+}
+
+// setGlobalTags replaces the [global_tags] table with tags.
+func setGlobalTags(lines []string, tags map[string]string) ([]string, error) {
+	globalTags, err := topTable(lines, "global_tags")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	table := []string{"[global_tags]\n"}
+	for _, key := range keys {
+		table = append(table, fmt.Sprintf("  %s = %q\n", tomlKey(key), tags[key]))
+	}
+
+	if globalTags == nil {
+		at := firstTableLine(lines)
+		return insertLines(lines, at, append(table, "\n")...), nil
+	}
+
+	begin := globalTags.Line - 1
+	end := lastLine(globalTags)
+	result := append([]string{}, lines[:begin]...)
+	result = append(result, table...)
+	return append(result, lines[end:]...), nil
+}
+
+// applyAgentSettings returns the lines of a config with settings applied.
+func applyAgentSettings(lines []string, settings *agentSettings) ([]string, error) {
+	lines = append([]string{}, lines...)
+
+	var err error
+	if settings.Interval != "" {
+		lines, err = setAgentKey(lines, "interval", settings.Interval)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if settings.FlushInterval != "" {
+		lines, err = setAgentKey(lines, "flush_interval", settings.FlushInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if settings.GlobalTags != nil {
+		lines, err = setGlobalTags(lines, settings.GlobalTags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// agentConfig returns the lines of telegraf.conf with settings applied, or
+// nil when settings is nil or telegraf.conf already has them.  It must be
+// called from the config directory with the config lock held.
+func agentConfig(settings *agentSettings) ([]string, error) {
+	if settings == nil {
+		return nil, nil
+	}
+
+	lines, err := readConfigLines("telegraf.conf")
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := applyAgentSettings(lines, settings)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Join(updated, "") == strings.Join(lines, "") {
+		return nil, nil
+	}
+	return updated, nil
+}
+
+// updateAgentSettings applies settings to the [agent] and [global_tags]
+// tables of telegraf.conf.  The new config is validated together with the
+// fragment files of dir, when given.  It returns false when telegraf.conf
+// already has the settings.  Settings sent along with plugin config are
+// written with it instead, see updatePluginConfig and updateFragments.
+func updateAgentSettings(settings *agentSettings, configFilePath string, dir string, timeout time.Duration) (bool, error) {
+	err := settings.check()
+	if err != nil {
+		return false, err
+	}
+
+	err = os.Chdir(configFilePath)
+	if err != nil {
+		return false, err
+	}
+
+	lock, err := lockFile(configLockFile)
+	if err != nil {
+		return false, err
+	}
+	defer unlockFile(lock)
+
+	updated, err := agentConfig(settings)
+	if err != nil || updated == nil {
+		return false, err
+	}
+
+	var fragments []string
+	if dir != "" {
+		current, err := readFragments(dir)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range fragmentIDs(current) {
+			fragments = append(fragments, filepath.Join(dir, id+fragmentExt))
+		}
+	}
+
+	err = replaceConfig(updated, fragments, timeout)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyAgentSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		settings agentSettings
+		expected string
+	}{
+		{
+			name: "replace existing",
+			config: "[global_tags]\n" +
+				"  dc = \"a\"\n" +
+				"  # rack = \"1\"\n" +
+				"\n" +
+				"[agent]\n" +
+				"    interval = \"10s\"\n" +
+				"  flush_interval = \"10s\"\n" +
+				"\n" +
+				"[[inputs.cpu]]\n",
+			settings: agentSettings{
+				Interval:   "30s",
+				GlobalTags: map[string]string{"region": "eu", "data center": "b"},
+			},
+			expected: "[global_tags]\n" +
+				"  \"data center\" = \"b\"\n" +
+				"  region = \"eu\"\n" +
+				"  # rack = \"1\"\n" +
+				"\n" +
+				"[agent]\n" +
+				"    interval = \"30s\"\n" +
+				"  flush_interval = \"10s\"\n" +
+				"\n" +
+				"[[inputs.cpu]]\n",
+		},
+		{
+			name:   "add missing",
+			config: "# Telegraf\n\n[[inputs.cpu]]\n",
+			settings: agentSettings{
+				FlushInterval: "1m",
+				GlobalTags:    map[string]string{},
+			},
+			expected: "# Telegraf\n\n" +
+				"[global_tags]\n" +
+				"\n" +
+				"[agent]\n" +
+				"  flush_interval = \"1m\"\n" +
+				"\n" +
+				"[[inputs.cpu]]\n",
+		},
+		{
+			name:     "add key to agent",
+			config:   "[agent]\n  interval = \"10s\"\n",
+			settings: agentSettings{FlushInterval: "5s"},
+			expected: "[agent]\n  flush_interval = \"5s\"\n  interval = \"10s\"\n",
+		},
+		{
+			name:     "nothing to change",
+			config:   "[global_tags]\n  dc = \"a\"\n",
+			settings: agentSettings{},
+			expected: "[global_tags]\n  dc = \"a\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := applyAgentSettings(splitLines(tt.config), &tt.settings)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, strings.Join(lines, ""))
+		})
+	}
+}
+
+func TestUpdateAgentSettings(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, testConfig)
+	defer cleanup()
+
+	settings := &agentSettings{Interval: "1m", GlobalTags: map[string]string{"dc": "a"}}
+	changed, err := updateAgentSettings(settings, dir, "", time.Second)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	actual := readTestConfig(t, dir)
+	require.True(t, strings.HasPrefix(actual, "[global_tags]\n  dc = \"a\"\n\n[agent]\n  interval = \"1m\"\n"), actual)
+
+	changed, err = updateAgentSettings(settings, dir, "", time.Second)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	_, err = updateAgentSettings(&agentSettings{Interval: "often"}, dir, "", time.Second)
+	require.Error(t, err)
+}
+
+func TestUpdatePluginConfigWithAgentSettings(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, testConfig)
+	defer cleanup()
+
+	settings := &agentSettings{Interval: "1m"}
+
+	// agent settings are not written when the plugin config is rejected
+	_, err := updatePluginConfig(map[string]string{"outputs": "[[outputs.doesnotexist]]\n"},
+		map[string]string{}, settings, dir, time.Second)
+	require.Error(t, err)
+	require.Equal(t, testConfig, readTestConfig(t, dir))
+
+	changed, err := updatePluginConfig(map[string]string{"inputs": "[[inputs.mock]]\n"},
+		map[string]string{"inputs": "abc"}, settings, dir, time.Second)
+	require.NoError(t, err)
+	require.True(t, changed)
+	actual := readTestConfig(t, dir)
+	require.Contains(t, actual, "interval = \"1m\"")
+	require.Contains(t, actual, "[[inputs.mock]]")
+
+	// unchanged sections and settings leave the file alone
+	changed, err = updatePluginConfig(map[string]string{}, map[string]string{}, settings, dir, time.Second)
+	require.NoError(t, err)
+	require.False(t, changed)
+}
+
+func TestUpdateFragmentsWithAgentSettings(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "telegraf.d"), 0755))
+
+	settings := &agentSettings{Interval: "1m"}
+
+	// agent settings are not written when the fragments are rejected
+	fragments, err := splitFragments("# plugin_id: broken\n[[inputs.doesnotexist]]\n")
+	require.NoError(t, err)
+	_, err = updateFragments(fragments, settings, dir, "telegraf.d", time.Second)
+	require.Error(t, err)
+	require.Equal(t, "[[outputs.http]]\n", readTestConfig(t, dir))
+	_, err = os.Stat(filepath.Join(dir, "telegraf.conf.new"))
+	require.True(t, os.IsNotExist(err))
+
+	fragments, err = splitFragments("# plugin_id: mock\n[[inputs.mock]]\n")
+	require.NoError(t, err)
+	changed, err := updateFragments(fragments, settings, dir, "telegraf.d", time.Second)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "[agent]\n  interval = \"1m\"\n\n[[outputs.http]]\n", readTestConfig(t, dir))
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "telegraf.d", "mock.conf"))
+	require.NoError(t, err)
+	require.Equal(t, "# plugin_id: mock\n[[inputs.mock]]\n", string(buf))
+
+	// settings alone change the config
+	changed, err = updateFragments(fragments, &agentSettings{Interval: "2m"}, dir, "telegraf.d", time.Second)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Contains(t, readTestConfig(t, dir), "interval = \"2m\"")
+
+	changed, err = updateFragments(fragments, &agentSettings{Interval: "2m"}, dir, "telegraf.d", time.Second)
+	require.NoError(t, err)
+	require.False(t, changed)
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// configSignatureHeader holds the base64 signature of a config body sent by
+// the bridge.
+const configSignatureHeader = "X-Config-Signature"
+
+func parsePublicKey(key string) (ed25519.PublicKey, error) {
+	buf, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config_public_key: %s", err)
+	}
+	if len(buf) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid config_public_key: expected %d bytes, got %d",
+			ed25519.PublicKeySize, len(buf))
+	}
+	return ed25519.PublicKey(buf), nil
+}
+
+// verifyConfigSignature checks the signature of a config body received from
+// the bridge against the configured keys.  Without any keys configured every
+// body is accepted.
+func (h *HTTP) verifyConfigSignature(body []byte, signature string) error {
+	if h.ConfigHMACKey == "" && h.configPublicKey == nil {
+		return nil
+	}
+
+	if signature == "" {
+		return fmt.Errorf("missing %s header", configSignatureHeader)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %s", configSignatureHeader, err)
+	}
+
+	if h.ConfigHMACKey != "" {
+		mac := hmac.New(sha256.New, []byte(h.ConfigHMACKey))
+		mac.Write(body)
+		if hmac.Equal(sig, mac.Sum(nil)) {
+			return nil
+		}
+	}
+
+	if h.configPublicKey != nil && ed25519.Verify(h.configPublicKey, body, sig) {
+		return nil
+	}
+
+	return errors.New("config signature does not match")
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestVerifyConfigSignature(t *testing.T) {
+	body := []byte("[[inputs.cpu]]\n")
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	hmacSig := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edSig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, body))
+	pubKey := base64.StdEncoding.EncodeToString(pub)
+
+	tests := []struct {
+		name      string
+		plugin    *HTTP
+		signature string
+		expectErr bool
+	}{
+		{
+			name:      "no keys accepts anything",
+			plugin:    &HTTP{},
+			signature: "",
+		},
+		{
+			name:      "valid hmac",
+			plugin:    &HTTP{ConfigHMACKey: "secret"},
+			signature: hmacSig,
+		},
+		{
+			name:      "hmac with wrong key",
+			plugin:    &HTTP{ConfigHMACKey: "other"},
+			signature: hmacSig,
+			expectErr: true,
+		},
+		{
+			name:      "missing signature",
+			plugin:    &HTTP{ConfigHMACKey: "secret"},
+			signature: "",
+			expectErr: true,
+		},
+		{
+			name:      "signature not base64",
+			plugin:    &HTTP{ConfigHMACKey: "secret"},
+			signature: "%%%",
+			expectErr: true,
+		},
+		{
+			name:      "valid ed25519",
+			plugin:    &HTTP{ConfigPublicKey: pubKey},
+			signature: edSig,
+		},
+		{
+			name:      "ed25519 signature of other body",
+			plugin:    &HTTP{ConfigPublicKey: pubKey},
+			signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("x"))),
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.plugin.ConfigPublicKey != "" {
+				key, err := parsePublicKey(tt.plugin.ConfigPublicKey)
+				require.NoError(t, err)
+				tt.plugin.configPublicKey = key
+			}
+
+			err := tt.plugin.verifyConfigSignature(body, tt.signature)
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestInvalidPublicKey(t *testing.T) {
+	plugin := &HTTP{
+		URL:             "http://127.0.0.1:8080",
+		ConfigPublicKey: base64.StdEncoding.EncodeToString([]byte("short")),
+	}
+	require.Error(t, plugin.Connect())
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	internalaws "github.com/influxdata/telegraf/internal/config/aws"
+)
+
+// sigv4Transport signs requests with AWS Signature Version 4 before sending
+// them with next.
+type sigv4Transport struct {
+	next    http.RoundTripper
+	signer  *v4.Signer
+	service string
+	region  string
+}
+
+func (h *HTTP) newSigV4Transport(next http.RoundTripper) *sigv4Transport {
+	credentialConfig := &internalaws.CredentialConfig{
+		Region:    h.Region,
+		AccessKey: h.AccessKey,
+		SecretKey: h.SecretKey,
+		RoleARN:   h.RoleARN,
+		Profile:   h.Profile,
+		Filename:  h.Filename,
+		Token:     h.Token,
+	}
+	configProvider := credentialConfig.Credentials()
+	creds := configProvider.ClientConfig(h.AWSService).Config.Credentials
+
+	return &sigv4Transport{
+		next:    next,
+		signer:  v4.NewSigner(creds),
+		service: h.AWSService,
+		region:  h.Region,
+	}
+}
+
+// RoundTrip signs a copy of req, as a RoundTripper must not modify the
+// request it is given.
+func (t *sigv4Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body io.ReadSeeker
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	signed := new(http.Request)
+	*signed = *req
+	signed.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		signed.Header[k] = append([]string(nil), v...)
+	}
+
+	// the body of signed is set to the body that was read
+	_, err := t.signer.Sign(signed, body, t.service, t.region, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(signed)
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigV4(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		require.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"), auth)
+		require.Contains(t, auth, "/us-east-1/execute-api/aws4_request")
+		require.NotEmpty(t, r.Header.Get("X-Amz-Date"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), "cpu value=42")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:        ts.URL,
+		AWSService: "execute-api",
+		Region:     "us-east-1",
+		AccessKey:  "AKID",
+		SecretKey:  "SECRET",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+}
+
+func TestSigV4WithOAuth2(t *testing.T) {
+	plugin := &HTTP{
+		URL:          defaultURL,
+		AWSService:   "execute-api",
+		ClientID:     "howdy",
+		ClientSecret: "secret",
+		TokenURL:     defaultURL,
+	}
+	require.Error(t, plugin.Connect())
+}
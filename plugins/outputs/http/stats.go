@@ -0,0 +1,97 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// httpStats are the internal metrics of the output, reported in the
+// internal_http_output measurement.  A nil httpStats records nothing.
+type httpStats struct {
+	tags          map[string]string
+	requestTime   selfstat.Stat
+	bytesSent     selfstat.Stat
+	retries       selfstat.Stat
+	queueDepth    selfstat.Stat
+	configUpdated selfstat.Stat
+}
+
+func newHTTPStats(tags map[string]string) *httpStats {
+	return &httpStats{
+		tags:          tags,
+		requestTime:   selfstat.RegisterTiming("http_output", "request_time_ns", tags),
+		bytesSent:     selfstat.Register("http_output", "bytes_sent", tags),
+		retries:       selfstat.Register("http_output", "retries", tags),
+		queueDepth:    selfstat.Register("http_output", "queue_depth", tags),
+		configUpdated: selfstat.Register("http_output", "config_updated", tags),
+	}
+}
+
+// statTags returns the tags of the internal metrics of the output.
+func (h *HTTP) statTags() map[string]string {
+	url := h.URL
+	if len(h.URLs) > 0 {
+		url = strings.Join(h.URLs, ",")
+	}
+	return map[string]string{"url": url}
+}
+
+// request records a request to the bridge, counting its response by status
+// code in the responses field.
+func (s *httpStats) request(req *http.Request, resp *http.Response, elapsed time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.requestTime.Incr(elapsed.Nanoseconds())
+	if req.ContentLength > 0 {
+		s.bytesSent.Incr(req.ContentLength)
+	}
+	if resp == nil {
+		return
+	}
+
+	tags := make(map[string]string, len(s.tags)+1)
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	tags["status_code"] = strconv.Itoa(resp.StatusCode)
+	selfstat.Register("http_output", "responses", tags).Incr(1)
+}
+
+func (s *httpStats) retry() {
+	if s != nil {
+		s.retries.Incr(1)
+	}
+}
+
+func (s *httpStats) setQueueDepth(depth int) {
+	if s != nil {
+		s.queueDepth.Set(int64(depth))
+	}
+}
+
+// configApplied records the time plugin config from the bridge was applied,
+// as the revisions themselves are not numbers.
+func (s *httpStats) configApplied() {
+	if s != nil {
+		s.configUpdated.Set(time.Now().Unix())
+	}
+}
+
+// statsTransport records the requests sent with next.
+type statsTransport struct {
+	next  http.RoundTripper
+	stats *httpStats
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	t.stats.request(req, resp, time.Since(start))
+	return resp, err
+}
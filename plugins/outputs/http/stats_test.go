@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL: ts.URL,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+
+	fields := map[string]interface{}{}
+	for _, m := range selfstat.Metrics() {
+		if m.Name() != "internal_http_output" {
+			continue
+		}
+		if tag, _ := m.GetTag("url"); tag != ts.URL {
+			continue
+		}
+		if code, ok := m.GetTag("status_code"); ok {
+			require.Equal(t, "204", code)
+		}
+		for k, v := range m.Fields() {
+			fields[k] = v
+		}
+	}
+	require.Equal(t, int64(2), fields["responses"])
+	require.NotZero(t, fields["bytes_sent"])
+	require.Contains(t, fields, "request_time_ns")
+	require.Equal(t, int64(0), fields["retries"])
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/validate"
+)
+
+// configStatus is the result of applying plugin config received from the
+// bridge, posted to config_status_url.
+type configStatus struct {
+	Source       string            `json:"source"`
+	Accepted     bool              `json:"accepted"`
+	Revisions    map[string]string `json:"revisions,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	FailedInputs []string          `json:"failed_inputs,omitempty"`
+	Time         string            `json:"time"`
+}
+
+// reportConfigStatus posts the outcome of a config update to the bridge.
+// Failures are only logged, they must not affect the metric write.
+func (h *HTTP) reportConfigStatus(sections map[string]string, applyErr error) {
+	if h.ConfigStatusURL == "" {
+		return
+	}
+
+	status := configStatus{
+		Source:    h.SourceAddress,
+		Accepted:  applyErr == nil,
+		Revisions: make(map[string]string, len(sections)),
+		Time:      time.Now().Format(time.RFC3339),
+	}
+	for section, content := range sections {
+		status.Revisions[section] = textRevision(content)
+	}
+	if applyErr != nil {
+		status.Error = applyErr.Error()
+	}
+	if verr, ok := applyErr.(*validate.Error); ok {
+		status.FailedInputs = verr.Inputs
+	}
+
+	err := h.postConfigStatus(&status)
+	if err != nil {
+		log.Printf("E! [outputs.http] Reporting config status to [%s]: %s", h.ConfigStatusURL, err)
+	}
+}
+
+func (h *HTTP) postConfigStatus(status *configStatus) error {
+	return h.postJSON(h.ConfigStatusURL, status)
+}
+
+// postJSON posts v as JSON to the bridge at u.
+func (h *HTTP) postJSON(u string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return h.post(u, "application/json", bytes.NewBuffer(body))
+}
+
+// post sends body to the bridge at u with the credentials and headers of
+// the plugin.
+func (h *HTTP) post(u string, contentType string, body io.Reader) error {
+	req, err := http.NewRequest(http.MethodPost, u, body)
+	if err != nil {
+		return err
+	}
+
+	if h.Username != "" || h.Password != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+	for k, v := range h.Headers {
+		if strings.ToLower(k) == "host" {
+			req.Host = v
+		}
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("received status code: %d", resp.StatusCode)
+	}
+	return nil
+}
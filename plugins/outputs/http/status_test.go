@@ -0,0 +1,52 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigStatusRejected(t *testing.T) {
+	var status configStatus
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("[[inputs.exec]]\n"))
+		case "/status":
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:         true,
+		ConfigFilePath:       dir,
+		URL:                  fmt.Sprintf("%s/write", ts.URL),
+		SourceAddress:        "10.0.0.1",
+		AllowedRemotePlugins: []string{"inputs.cpu"},
+		ConfigStatusURL:      fmt.Sprintf("%s/status", ts.URL),
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	err := plugin.Write([]telegraf.Metric{getMetric()})
+	require.Error(t, err)
+
+	require.Equal(t, "10.0.0.1", status.Source)
+	require.False(t, status.Accepted)
+	require.Equal(t, "plugins not allowed: inputs.exec", status.Error)
+	require.Equal(t, map[string]string{"inputs": textRevision("[[inputs.exec]]\n")}, status.Revisions)
+	require.NotEmpty(t, status.Time)
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/influxdata/telegraf"
+)
+
+// templateMetric is the data a url template is executed with.
+type templateMetric struct {
+	metric telegraf.Metric
+}
+
+// Name returns the measurement name, escaped for use in a URL.
+func (m *templateMetric) Name() string {
+	return url.PathEscape(m.metric.Name())
+}
+
+// Tag returns the value of a tag, escaped for use in a URL, or an empty
+// string when the metric does not have it.
+func (m *templateMetric) Tag(key string) string {
+	value, _ := m.metric.GetTag(key)
+	return url.PathEscape(value)
+}
+
+// metricGroup holds the metrics sent to the same URL.
+type metricGroup struct {
+	url     string
+	metrics []telegraf.Metric
+}
+
+// isURLTemplate reports whether url holds template actions.
+func isURLTemplate(url string) bool {
+	return strings.Contains(url, "{{")
+}
+
+func parseURLTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("url").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url template: %s", err)
+	}
+	return tmpl, nil
+}
+
+// groupByURL resolves the url template for every metric and groups the
+// metrics by URL, in the order the URLs first appear.
+func (h *HTTP) groupByURL(metrics []telegraf.Metric) ([]metricGroup, error) {
+	var groups []metricGroup
+	index := map[string]int{}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		buf.Reset()
+		err := h.urlTemplate.Execute(&buf, &templateMetric{metric: m})
+		if err != nil {
+			return nil, fmt.Errorf("executing url template: %s", err)
+		}
+		url := buf.String()
+
+		i, ok := index[url]
+		if !ok {
+			i = len(groups)
+			index[url] = i
+			groups = append(groups, metricGroup{url: url})
+		}
+		groups[i].metrics = append(groups[i].metrics, m)
+	}
+	return groups, nil
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func getTaggedMetric(t *testing.T, tags map[string]string) telegraf.Metric {
+	m, err := metric.New("cpu", tags, map[string]interface{}{"value": 42.0}, time.Unix(0, 0))
+	require.NoError(t, err)
+	return m
+}
+
+func TestURLTemplate(t *testing.T) {
+	var mu sync.Mutex
+	lines := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		lines[r.URL.Path] += strings.Count(string(body), "\n")
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL: ts.URL + `/{{ .Tag "dc" }}/{{ .Name }}`,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	metrics := []telegraf.Metric{
+		getTaggedMetric(t, map[string]string{"dc": "east"}),
+		getTaggedMetric(t, map[string]string{"dc": "west"}),
+		getTaggedMetric(t, map[string]string{"dc": "east"}),
+		getTaggedMetric(t, map[string]string{"dc": "a/b"}),
+	}
+	require.NoError(t, plugin.Write(metrics))
+	require.Equal(t, map[string]int{"/east/cpu": 2, "/west/cpu": 1, "/a/b/cpu": 1}, lines)
+}
+
+func TestGroupByURL(t *testing.T) {
+	tmpl, err := parseURLTemplate(`http://{{ .Tag "dc" }}/write`)
+	require.NoError(t, err)
+	plugin := &HTTP{urlTemplate: tmpl}
+
+	groups, err := plugin.groupByURL([]telegraf.Metric{
+		getTaggedMetric(t, map[string]string{"dc": "west"}),
+		getTaggedMetric(t, map[string]string{}),
+		getTaggedMetric(t, map[string]string{"dc": "west"}),
+	})
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	require.Equal(t, "http://west/write", groups[0].url)
+	require.Len(t, groups[0].metrics, 2)
+	require.Equal(t, "http:///write", groups[1].url)
+}
+
+func TestURLTemplateInvalid(t *testing.T) {
+	plugin := &HTTP{
+		URL: `http://{{ .Tag "dc" }/write`,
+	}
+	require.Error(t, plugin.Connect())
+}
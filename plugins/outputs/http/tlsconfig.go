@@ -0,0 +1,110 @@
+package http
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	internaltls "github.com/influxdata/telegraf/internal/tls"
+)
+
+const pinPrefix = "sha256/"
+
+// tlsConfig returns the TLS config of the connections to the bridge, with
+// the client certificate reloaded when renewed, the cipher suites of
+// tls_cipher_suites and the server pinned to tls_pinned_keys.
+func (h *HTTP) tlsConfig() (*tls.Config, error) {
+	tlsCfg, err := h.ClientConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg == nil {
+		if len(h.TLSCipherSuites) == 0 && len(h.TLSPinnedKeys) == 0 &&
+			h.TLSMinVersion == "" && h.TLSMaxVersion == "" {
+			return nil, nil
+		}
+		tlsCfg = &tls.Config{Renegotiation: tls.RenegotiateNever}
+
+		// the versions are only set by ClientConfig along with the CA or
+		// the client certificate
+		if h.TLSMinVersion != "" {
+			tlsCfg.MinVersion, err = internaltls.ParseTLSVersion(h.TLSMinVersion)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse tls min version %q: %v", h.TLSMinVersion, err)
+			}
+		}
+		if h.TLSMaxVersion != "" {
+			tlsCfg.MaxVersion, err = internaltls.ParseTLSVersion(h.TLSMaxVersion)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse tls max version %q: %v", h.TLSMaxVersion, err)
+			}
+		}
+	}
+
+	if h.TLSCert != "" && h.TLSKey != "" {
+		if h.certReloader == nil {
+			h.certReloader, err = newCertReloader(h.TLSCert, h.TLSKey)
+			if err != nil {
+				return nil, err
+			}
+		}
+		tlsCfg.Certificates = nil
+		tlsCfg.GetClientCertificate = h.certReloader.getClientCertificate
+	}
+
+	if len(h.TLSCipherSuites) > 0 {
+		cipherSuites, err := internaltls.ParseCiphers(h.TLSCipherSuites)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse tls_cipher_suites %s: %v",
+				strings.Join(h.TLSCipherSuites, ","), err)
+		}
+		tlsCfg.CipherSuites = cipherSuites
+	}
+
+	if len(h.TLSPinnedKeys) > 0 {
+		pins, err := parsePins(h.TLSPinnedKeys)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPins(rawCerts, pins)
+		}
+	}
+	return tlsCfg, nil
+}
+
+// parsePins decodes pins of the form "sha256/<base64 digest>".
+func parsePins(keys []string) (map[string]bool, error) {
+	pins := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !strings.HasPrefix(key, pinPrefix) {
+			return nil, fmt.Errorf("invalid pin %q, expected %s<base64 digest>", key, pinPrefix)
+		}
+		digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(key, pinPrefix))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin %q, expected %s<base64 digest>", key, pinPrefix)
+		}
+		pins[string(digest)] = true
+	}
+	return pins, nil
+}
+
+// verifyPins checks that the public key of one of the certificates presented
+// by the server is pinned.  It runs after the usual verification of the
+// chain.
+func verifyPins(rawCerts [][]byte, pins map[string]bool) error {
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if pins[string(digest[:])] {
+			return nil
+		}
+	}
+	return fmt.Errorf("no certificate of the server matches tls_pinned_keys")
+}
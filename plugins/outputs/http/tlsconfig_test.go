@@ -0,0 +1,93 @@
+package http
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinnedKeys(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	digest := sha256.Sum256(ts.Certificate().RawSubjectPublicKeyInfo)
+	pin := pinPrefix + base64.StdEncoding.EncodeToString(digest[:])
+	otherPin := pinPrefix + base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	tests := []struct {
+		name string
+		pins []string
+		ok   bool
+	}{
+		{
+			name: "pinned key",
+			pins: []string{otherPin, pin},
+			ok:   true,
+		},
+		{
+			name: "other key",
+			pins: []string{otherPin},
+			ok:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &HTTP{
+				URL:           ts.URL,
+				TLSPinnedKeys: tt.pins,
+			}
+			plugin.InsecureSkipVerify = true
+			plugin.SetSerializer(influx.NewSerializer())
+			require.NoError(t, plugin.Connect())
+
+			err := plugin.Write([]telegraf.Metric{getMetric()})
+			if tt.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	_, err := parsePins([]string{"AAAA"})
+	require.Error(t, err)
+	_, err = parsePins([]string{pinPrefix + "AAAA"})
+	require.Error(t, err)
+}
+
+func TestCipherSuites(t *testing.T) {
+	plugin := &HTTP{
+		TLSCipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}
+	tlsCfg, err := plugin.tlsConfig()
+	require.NoError(t, err)
+	require.Len(t, tlsCfg.CipherSuites, 1)
+
+	plugin.TLSCipherSuites = []string{"TLS_NOT_A_CIPHER"}
+	_, err = plugin.tlsConfig()
+	require.Error(t, err)
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	plugin := &HTTP{}
+	plugin.TLSMinVersion = "TLS12"
+	tlsCfg, err := plugin.tlsConfig()
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+
+	plugin.TLSMinVersion = "TLS99"
+	_, err = plugin.tlsConfig()
+	require.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const unixSocketExt = ".sock"
+
+// resolveUnixURLs rewrites the unix:// URLs of the plugin into http:// URLs
+// with a placeholder host, and remembers the socket each host stands for.
+func (h *HTTP) resolveUnixURLs() error {
+	if h.unixSockets == nil {
+		h.unixSockets = map[string]string{}
+	}
+
+	var err error
+	h.URL, err = h.resolveUnixURL(h.URL)
+	if err != nil {
+		return err
+	}
+	h.ConfigURL, err = h.resolveUnixURL(h.ConfigURL)
+	if err != nil {
+		return err
+	}
+	for i, u := range h.URLs {
+		h.URLs[i], err = h.resolveUnixURL(u)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveUnixURL returns the http:// URL standing for a unix:// URL, such as
+// unix:///var/run/collector.sock/telegraf where the socket is the part of
+// the path up to ".sock" and the rest is the request path.  Other URLs are
+// returned unchanged.
+func (h *HTTP) resolveUnixURL(raw string) (string, error) {
+	if !strings.HasPrefix(raw, "unix://") {
+		return raw, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	i := strings.Index(u.Path, unixSocketExt)
+	if u.Host != "" || i < 0 {
+		return "", fmt.Errorf("invalid unix socket url [%s], expected unix:///path/to/socket%s/path", raw, unixSocketExt)
+	}
+	socket := u.Path[:i+len(unixSocketExt)]
+
+	host := ""
+	for name, s := range h.unixSockets {
+		if s == socket {
+			host = name
+		}
+	}
+	if host == "" {
+		host = fmt.Sprintf("unix%d", len(h.unixSockets))
+		h.unixSockets[host] = socket
+	}
+
+	u.Scheme = "http"
+	u.Host = host
+	u.Path = u.Path[i+len(unixSocketExt):]
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String(), nil
+}
+
+// unixSocket returns the socket the host of addr stands for.
+func (h *HTTP) unixSocket(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	socket, ok := h.unixSockets[host]
+	return socket, ok
+}
+
+// dialContext connects to the unix socket of the placeholder hosts, and to
+// other addresses with dialer.
+func (h *HTTP) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if socket, ok := h.unixSocket(addr); ok {
+			// local_address does not apply to unix sockets
+			unixDialer := *dialer
+			unixDialer.LocalAddr = nil
+			return unixDialer.DialContext(ctx, "unix", socket)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
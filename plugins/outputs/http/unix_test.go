@@ -0,0 +1,65 @@
+// +build !windows
+
+package http
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveUnixURL(t *testing.T) {
+	plugin := &HTTP{unixSockets: map[string]string{}}
+
+	u, err := plugin.resolveUnixURL("unix:///var/run/collector.sock/telegraf?db=x")
+	require.NoError(t, err)
+	require.Equal(t, "http://unix0/telegraf?db=x", u)
+
+	u, err = plugin.resolveUnixURL("unix:///var/run/collector.sock")
+	require.NoError(t, err)
+	require.Equal(t, "http://unix0/", u)
+	require.Equal(t, map[string]string{"unix0": "/var/run/collector.sock"}, plugin.unixSockets)
+
+	u, err = plugin.resolveUnixURL("http://127.0.0.1:8080/telegraf")
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:8080/telegraf", u)
+
+	_, err = plugin.resolveUnixURL("unix:///var/run/collector/telegraf")
+	require.Error(t, err)
+}
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "http-unix")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "collector.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	var path string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL: "unix://" + socket + "/telegraf",
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write([]telegraf.Metric{getMetric()}))
+	require.Equal(t, "/telegraf", path)
+}
@@ -0,0 +1,207 @@
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	minPushBackoff = time.Second
+	maxPushBackoff = time.Minute
+)
+
+// pushMessage is a message sent by the bridge over the push channel.
+type pushMessage struct {
+	// Type is "config" for new plugin config, other types are ignored.
+	Type      string `json:"type"`
+	Config    string `json:"config,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// startPushChannel keeps a WebSocket connection to config_websocket_url open
+// until Close is called, reconnecting with increasing delays when it fails.
+func (h *HTTP) startPushChannel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancelPush = cancel
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		backoff := minPushBackoff
+		for {
+			connected, err := h.runPushChannel(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if connected {
+				backoff = minPushBackoff
+			}
+			log.Printf("E! [outputs.http] Push channel to [%s]: %s, reconnecting in %s",
+				h.ConfigWebSocketURL, err, backoff)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > maxPushBackoff {
+				backoff = maxPushBackoff
+			}
+		}
+	}()
+}
+
+func (h *HTTP) stopPushChannel() {
+	if h.cancelPush != nil {
+		h.cancelPush()
+		h.cancelPush = nil
+	}
+}
+
+// runPushChannel connects to the bridge and handles pushed messages until
+// the connection fails or ctx is done.  It reports whether the connection
+// was established.
+func (h *HTTP) runPushChannel(ctx context.Context) (bool, error) {
+	ws, err := h.dialPushChannel()
+	if err != nil {
+		return false, err
+	}
+	log.Printf("I! [outputs.http] Push channel to [%s] connected", h.ConfigWebSocketURL)
+	if h.MaxResponseSize.Size > 0 {
+		ws.MaxPayloadBytes = int(h.MaxResponseSize.Size)
+	}
+
+	// unblock Receive when the plugin is closed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		ws.Close()
+	}()
+
+	for {
+		var msg pushMessage
+		err = websocket.JSON.Receive(ws, &msg)
+		if err != nil {
+			return true, err
+		}
+
+		if msg.Type != "config" {
+			log.Printf("W! [outputs.http] Ignoring push message of type %q", msg.Type)
+			continue
+		}
+
+		err = h.applyPushedConfig(&msg)
+		if err != nil {
+			log.Printf("E! [outputs.http] Push channel to [%s]: %s", h.ConfigWebSocketURL, err)
+		}
+	}
+}
+
+// checkPushChannel returns an error for settings the push channel cannot
+// honor.  The WebSocket is dialed directly instead of through the HTTP
+// client, so only basic auth, headers and the agent identity are sent with
+// it, and it neither goes through a proxy nor over a unix socket.
+func (h *HTTP) checkPushChannel() error {
+	u, err := url.Parse(h.ConfigWebSocketURL)
+	if err != nil {
+		return fmt.Errorf("invalid config_websocket_url [%s]: %s", h.ConfigWebSocketURL, err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return fmt.Errorf("invalid config_websocket_url [%s], expected a ws:// or wss:// url", h.ConfigWebSocketURL)
+	}
+
+	var unsupported []string
+	if h.HTTPProxyURL != "" {
+		unsupported = append(unsupported, "http_proxy_url")
+	}
+	if h.ClientID != "" {
+		unsupported = append(unsupported, "OAuth2")
+	}
+	if h.Kerberos {
+		unsupported = append(unsupported, "kerberos")
+	}
+	if h.AuthURL != "" {
+		unsupported = append(unsupported, "auth_url")
+	}
+	if h.AWSService != "" {
+		unsupported = append(unsupported, "aws_service")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("config_websocket_url cannot be combined with %s", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+func (h *HTTP) dialPushChannel() (*websocket.Conn, error) {
+	u, err := url.Parse(h.ConfigWebSocketURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("source", h.SourceAddress)
+	u.RawQuery = q.Encode()
+
+	origin := "http://" + u.Host
+	if u.Scheme == "wss" {
+		origin = "https://" + u.Host
+	}
+
+	config, err := websocket.NewConfig(u.String(), origin)
+	if err != nil {
+		return nil, err
+	}
+
+	config.TlsConfig, err = h.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.Dialer = h.newDialer()
+
+	if h.Username != "" || h.Password != "" {
+		config.Header.Set("Authorization", basicAuth(h.Username, h.Password))
+	}
+	config.Header.Set("User-Agent", "Telegraf/"+internal.Version())
+	for k, v := range h.Headers {
+		config.Header.Set(k, v)
+	}
+	if h.identity != nil {
+		config.Header.Set(agentIDHeader, h.identity.AgentID)
+		if token := h.identity.token(); token != "" {
+			config.Header.Set(agentTokenHeader, token)
+		}
+	}
+
+	return websocket.DialConfig(config)
+}
+
+// applyPushedConfig applies plugin config pushed by the bridge like config
+// received in the response to a request.
+func (h *HTTP) applyPushedConfig(msg *pushMessage) error {
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+
+	revisions, err := h.configRevisions()
+	if err != nil {
+		return fmt.Errorf("reading plugin config revisions: %s", err)
+	}
+	return h.updatePluginConfig([]byte(msg.Config), msg.Signature, revisions)
+}
+
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestPushChannel(t *testing.T) {
+	statuses := make(chan configStatus, 1)
+	mux := http.NewServeMux()
+	mux.Handle("/push", websocket.Handler(func(ws *websocket.Conn) {
+		require.Equal(t, "10.0.0.1", ws.Request().URL.Query().Get("source"))
+		websocket.JSON.Send(ws, pushMessage{Type: "unknown"})
+		websocket.JSON.Send(ws, pushMessage{Type: "config", Config: "[[inputs.exec]]\n"})
+		// keep the connection open until the client closes it
+		var msg pushMessage
+		websocket.JSON.Receive(ws, &msg)
+	}))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		var status configStatus
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+		statuses <- status
+		w.WriteHeader(http.StatusNoContent)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir, cleanup := writeTestConfig(t, "[[outputs.http]]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:         true,
+		ConfigFilePath:       dir,
+		URL:                  ts.URL + "/write",
+		SourceAddress:        "10.0.0.1",
+		AllowedRemotePlugins: []string{"inputs.cpu"},
+		ConfigStatusURL:      ts.URL + "/status",
+		ConfigWebSocketURL:   "ws" + strings.TrimPrefix(ts.URL, "http") + "/push",
+	}
+	require.NoError(t, plugin.Connect())
+
+	select {
+	case status := <-statuses:
+		require.False(t, status.Accepted)
+		require.Equal(t, "plugins not allowed: inputs.exec", status.Error)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pushed config not applied")
+	}
+
+	require.NoError(t, plugin.Close())
+}
+
+func TestPushedConfigRevisionsError(t *testing.T) {
+	dir, cleanup := writeTestConfig(t, "[[inputs.cpu]\n")
+	defer cleanup()
+
+	plugin := &HTTP{
+		ManageConfig:   true,
+		ConfigFilePath: dir,
+	}
+	err := plugin.applyPushedConfig(&pushMessage{Type: "config", Config: "[[inputs.cpu]]\n"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "reading plugin config revisions")
+}
+
+func TestCheckPushChannel(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin *HTTP
+		err    string
+	}{
+		{
+			name:   "basic auth",
+			plugin: &HTTP{ConfigWebSocketURL: "wss://127.0.0.1/push", Username: "telegraf", Password: "secret"},
+		},
+		{
+			name:   "unix socket",
+			plugin: &HTTP{ConfigWebSocketURL: "unix:///var/run/bridge.sock/push"},
+			err:    "expected a ws:// or wss:// url",
+		},
+		{
+			name:   "proxy",
+			plugin: &HTTP{ConfigWebSocketURL: "ws://127.0.0.1/push", HTTPProxyURL: "http://proxy:3128"},
+			err:    "cannot be combined with http_proxy_url",
+		},
+		{
+			name: "other authentication",
+			plugin: &HTTP{
+				ConfigWebSocketURL: "ws://127.0.0.1/push",
+				ClientID:           "id",
+				Kerberos:           true,
+				AuthURL:            "http://127.0.0.1/login",
+				AWSService:         "execute-api",
+			},
+			err: "cannot be combined with OAuth2, kerberos, auth_url, aws_service",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plugin.checkPushChannel()
+			if tt.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+)
+
+// writeErrors aggregates the errors of batches written by several workers.
+type writeErrors struct {
+	errs    []error
+	batches int
+}
+
+func (e *writeErrors) Error() string {
+	msgs := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d of %d batches failed: %s", len(e.errs), e.batches, strings.Join(msgs, "; "))
+}
+
+// splitMetrics splits the metrics of a flush into n parts of about the same
+// size.
+func splitMetrics(metrics []telegraf.Metric, n int) [][]telegraf.Metric {
+	if n > len(metrics) {
+		n = len(metrics)
+	}
+	parts := make([][]telegraf.Metric, 0, n)
+	for i := 0; i < n; i++ {
+		begin := i * len(metrics) / n
+		end := (i + 1) * len(metrics) / n
+		parts = append(parts, metrics[begin:end])
+	}
+	return parts
+}
+
+// writeBatch sends a batch unless the circuit breaker is open or the bridge
+// asked to retry later, dropping it when the bridge refuses it with one of
+// drop_status_codes.  Only the writes sent count for the circuit breaker.
+func (h *HTTP) writeBatch(b batch) error {
+	err := h.checkRetryAfter()
+	if err != nil {
+		return err
+	}
+	err = h.breaker.allow()
+	if err != nil {
+		return err
+	}
+
+	err = h.writeWithRetry(b)
+	h.breaker.record(isFailoverError(err))
+	if err != nil && h.isDropError(err) {
+		log.Printf("E! [outputs.http] Dropping %d metrics: %s", b.count, err)
+		return nil
+	}
+	return err
+}
+
+// writeConcurrently sends batches with up to workers requests in flight.
+// Every batch is attempted; the errors of the failed ones are returned
+// together, and the metrics of the others are not sent again.
+func (h *HTTP) writeConcurrently(batches []batch) error {
+	ch := make(chan batch)
+	errs := make([][]error, h.Workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < h.Workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for b := range ch {
+				if err := h.writeBatch(b); err != nil {
+					errs[worker] = append(errs[worker], err)
+					continue
+				}
+				h.accepted.add(b)
+			}
+		}(i)
+	}
+
+	for _, b := range batches {
+		ch <- b
+	}
+	close(ch)
+	wg.Wait()
+
+	result := &writeErrors{batches: len(batches)}
+	for _, workerErrs := range errs {
+		result.errs = append(result.errs, workerErrs...)
+	}
+	switch len(result.errs) {
+	case 0:
+		return nil
+	case 1:
+		return result.errs[0]
+	}
+	return result
+}
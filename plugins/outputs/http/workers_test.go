@@ -0,0 +1,99 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitMetrics(t *testing.T) {
+	metrics := getMetrics(t, 5)
+
+	parts := splitMetrics(metrics, 2)
+	require.Len(t, parts, 2)
+	require.Len(t, parts[0], 2)
+	require.Len(t, parts[1], 3)
+
+	parts = splitMetrics(metrics, 10)
+	require.Len(t, parts, 5)
+}
+
+func TestWorkers(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight, lines int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		lines += strings.Count(string(body), "\n")
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:     ts.URL,
+		Workers: 4,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	require.NoError(t, plugin.Write(getMetrics(t, 8)))
+	require.Equal(t, 8, lines)
+	require.True(t, maxInFlight > 1)
+}
+
+func TestWorkersErrors(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		if strings.Contains(string(body), "value=0") || strings.Contains(string(body), "value=3") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	plugin := &HTTP{
+		URL:     ts.URL,
+		Workers: 4,
+	}
+	plugin.SetSerializer(influx.NewSerializer())
+	require.NoError(t, plugin.Connect())
+
+	metrics := getMetrics(t, 4)
+	err := plugin.Write(metrics)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "2 of 4 batches failed")
+	require.Equal(t, 4, requests)
+
+	// only the failed batches are sent again
+	err = plugin.Write(metrics)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "2 of 2 batches failed")
+	require.Equal(t, 6, requests)
+}
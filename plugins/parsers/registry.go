@@ -35,6 +35,13 @@ type ParserFuncInput interface {
 	SetParserFunc(fn ParserFunc)
 }
 
+// ParserConfigInput is an interface for input plugins that build parsers
+// from their parser config themselves, such as with other data formats.
+type ParserConfigInput interface {
+	// SetParserConfig sets the parser config of the plugin.
+	SetParserConfig(config *Config)
+}
+
 // Parser is an interface defining functions that a parser plugin must satisfy.
 type Parser interface {
 	// Parse takes a byte buffer separated by newlines
@@ -123,6 +123,12 @@ func (s *Serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
 	copy(out, s.buf.Bytes())
 	return out, nil
 }
+
+// MediaType returns the media type of the line protocol output.
+func (s *Serializer) MediaType() string {
+	return "text/plain; charset=utf-8"
+}
+
 func (s *Serializer) Write(w io.Writer, m telegraf.Metric) (int, error) {
 	err := s.writeMetric(w, m)
 	return s.bytesWritten, err
@@ -47,6 +47,11 @@ func (s *serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
 	return serialized, nil
 }
 
+// MediaType returns the media type of the json output.
+func (s *serializer) MediaType() string {
+	return "application/json"
+}
+
 func (s *serializer) createObject(metric telegraf.Metric) map[string]interface{} {
 	m := make(map[string]interface{}, 4)
 	m["tags"] = metric.Tags()
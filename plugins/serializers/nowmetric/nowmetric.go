@@ -65,6 +65,11 @@ func (s *serializer) SerializeBatch(metrics []telegraf.Metric) (out []byte, err
 	return replaced, nil
 }
 
+// MediaType returns the media type of the nowmetric output.
+func (s *serializer) MediaType() string {
+	return "application/json"
+}
+
 func (s *serializer) createObject(metric telegraf.Metric) ([]byte, error) {
 	/*  ServiceNow Operational Intelligence supports an array of JSON objects.
 	** Following elements accepted in the request body:
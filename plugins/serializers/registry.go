@@ -41,6 +41,14 @@ type Serializer interface {
 	SerializeBatch(metrics []telegraf.Metric) ([]byte, error)
 }
 
+// MediaTyper is an optional interface for serializers that know the media
+// type of their output, such as "application/json", so that outputs can
+// announce it in a Content-Type header.
+type MediaTyper interface {
+	// MediaType returns the media type of the serialized metrics.
+	MediaType() string
+}
+
 // Config is a struct that covers the data types needed for all serializer types,
 // and can be used to instantiate _any_ of the serializers.
 type Config struct {
@@ -45,6 +45,11 @@ func (s *serializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
 	return serialized, nil
 }
 
+// MediaType returns the media type of the splunkmetric output.
+func (s *serializer) MediaType() string {
+	return "application/json"
+}
+
 func (s *serializer) createObject(metric telegraf.Metric) (metricGroup []byte, err error) {
 
 	/*  Splunk supports one metric json object, and does _not_ support an array of JSON objects.
@@ -120,6 +120,41 @@ func Metrics() []telegraf.Metric {
 	return metrics
 }
 
+// Snapshot returns the registered stats as telegraf metrics, like Metrics,
+// but leaves out the timing stats, as reading them clears their average.
+// It is meant for plugins reporting the state of the agent elsewhere,
+// without disturbing the internal input.
+func Snapshot() []telegraf.Metric {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	now := time.Now()
+	metrics := make([]telegraf.Metric, 0, len(registry.stats))
+	for _, stats := range registry.stats {
+		var tags map[string]string
+		var name string
+		fields := map[string]interface{}{}
+		for fieldname, stat := range stats {
+			if _, ok := stat.(*timingStat); ok {
+				continue
+			}
+			tags = stat.Tags()
+			name = stat.Name()
+			fields[fieldname] = stat.Get()
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		metric, err := metric.New(name, tags, fields, now)
+		if err != nil {
+			log.Printf("E! Error creating selfstat metric: %s", err)
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
 type rgstry struct {
 	stats map[uint64]map[string]Stat
 	mu    sync.Mutex
@@ -219,3 +219,21 @@ func TestRegisterMetricsAndVerify(t *testing.T) {
 		},
 	)
 }
+
+func TestSnapshot(t *testing.T) {
+	testLock.Lock()
+	defer testCleanup()
+
+	timing := RegisterTiming("test", "test_field1_ns", map[string]string{"test": "foo"})
+	counter := Register("test", "test_field2", map[string]string{"test": "foo"})
+	RegisterTiming("test_timing", "test_field1_ns", map[string]string{"test": "bar"})
+	timing.Incr(10)
+	counter.Incr(3)
+
+	metrics := Snapshot()
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{"test_field2": int64(3)}, metrics[0].Fields())
+
+	// timings are not read, so their average is kept
+	assert.Equal(t, int64(10), timing.Get())
+}